@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+)
+
+// Deploy strategies that can be configured per service.
+const (
+	// strategyRecreate updates the service's containers in place.
+	strategyRecreate = "recreate"
+	// strategyBlueGreen starts a parallel set of containers and only removes the
+	// old set once the new one is healthy.
+	strategyBlueGreen = "blue-green"
+)
+
+const (
+	defaultHealthTimeout = 2 * time.Minute
+	defaultDrainPeriod   = 10 * time.Second
+	healthPollInterval   = 2 * time.Second
+)
+
+var deployColors = []string{"blue", "green"}
+
+// deployBlueGreen starts the project under a parallel project name next to the
+// running one. Both sets carry the same traefik router labels, so traefik load
+// balances across them until the old set is removed.
+//
+// Networks and volumes keep the base project's names and are shared by both
+// sets, so traefik reaches the new containers the same way it reached the old
+// ones and data is carried over.
+func deployBlueGreen(ctx context.Context, rt runtime, project *types.Project, sc serviceConfig) (*upResult, error) {
+	for _, service := range project.Services {
+		if service.ContainerName != "" {
+			return nil, fmt.Errorf("service %s: container_name can't be used with the %s strategy", service.Name, strategyBlueGreen)
+		}
+		if len(service.Ports) > 0 {
+			return nil, fmt.Errorf("service %s: published ports can't be used with the %s strategy", service.Name, strategyBlueGreen)
+		}
+	}
+
+	candidates := colorCandidates(project.Name)
+	next, err := nextColorProject(ctx, rt, project.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	nextProject := *project
+	nextProject.Name = next
+
+	log.Printf("Starting %s alongside the running containers", next)
+	up, err := rt.Up(ctx, &nextProject)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitHealthy(ctx, rt, next, sc.healthTimeout()); err != nil {
+		log.Printf("New containers for %s did not become healthy, removing them: %v", next, err)
+		if downErr := rt.Down(ctx, next); downErr != nil {
+			log.Printf("Warning: failed to remove %s: %v", next, downErr)
+		}
+		return nil, err
+	}
+
+	log.Printf("Waiting %s for traefik to route to %s", sc.drainPeriod(), next)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(sc.drainPeriod()):
+	}
+
+	for _, name := range candidates {
+		if name == next {
+			continue
+		}
+		log.Printf("Removing previous containers for %s", name)
+		if err := rt.Down(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to remove previous containers: %w", err)
+		}
+	}
+
+	return up, nil
+}
+
+// colorCandidates returns every project name a blue/green service's
+// containers may run under. The base project holds containers deployed before
+// blue/green was enabled.
+func colorCandidates(projectName string) []string {
+	candidates := []string{projectName}
+	for _, color := range deployColors {
+		candidates = append(candidates, colorProjectName(projectName, color))
+	}
+	return candidates
+}
+
+// planBlueGreen works out what deployBlueGreen would do: start the project's
+// containers under the next color, then remove those of the running color.
+func planBlueGreen(ctx context.Context, rt runtime, project *types.Project) (*planResult, error) {
+	next, err := nextColorProject(ctx, rt, project.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	nextProject := *project
+	nextProject.Name = next
+
+	result, err := rt.Plan(ctx, &nextProject)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range result.Containers {
+		reason := fmt.Sprintf("switching to %s", next)
+		if c.Reason != "" {
+			reason += ": " + c.Reason
+		}
+		result.Containers[i].Reason = reason
+	}
+
+	for _, name := range colorCandidates(project.Name) {
+		if name == next {
+			continue
+		}
+		containers, err := rt.Containers(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range containers {
+			result.Containers = append(result.Containers, plannedContainer{
+				Service: c.Service,
+				Name:    c.Name,
+				Action:  containerRemoved,
+				Reason:  fmt.Sprintf("replaced by %s once it is healthy", next),
+			})
+		}
+	}
+	return result, nil
+}
+
+// nextColorProject returns the project name the next deploy starts its
+// containers under: whichever color isn't running.
+func nextColorProject(ctx context.Context, rt runtime, projectName string) (string, error) {
+	active := make(map[string]bool)
+	for _, name := range colorCandidates(projectName) {
+		containers, err := rt.Containers(ctx, name)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range containers {
+			if c.State == "running" {
+				active[name] = true
+			}
+		}
+	}
+
+	next := colorProjectName(projectName, deployColors[0])
+	if active[next] {
+		next = colorProjectName(projectName, deployColors[1])
+	}
+	return next, nil
+}
+
+func colorProjectName(projectName, color string) string {
+	return fmt.Sprintf("%s-%s", projectName, color)
+}
+
+// waitHealthy waits until every container in the project is running and,
+// if it has a healthcheck, healthy.
+func waitHealthy(ctx context.Context, rt runtime, projectName string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	for {
+		containers, err := rt.Containers(ctx, projectName)
+		if err != nil {
+			return err
+		}
+
+		ready := len(containers) > 0
+		for _, c := range containers {
+			switch {
+			case c.State != "running":
+				ready = false
+				if c.State == "exited" || c.State == "dead" {
+					return fmt.Errorf("container %s is %s", c.Name, c.State)
+				}
+			case c.Health == "unhealthy":
+				return fmt.Errorf("container %s is unhealthy", c.Name)
+			case c.Health != "" && c.Health != "healthy":
+				ready = false
+			}
+		}
+		if ready {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for containers to become healthy: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
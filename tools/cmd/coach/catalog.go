@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+	"github.com/baely/infra/tools/internal/provenance"
+	"github.com/baely/infra/tools/internal/routing"
+)
+
+// Labels services use to describe themselves in the catalog.
+const (
+	labelPublicURL         = "baileys.public.url"
+	labelPublicTitle       = "baileys.public.title"
+	labelPublicDescription = "baileys.public.description"
+)
+
+// Statuses of a service in the catalog.
+const (
+	statusRunning  = "running"
+	statusDegraded = "degraded"
+	statusStopped  = "stopped"
+	statusUnknown  = "unknown"
+)
+
+// catalogEntry is a deployed service as listed in the catalog.
+type catalogEntry struct {
+	Name        string           `json:"name"`
+	Environment string           `json:"environment"`
+	URL         string           `json:"url,omitempty"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	Ref         string           `json:"ref,omitempty"`
+	SHA         string           `json:"sha,omitempty"`
+	Host        string           `json:"host,omitempty"`
+	DeployedAt  time.Time        `json:"deployed_at,omitzero"`
+	Source      *serviceSource   `json:"source,omitempty"`
+	Status      string           `json:"status"`
+	Containers  []containerState `json:"-"`
+}
+
+// serviceSource is the repository scout synced a service's config from.
+type serviceSource struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+	SHA        string `json:"sha"`
+}
+
+// public reports whether the service has described itself with baileys.public.* labels.
+func (e catalogEntry) public() bool {
+	return e.URL != "" || e.Title != ""
+}
+
+func (s *coachService) ListServices(ctx context.Context, req *squadv1alpha1.ListServicesRequest) (*squadv1alpha1.ListServicesResponse, error) {
+	log.Printf("Listing services")
+
+	entries, err := s.catalog(ctx)
+	if err != nil {
+		log.Printf("Failed to list services: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to list services: %v", err)
+	}
+
+	resp := &squadv1alpha1.ListServicesResponse{}
+	for _, e := range entries {
+		svc := &squadv1alpha1.Service{
+			Name:        e.Name,
+			Environment: e.Environment,
+			Url:         e.URL,
+			Title:       e.Title,
+			Description: e.Description,
+			Ref:         e.Ref,
+			Sha:         e.SHA,
+			Host:        e.Host,
+			Status:      e.Status,
+			Containers:  containersToProto(e.Containers),
+		}
+		if !e.DeployedAt.IsZero() {
+			svc.DeployedAt = timestamppb.New(e.DeployedAt)
+		}
+		if e.Source != nil {
+			svc.Source = &squadv1alpha1.ServiceSource{
+				Repository: e.Source.Repository,
+				Ref:        e.Source.Ref,
+				Sha:        e.Source.SHA,
+			}
+		}
+		resp.Services = append(resp.Services, svc)
+	}
+
+	log.Printf("Listed %d services", len(resp.Services))
+	return resp, nil
+}
+
+// catalog describes every service that has been deployed, sorted by name and environment.
+func (s *coachService) catalog(ctx context.Context) ([]catalogEntry, error) {
+	workspaces, err := listWorkspaces(s.stateDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []catalogEntry
+	for _, ws := range workspaces {
+		d, err := ws.deployment()
+		if err != nil {
+			log.Printf("Warning: %s: %v", ws.service, err)
+		}
+		if d == nil {
+			// Never successfully deployed.
+			continue
+		}
+
+		entry := catalogEntry{
+			Name:        ws.service,
+			Environment: ws.environment,
+			Ref:         d.Ref,
+			SHA:         d.SHA,
+			Host:        d.Host,
+			DeployedAt:  d.DeployedAt,
+		}
+		s.describe(&entry, ws)
+		entry.Source = readSource(ws)
+		entry.Containers, entry.Status = s.liveStatus(ctx, ws, d.Host)
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].Environment < entries[j].Environment
+	})
+	return entries, nil
+}
+
+// describe fills in the entry from the first compose service in the
+// workspace's deploy.yaml that has baileys.public.* labels.
+func (s *coachService) describe(entry *catalogEntry, ws *workspace) {
+	b, err := os.ReadFile(filepath.Join(ws.dir, deployFileName))
+	if err != nil {
+		log.Printf("Warning: failed to read %s for %s: %v", deployFileName, ws.service, err)
+		return
+	}
+
+	stack, err := routing.ParseDeployFile(ws.service, b)
+	if err != nil {
+		log.Printf("Warning: %s: %v", ws.service, err)
+		return
+	}
+
+	names := make([]string, 0, len(stack.Services))
+	for name := range stack.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		labels := stack.Services[name]
+		if labels[labelPublicURL] == "" && labels[labelPublicTitle] == "" {
+			continue
+		}
+		entry.URL = labels[labelPublicURL]
+		entry.Title = labels[labelPublicTitle]
+		entry.Description = labels[labelPublicDescription]
+		return
+	}
+}
+
+// readSource returns where the config deployed from the workspace was synced
+// from, or nil if it has no scout manifest.
+func readSource(ws *workspace) *serviceSource {
+	m, err := provenance.Read(ws.dir)
+	if err != nil {
+		log.Printf("Warning: %s: %v", ws.service, err)
+		return nil
+	}
+	if m == nil {
+		return nil
+	}
+	return &serviceSource{Repository: m.Repository, Ref: m.Ref, SHA: m.SHA}
+}
+
+// liveStatus returns the containers of the service deployed from the workspace
+// and a summary of their state.
+func (s *coachService) liveStatus(ctx context.Context, ws *workspace, host string) ([]containerState, string) {
+	rt, err := s.runtimeFor(ws.service, ws.environment, host)
+	if err != nil {
+		log.Printf("Warning: %s: %v", ws.service, err)
+		return nil, statusUnknown
+	}
+
+	projectName := ws.projectName()
+	projects := []string{projectName}
+	if s.config.Services[ws.service].strategy() == strategyBlueGreen {
+		projects = colorCandidates(projectName)
+	}
+
+	var containers []containerState
+	for _, name := range projects {
+		c, err := rt.Containers(ctx, name)
+		if err != nil {
+			log.Printf("Warning: failed to get containers for %s: %v", name, err)
+			return nil, statusUnknown
+		}
+		containers = append(containers, c...)
+	}
+
+	running := 0
+	for _, c := range containers {
+		if c.State == "running" && (c.Health == "" || c.Health == "healthy") {
+			running++
+		}
+	}
+
+	switch {
+	case len(containers) > 0 && running == len(containers):
+		return containers, statusRunning
+	case running > 0:
+		return containers, statusDegraded
+	default:
+		return containers, statusStopped
+	}
+}
+
+// catalogHandler serves the public services in the catalog as an HTML page
+// at / and as JSON at /services.json. Only services in the default
+// environment with baileys.public.* labels are listed, as the endpoint isn't
+// authenticated.
+func (s *coachService) catalogHandler() http.Handler {
+	publicEntries := func(r *http.Request) ([]catalogEntry, error) {
+		entries, err := s.catalog(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		var public []catalogEntry
+		for _, e := range entries {
+			if e.public() && e.Environment == s.config.environment() {
+				public = append(public, e)
+			}
+		}
+		return public, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /services.json", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := publicEntries(r)
+		if err != nil {
+			log.Printf("Failed to list services: %v", err)
+			http.Error(w, "failed to list services", http.StatusInternalServerError)
+			return
+		}
+		if entries == nil {
+			entries = []catalogEntry{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			log.Printf("Failed to write services: %v", err)
+		}
+	})
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := publicEntries(r)
+		if err != nil {
+			log.Printf("Failed to list services: %v", err)
+			http.Error(w, "failed to list services", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := catalogPage.Execute(w, entries); err != nil {
+			log.Printf("Failed to render catalog: %v", err)
+		}
+	})
+	return mux
+}
+
+var catalogPage = template.Must(template.New("catalog").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Services</title>
+<style>
+body { font-family: sans-serif; max-width: 40rem; margin: 2rem auto; padding: 0 1rem; }
+li { margin-bottom: 1rem; list-style: none; }
+.status { font-size: 0.8rem; color: #666; }
+.running { color: #2a7; }
+.degraded { color: #c80; }
+.stopped, .unknown { color: #c33; }
+</style>
+</head>
+<body>
+<h1>Services</h1>
+<ul>
+{{- range .}}
+<li>
+<a href="{{.URL}}">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a>
+<span class="status {{.Status}}">{{.Status}}</span>
+{{- if .Description}}<br>{{.Description}}{{end}}
+<br><span class="status">{{.Ref}}{{if not .DeployedAt.IsZero}}, deployed {{.DeployedAt.Format "2 Jan 2006 15:04 MST"}}{{end}}</span>
+</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
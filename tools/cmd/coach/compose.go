@@ -0,0 +1,878 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/loader"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+)
+
+// Labels set by docker compose. Coach sets the same ones so that containers it
+// creates can be inspected and managed with the compose CLI, and vice versa.
+const (
+	labelProject     = "com.docker.compose.project"
+	labelService     = "com.docker.compose.service"
+	labelNumber      = "com.docker.compose.container-number"
+	labelOneoff      = "com.docker.compose.oneoff"
+	labelWorkingDir  = "com.docker.compose.project.working_dir"
+	labelConfigFiles = "com.docker.compose.project.config_files"
+	labelConfigHash  = "com.docker.compose.config-hash"
+	labelImage       = "com.docker.compose.image"
+	labelNetwork     = "com.docker.compose.network"
+	labelVolume      = "com.docker.compose.volume"
+)
+
+const deployFileName = "deploy.yaml"
+
+// loadProject parses workDir/deploy.yaml into a compose project named after the directory,
+// matching what `docker compose -f deploy.yaml` would have loaded.
+func loadProject(ctx context.Context, workDir string, extra ...cli.ProjectOptionsFn) (*types.Project, error) {
+	opts, err := cli.NewProjectOptions(
+		[]string{filepath.Join(workDir, deployFileName)},
+		append([]cli.ProjectOptionsFn{
+			cli.WithWorkingDirectory(workDir),
+			cli.WithName(loader.NormalizeProjectName(filepath.Base(workDir))),
+			cli.WithOsEnv,
+			cli.WithDotEnv,
+		}, extra...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure project loader: %w", err)
+	}
+
+	project, err := opts.LoadProject(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", deployFileName, err)
+	}
+	useStoredSecrets(project)
+	return project, nil
+}
+
+func (e *dockerEngine) Up(ctx context.Context, project *types.Project) (*upResult, error) {
+	if err := e.ensureNetworks(ctx, project); err != nil {
+		return nil, err
+	}
+	if err := e.ensureVolumes(ctx, project); err != nil {
+		return nil, err
+	}
+
+	result := &upResult{}
+	err := project.ForEachService(project.ServiceNames(), func(name string, service *types.ServiceConfig) error {
+		state, err := e.upService(ctx, project, *service)
+		if err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+		result.Containers = append(result.Containers, *state)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (e *dockerEngine) ensureNetworks(ctx context.Context, project *types.Project) error {
+	for _, key := range project.NetworkNames() {
+		config := project.Networks[key]
+
+		_, err := e.client.NetworkInspect(ctx, config.Name, network.InspectOptions{})
+		if err == nil {
+			continue
+		}
+		if !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to inspect network %s: %w", config.Name, err)
+		}
+		if config.External {
+			return fmt.Errorf("external network %s not found", config.Name)
+		}
+
+		labels := map[string]string{
+			labelProject: project.Name,
+			labelNetwork: key,
+		}
+		for k, v := range config.Labels {
+			labels[k] = v
+		}
+
+		log.Printf("Creating network %s", config.Name)
+		_, err = e.client.NetworkCreate(ctx, config.Name, network.CreateOptions{
+			Driver:     config.Driver,
+			Options:    config.DriverOpts,
+			Internal:   config.Internal,
+			Attachable: config.Attachable,
+			EnableIPv4: config.EnableIPv4,
+			EnableIPv6: config.EnableIPv6,
+			Labels:     labels,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create network %s: %w", config.Name, err)
+		}
+	}
+	return nil
+}
+
+func (e *dockerEngine) ensureVolumes(ctx context.Context, project *types.Project) error {
+	for _, key := range project.VolumeNames() {
+		config := project.Volumes[key]
+
+		_, err := e.client.VolumeInspect(ctx, config.Name)
+		if err == nil {
+			continue
+		}
+		if !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to inspect volume %s: %w", config.Name, err)
+		}
+		if config.External {
+			return fmt.Errorf("external volume %s not found", config.Name)
+		}
+
+		labels := map[string]string{
+			labelProject: project.Name,
+			labelVolume:  key,
+		}
+		for k, v := range config.Labels {
+			labels[k] = v
+		}
+
+		log.Printf("Creating volume %s", config.Name)
+		_, err = e.client.VolumeCreate(ctx, volume.CreateOptions{
+			Name:       config.Name,
+			Driver:     config.Driver,
+			DriverOpts: config.DriverOpts,
+			Labels:     labels,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create volume %s: %w", config.Name, err)
+		}
+	}
+	return nil
+}
+
+// upService makes sure the service's container is running with its current config.
+// A container is recreated when its config hash or image has changed.
+func (e *dockerEngine) upService(ctx context.Context, project *types.Project, service types.ServiceConfig) (*containerState, error) {
+	if service.Image == "" {
+		return nil, fmt.Errorf("no image specified")
+	}
+
+	img, err := e.client.ImageInspect(ctx, service.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", service.Image, err)
+	}
+
+	spec, err := newContainerSpec(project, service)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := spec.hash(img.ID)
+	if err != nil {
+		return nil, err
+	}
+	spec.config.Labels[labelConfigHash] = hash
+	spec.config.Labels[labelImage] = img.ID
+
+	existing, err := e.serviceContainers(ctx, project.Name, service.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	action := containerCreated
+	var id string
+	for _, c := range existing {
+		if id == "" && c.Labels[labelConfigHash] == hash {
+			id = c.ID
+			action = containerUnchanged
+			if c.State != container.StateRunning {
+				action = containerStarted
+			}
+			continue
+		}
+
+		log.Printf("Removing outdated container %s", strings.Join(c.Names, ","))
+		if err := e.removeContainer(ctx, c.ID, service.StopGracePeriod); err != nil {
+			return nil, err
+		}
+		action = containerRecreated
+	}
+
+	if id == "" {
+		log.Printf("Creating container %s", spec.name)
+		resp, err := e.client.ContainerCreate(ctx, spec.config, spec.hostConfig, spec.networkingConfig, nil, spec.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create container: %w", err)
+		}
+		id = resp.ID
+
+		for _, endpoint := range spec.extraNetworks {
+			if err := e.client.NetworkConnect(ctx, endpoint.name, id, endpoint.settings); err != nil {
+				return nil, fmt.Errorf("failed to connect container to network %s: %w", endpoint.name, err)
+			}
+		}
+	}
+
+	if action != containerUnchanged {
+		log.Printf("Starting container %s", spec.name)
+		if err := e.client.ContainerStart(ctx, id, container.StartOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to start container: %w", err)
+		}
+	}
+
+	state, err := e.inspectContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	state.Action = action
+	state.Digest = repoDigest(service.Image, img.RepoDigests)
+
+	log.Printf("Container %s is %s (%s)", state.Name, state.State, action)
+	return state, nil
+}
+
+func (e *dockerEngine) serviceContainers(ctx context.Context, projectName, serviceName string) ([]container.Summary, error) {
+	containers, err := e.client.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelProject, projectName)),
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelService, serviceName)),
+			filters.Arg("label", fmt.Sprintf("%s=False", labelOneoff)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return containers, nil
+}
+
+func (e *dockerEngine) Containers(ctx context.Context, projectName string) ([]containerState, error) {
+	containers, err := e.projectContainers(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []containerState
+	for _, c := range containers {
+		state, err := e.inspectContainer(ctx, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, *state)
+	}
+	return states, nil
+}
+
+func (e *dockerEngine) Down(ctx context.Context, projectName string) error {
+	containers, err := e.projectContainers(ctx, projectName)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		log.Printf("Removing container %s", strings.Join(c.Names, ","))
+		if err := e.removeContainer(ctx, c.ID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *dockerEngine) projectContainers(ctx context.Context, projectName string) ([]container.Summary, error) {
+	containers, err := e.client.ContainerList(ctx, container.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s=%s", labelProject, projectName)),
+			filters.Arg("label", fmt.Sprintf("%s=False", labelOneoff)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return containers, nil
+}
+
+func (e *dockerEngine) removeContainer(ctx context.Context, id string, gracePeriod *types.Duration) error {
+	var timeout *int
+	if gracePeriod != nil {
+		seconds := int(time.Duration(*gracePeriod).Seconds())
+		timeout = &seconds
+	}
+
+	if err := e.client.ContainerStop(ctx, id, container.StopOptions{Timeout: timeout}); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", id, err)
+	}
+	if err := e.client.ContainerRemove(ctx, id, container.RemoveOptions{}); err != nil {
+		return fmt.Errorf("failed to remove container %s: %w", id, err)
+	}
+	return nil
+}
+
+func (e *dockerEngine) inspectContainer(ctx context.Context, id string) (*containerState, error) {
+	inspect, err := e.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container %s: %w", id, err)
+	}
+
+	state := &containerState{
+		Service: inspect.Config.Labels[labelService],
+		ID:      inspect.ID,
+		Name:    strings.TrimPrefix(inspect.Name, "/"),
+		Image:   inspect.Config.Image,
+		ImageID: inspect.Image,
+	}
+	if inspect.State != nil {
+		state.State = string(inspect.State.Status)
+		if inspect.State.Health != nil {
+			state.Health = string(inspect.State.Health.Status)
+		}
+	}
+	return state, nil
+}
+
+// containerSpec is everything needed to create a service's container.
+type containerSpec struct {
+	name             string
+	config           *container.Config
+	hostConfig       *container.HostConfig
+	networkingConfig *network.NetworkingConfig
+	// extraNetworks are connected after the container is created, as older
+	// daemons only accept a single network at creation time.
+	extraNetworks []endpoint
+}
+
+type endpoint struct {
+	name     string
+	settings *network.EndpointSettings
+}
+
+// hash identifies a container's config, so an unchanged service is left running.
+func (s *containerSpec) hash(imageID string) (string, error) {
+	b, err := json.Marshal(struct {
+		Name             string
+		ImageID          string
+		Config           *container.Config
+		HostConfig       *container.HostConfig
+		NetworkingConfig *network.NetworkingConfig
+		ExtraNetworks    []string
+	}{
+		Name:             s.name,
+		ImageID:          imageID,
+		Config:           s.config,
+		HostConfig:       s.hostConfig,
+		NetworkingConfig: s.networkingConfig,
+		ExtraNetworks:    endpointNames(s.extraNetworks),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash container config: %w", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func endpointNames(endpoints []endpoint) []string {
+	var names []string
+	for _, e := range endpoints {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+func newContainerSpec(project *types.Project, service types.ServiceConfig) (*containerSpec, error) {
+	if fields := unsupportedFields(service); len(fields) > 0 {
+		return nil, fmt.Errorf("unsupported compose field %s", strings.Join(fields, ", "))
+	}
+
+	name := service.ContainerName
+	if name == "" {
+		name = fmt.Sprintf("%s-%s-1", project.Name, service.Name)
+	}
+
+	labels := map[string]string{
+		labelProject:     project.Name,
+		labelService:     service.Name,
+		labelNumber:      "1",
+		labelOneoff:      "False",
+		labelWorkingDir:  project.WorkingDir,
+		labelConfigFiles: strings.Join(project.ComposeFiles, ","),
+	}
+	for k, v := range service.Labels {
+		labels[k] = v
+	}
+
+	var env []string
+	for k, v := range service.Environment {
+		if v != nil {
+			env = append(env, fmt.Sprintf("%s=%s", k, *v))
+		}
+	}
+	sort.Strings(env)
+
+	config := &container.Config{
+		Hostname:     service.Hostname,
+		Domainname:   service.DomainName,
+		User:         service.User,
+		Env:          env,
+		Cmd:          []string(service.Command),
+		Entrypoint:   []string(service.Entrypoint),
+		Image:        service.Image,
+		WorkingDir:   service.WorkingDir,
+		Labels:       labels,
+		StopSignal:   service.StopSignal,
+		Tty:          service.Tty,
+		OpenStdin:    service.StdinOpen,
+		ExposedPorts: nat.PortSet{},
+	}
+	if service.StopGracePeriod != nil {
+		seconds := int(time.Duration(*service.StopGracePeriod).Seconds())
+		config.StopTimeout = &seconds
+	}
+	if service.HealthCheck != nil {
+		config.Healthcheck = healthConfig(service.HealthCheck)
+	}
+
+	restartPolicy, err := restartPolicy(service.Restart)
+	if err != nil {
+		return nil, err
+	}
+
+	hostConfig := &container.HostConfig{
+		RestartPolicy:  restartPolicy,
+		Privileged:     service.Privileged,
+		ReadonlyRootfs: service.ReadOnly,
+		CapAdd:         service.CapAdd,
+		CapDrop:        service.CapDrop,
+		SecurityOpt:    service.SecurityOpt,
+		DNS:            service.DNS,
+		DNSSearch:      service.DNSSearch,
+		DNSOptions:     service.DNSOpts,
+		ExtraHosts:     service.ExtraHosts.AsList(":"),
+		Init:           service.Init,
+		PortBindings:   nat.PortMap{},
+		Sysctls:        service.Sysctls,
+		Annotations:    service.Annotations,
+		GroupAdd:       service.GroupAdd,
+		OomScoreAdj:    int(service.OomScoreAdj),
+		ShmSize:        int64(service.ShmSize),
+		PidMode:        container.PidMode(serviceContainerMode(project, service.Pid)),
+		IpcMode:        container.IpcMode(serviceContainerMode(project, service.Ipc)),
+		UTSMode:        container.UTSMode(service.Uts),
+		UsernsMode:     container.UsernsMode(service.UserNSMode),
+		CgroupnsMode:   container.CgroupnsMode(service.Cgroup),
+		Runtime:        service.Runtime,
+		StorageOpt:     service.StorageOpt,
+		Resources:      resources(service),
+	}
+	for _, from := range service.VolumesFrom {
+		hostConfig.VolumesFrom = append(hostConfig.VolumesFrom, volumesFrom(project, from))
+	}
+	if service.Logging != nil {
+		hostConfig.LogConfig = container.LogConfig{
+			Type:   service.Logging.Driver,
+			Config: service.Logging.Options,
+		}
+	}
+	if len(service.Tmpfs) > 0 {
+		hostConfig.Tmpfs = make(map[string]string)
+		for _, t := range service.Tmpfs {
+			target, options, _ := strings.Cut(t, ":")
+			hostConfig.Tmpfs[target] = options
+		}
+	}
+
+	for _, p := range service.Ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		port := nat.Port(fmt.Sprintf("%d/%s", p.Target, protocol))
+		config.ExposedPorts[port] = struct{}{}
+		if p.Published != "" {
+			hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], nat.PortBinding{
+				HostIP:   p.HostIP,
+				HostPort: p.Published,
+			})
+		}
+	}
+	for _, expose := range service.Expose {
+		port, err := nat.NewPort("tcp", expose)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expose %s: %w", expose, err)
+		}
+		config.ExposedPorts[port] = struct{}{}
+	}
+
+	if err := addMounts(project, service, hostConfig); err != nil {
+		return nil, err
+	}
+
+	spec := &containerSpec{
+		name:       name,
+		config:     config,
+		hostConfig: hostConfig,
+	}
+
+	switch {
+	case service.NetworkMode != "":
+		hostConfig.NetworkMode = container.NetworkMode(serviceContainerMode(project, service.NetworkMode))
+	default:
+		for i, key := range service.NetworksByPriority() {
+			settings := &network.EndpointSettings{
+				Aliases: []string{service.Name},
+			}
+			if cfg := service.Networks[key]; cfg != nil {
+				settings.Aliases = append(settings.Aliases, cfg.Aliases...)
+				if cfg.Ipv4Address != "" || cfg.Ipv6Address != "" {
+					settings.IPAMConfig = &network.EndpointIPAMConfig{
+						IPv4Address: cfg.Ipv4Address,
+						IPv6Address: cfg.Ipv6Address,
+					}
+				}
+			}
+
+			networkName := project.Networks[key].Name
+			if i == 0 {
+				hostConfig.NetworkMode = container.NetworkMode(networkName)
+				spec.networkingConfig = &network.NetworkingConfig{
+					EndpointsConfig: map[string]*network.EndpointSettings{networkName: settings},
+				}
+				continue
+			}
+			spec.extraNetworks = append(spec.extraNetworks, endpoint{name: networkName, settings: settings})
+		}
+	}
+
+	return spec, nil
+}
+
+// serviceContainerMode turns a namespace mode such as network_mode, pid or
+// ipc that refers to another service, e.g. service:db, into one referring to
+// that service's container.
+func serviceContainerMode(project *types.Project, mode string) string {
+	if dep, ok := strings.CutPrefix(mode, "service:"); ok {
+		return "container:" + serviceContainerName(project, dep)
+	}
+	return mode
+}
+
+// serviceContainerName is the name of the container Coach creates for a service.
+func serviceContainerName(project *types.Project, service string) string {
+	if s, ok := project.Services[service]; ok && s.ContainerName != "" {
+		return s.ContainerName
+	}
+	return fmt.Sprintf("%s-%s-1", project.Name, service)
+}
+
+// volumesFrom turns a volumes_from entry, either a service or
+// container:<name>, optionally followed by :ro or :rw, into the daemon's form.
+func volumesFrom(project *types.Project, from string) string {
+	if name, ok := strings.CutPrefix(from, "container:"); ok {
+		return name
+	}
+	service, mode, hasMode := strings.Cut(from, ":")
+	name := serviceContainerName(project, service)
+	if hasMode {
+		name += ":" + mode
+	}
+	return name
+}
+
+// resources returns the service's resource limits and devices. Limits under
+// deploy.resources take precedence over the older top-level fields such as
+// mem_limit and cpus, like docker compose.
+func resources(service types.ServiceConfig) container.Resources {
+	r := container.Resources{
+		Memory:             int64(service.MemLimit),
+		MemoryReservation:  int64(service.MemReservation),
+		MemorySwap:         int64(service.MemSwapLimit),
+		NanoCPUs:           int64(service.CPUS * 1e9),
+		CPUShares:          service.CPUShares,
+		CPUPeriod:          service.CPUPeriod,
+		CPUQuota:           service.CPUQuota,
+		CPURealtimePeriod:  service.CPURTPeriod,
+		CPURealtimeRuntime: service.CPURTRuntime,
+		CpusetCpus:         service.CPUSet,
+		CgroupParent:       service.CgroupParent,
+		DeviceCgroupRules:  service.DeviceCgroupRules,
+	}
+	if service.MemSwappiness != 0 {
+		swappiness := int64(service.MemSwappiness)
+		r.MemorySwappiness = &swappiness
+	}
+	if service.OomKillDisable {
+		r.OomKillDisable = &service.OomKillDisable
+	}
+	if service.PidsLimit != 0 {
+		r.PidsLimit = &service.PidsLimit
+	}
+
+	if service.Deploy != nil {
+		if limits := service.Deploy.Resources.Limits; limits != nil {
+			if limits.MemoryBytes != 0 {
+				r.Memory = int64(limits.MemoryBytes)
+			}
+			if limits.NanoCPUs != 0 {
+				r.NanoCPUs = int64(float64(limits.NanoCPUs) * 1e9)
+			}
+			if limits.Pids != 0 {
+				r.PidsLimit = &limits.Pids
+			}
+		}
+		if reservations := service.Deploy.Resources.Reservations; reservations != nil {
+			if reservations.MemoryBytes != 0 {
+				r.MemoryReservation = int64(reservations.MemoryBytes)
+			}
+			r.DeviceRequests = append(r.DeviceRequests, deviceRequests(reservations.Devices, nil)...)
+		}
+	}
+	r.DeviceRequests = append(r.DeviceRequests, deviceRequests(service.Gpus, []string{"gpu"})...)
+
+	for _, d := range service.Devices {
+		permissions := d.Permissions
+		if permissions == "" {
+			permissions = "rwm"
+		}
+		r.Devices = append(r.Devices, container.DeviceMapping{
+			PathOnHost:        d.Source,
+			PathInContainer:   d.Target,
+			CgroupPermissions: permissions,
+		})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(service.Ulimits)) {
+		u := service.Ulimits[name]
+		soft, hard := u.Soft, u.Hard
+		if u.Single != 0 {
+			soft, hard = u.Single, u.Single
+		}
+		r.Ulimits = append(r.Ulimits, &container.Ulimit{Name: name, Soft: int64(soft), Hard: int64(hard)})
+	}
+	return r
+}
+
+// deviceRequests converts device requests, e.g. for GPUs. Requests without
+// capabilities get defaultCapabilities.
+func deviceRequests(requests []types.DeviceRequest, defaultCapabilities []string) []container.DeviceRequest {
+	var out []container.DeviceRequest
+	for _, request := range requests {
+		capabilities := request.Capabilities
+		if len(capabilities) == 0 {
+			capabilities = defaultCapabilities
+		}
+		out = append(out, container.DeviceRequest{
+			Driver:       request.Driver,
+			Count:        int(request.Count),
+			DeviceIDs:    request.IDs,
+			Capabilities: [][]string{capabilities},
+			Options:      request.Options,
+		})
+	}
+	return out
+}
+
+// unsupportedFields returns the compose fields set on a service that Coach
+// can't apply, so a deploy.yaml using them fails instead of starting
+// containers without them.
+func unsupportedFields(service types.ServiceConfig) []string {
+	var fields []string
+	unsupported := func(set bool, field string) {
+		if set {
+			fields = append(fields, field)
+		}
+	}
+
+	unsupported(service.BlkioConfig != nil, "blkio_config")
+	unsupported(service.CPUCount != 0, "cpu_count")
+	unsupported(service.CPUPercent != 0, "cpu_percent")
+	unsupported(service.CredentialSpec != nil, "credential_spec")
+	unsupported(len(service.Links) > 0, "links")
+	unsupported(len(service.ExternalLinks) > 0, "external_links")
+	unsupported(service.Isolation != "", "isolation")
+	unsupported(service.LogDriver != "" || len(service.LogOpt) > 0, "log_driver")
+	unsupported(service.MacAddress != "", "mac_address")
+	unsupported(len(service.Models) > 0, "models")
+	unsupported(service.Platform != "", "platform")
+	unsupported(len(service.PostStart) > 0, "post_start")
+	unsupported(len(service.PreStop) > 0, "pre_stop")
+	unsupported(service.Provider != nil, "provider")
+	unsupported(service.Scale != nil && *service.Scale != 1, "scale")
+	unsupported(service.UseAPISocket, "use_api_socket")
+	unsupported(service.VolumeDriver != "", "volume_driver")
+
+	for _, name := range slices.Sorted(maps.Keys(service.DependsOn)) {
+		condition := service.DependsOn[name].Condition
+		unsupported(condition != "" && condition != types.ServiceConditionStarted, fmt.Sprintf("depends_on.%s.condition", name))
+	}
+	for _, name := range slices.Sorted(maps.Keys(service.Networks)) {
+		cfg := service.Networks[name]
+		if cfg == nil {
+			continue
+		}
+		unsupported(len(cfg.DriverOpts) > 0, fmt.Sprintf("networks.%s.driver_opts", name))
+		unsupported(cfg.GatewayPriority != 0, fmt.Sprintf("networks.%s.gw_priority", name))
+		unsupported(cfg.InterfaceName != "", fmt.Sprintf("networks.%s.interface_name", name))
+		unsupported(len(cfg.LinkLocalIPs) > 0, fmt.Sprintf("networks.%s.link_local_ips", name))
+		unsupported(cfg.MacAddress != "", fmt.Sprintf("networks.%s.mac_address", name))
+	}
+
+	if deploy := service.Deploy; deploy != nil {
+		unsupported(deploy.Mode != "" && deploy.Mode != "replicated", "deploy.mode")
+		unsupported(deploy.Replicas != nil && *deploy.Replicas != 1, "deploy.replicas")
+		unsupported(len(deploy.Labels) > 0, "deploy.labels")
+		unsupported(deploy.UpdateConfig != nil, "deploy.update_config")
+		unsupported(deploy.RollbackConfig != nil, "deploy.rollback_config")
+		unsupported(deploy.RestartPolicy != nil, "deploy.restart_policy")
+		unsupported(len(deploy.Placement.Constraints) > 0 || len(deploy.Placement.Preferences) > 0 || deploy.Placement.MaxReplicas != 0, "deploy.placement")
+		unsupported(deploy.EndpointMode != "", "deploy.endpoint_mode")
+		if limits := deploy.Resources.Limits; limits != nil {
+			unsupported(len(limits.Devices) > 0, "deploy.resources.limits.devices")
+			unsupported(len(limits.GenericResources) > 0, "deploy.resources.limits.generic_resources")
+		}
+		if reservations := deploy.Resources.Reservations; reservations != nil {
+			unsupported(reservations.NanoCPUs != 0, "deploy.resources.reservations.cpus")
+			unsupported(reservations.Pids != 0, "deploy.resources.reservations.pids")
+			unsupported(len(reservations.GenericResources) > 0, "deploy.resources.reservations.generic_resources")
+		}
+	}
+	return fields
+}
+
+func addMounts(project *types.Project, service types.ServiceConfig, hostConfig *container.HostConfig) error {
+	for _, v := range service.Volumes {
+		switch v.Type {
+		case types.VolumeTypeBind:
+			// Binds rather than mounts, so that missing host paths such as ./data are created.
+			bind := fmt.Sprintf("%s:%s", v.Source, v.Target)
+			if v.ReadOnly {
+				bind += ":ro"
+			}
+			hostConfig.Binds = append(hostConfig.Binds, bind)
+		case types.VolumeTypeVolume:
+			source := v.Source
+			if vol, ok := project.Volumes[v.Source]; ok {
+				source = vol.Name
+			}
+			m := mount.Mount{
+				Type:     mount.TypeVolume,
+				Source:   source,
+				Target:   v.Target,
+				ReadOnly: v.ReadOnly,
+			}
+			if v.Volume != nil {
+				m.VolumeOptions = &mount.VolumeOptions{NoCopy: v.Volume.NoCopy, Subpath: v.Volume.Subpath}
+			}
+			hostConfig.Mounts = append(hostConfig.Mounts, m)
+		case types.VolumeTypeTmpfs:
+			m := mount.Mount{
+				Type:   mount.TypeTmpfs,
+				Target: v.Target,
+			}
+			if v.Tmpfs != nil {
+				m.TmpfsOptions = &mount.TmpfsOptions{SizeBytes: int64(v.Tmpfs.Size)}
+			}
+			hostConfig.Mounts = append(hostConfig.Mounts, m)
+		default:
+			return fmt.Errorf("unsupported volume type %s for %s", v.Type, v.Target)
+		}
+	}
+
+	for _, s := range service.Secrets {
+		secret, ok := project.Secrets[s.Source]
+		if !ok {
+			return fmt.Errorf("secret %s is not defined", s.Source)
+		}
+		if secret.File == "" {
+			return fmt.Errorf("secret %s: only file secrets are supported", s.Source)
+		}
+
+		target := s.Target
+		if target == "" {
+			target = "/run/secrets/" + s.Source
+		} else if !filepath.IsAbs(target) {
+			target = "/run/secrets/" + target
+		}
+		hostConfig.Binds = append(hostConfig.Binds, fmt.Sprintf("%s:%s:ro", secret.File, target))
+	}
+
+	for _, c := range service.Configs {
+		config, ok := project.Configs[c.Source]
+		if !ok {
+			return fmt.Errorf("config %s is not defined", c.Source)
+		}
+		if config.File == "" {
+			return fmt.Errorf("config %s: only file configs are supported", c.Source)
+		}
+
+		target := c.Target
+		if target == "" {
+			target = "/" + c.Source
+		}
+		hostConfig.Binds = append(hostConfig.Binds, fmt.Sprintf("%s:%s:ro", config.File, target))
+	}
+
+	return nil
+}
+
+func restartPolicy(restart string) (container.RestartPolicy, error) {
+	if restart == "" {
+		return container.RestartPolicy{Name: container.RestartPolicyDisabled}, nil
+	}
+
+	name, count, hasCount := strings.Cut(restart, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if hasCount {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return policy, fmt.Errorf("invalid restart policy %s", restart)
+		}
+		policy.MaximumRetryCount = n
+	}
+
+	if err := container.ValidateRestartPolicy(policy); err != nil {
+		return policy, fmt.Errorf("invalid restart policy %s: %w", restart, err)
+	}
+	return policy, nil
+}
+
+func healthConfig(h *types.HealthCheckConfig) *container.HealthConfig {
+	if h.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}
+	}
+
+	config := &container.HealthConfig{Test: h.Test}
+	if h.Interval != nil {
+		config.Interval = time.Duration(*h.Interval)
+	}
+	if h.Timeout != nil {
+		config.Timeout = time.Duration(*h.Timeout)
+	}
+	if h.StartPeriod != nil {
+		config.StartPeriod = time.Duration(*h.StartPeriod)
+	}
+	if h.StartInterval != nil {
+		config.StartInterval = time.Duration(*h.StartInterval)
+	}
+	if h.Retries != nil {
+		config.Retries = int(*h.Retries)
+	}
+	return config
+}
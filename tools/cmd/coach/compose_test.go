@@ -0,0 +1,538 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// fakeDocker is an in-memory daemon with the parts of the Docker API that Up
+// and Plan use. Calling anything else panics on the nil embedded client.
+type fakeDocker struct {
+	client.APIClient
+
+	images     map[string]image.InspectResponse
+	networks   map[string]bool
+	containers map[string]*fakeContainer
+	nextID     int
+
+	// created and removed are the names of the containers created and removed, in order.
+	created []string
+	removed []string
+}
+
+type fakeContainer struct {
+	id      string
+	name    string
+	config  *container.Config
+	imageID string
+	state   container.ContainerState
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		images:     make(map[string]image.InspectResponse),
+		networks:   make(map[string]bool),
+		containers: make(map[string]*fakeContainer),
+	}
+}
+
+func (d *fakeDocker) ImageInspect(ctx context.Context, ref string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+	img, ok := d.images[ref]
+	if !ok {
+		return image.InspectResponse{}, errdefs.NotFound(fmt.Errorf("no such image: %s", ref))
+	}
+	return img, nil
+}
+
+func (d *fakeDocker) NetworkInspect(ctx context.Context, name string, options network.InspectOptions) (network.Inspect, error) {
+	if !d.networks[name] {
+		return network.Inspect{}, errdefs.NotFound(fmt.Errorf("network %s not found", name))
+	}
+	return network.Inspect{Name: name}, nil
+}
+
+func (d *fakeDocker) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	d.networks[name] = true
+	return network.CreateResponse{ID: name}, nil
+}
+
+func (d *fakeDocker) NetworkConnect(ctx context.Context, name, id string, config *network.EndpointSettings) error {
+	if !d.networks[name] {
+		return errdefs.NotFound(fmt.Errorf("network %s not found", name))
+	}
+	return nil
+}
+
+func (d *fakeDocker) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	var containers []container.Summary
+	for _, c := range d.containers {
+		if !hasLabels(c.config.Labels, options.Filters.Get("label")) {
+			continue
+		}
+		containers = append(containers, container.Summary{
+			ID:     c.id,
+			Names:  []string{"/" + c.name},
+			Labels: c.config.Labels,
+			State:  c.state,
+		})
+	}
+	return containers, nil
+}
+
+func hasLabels(labels map[string]string, filters []string) bool {
+	for _, f := range filters {
+		k, v, _ := strings.Cut(f, "=")
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *fakeDocker) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, name string) (container.CreateResponse, error) {
+	for _, c := range d.containers {
+		if c.name == name {
+			return container.CreateResponse{}, errdefs.Conflict(fmt.Errorf("container name %s is already in use", name))
+		}
+	}
+	img, ok := d.images[config.Image]
+	if !ok {
+		return container.CreateResponse{}, errdefs.NotFound(fmt.Errorf("no such image: %s", config.Image))
+	}
+
+	d.nextID++
+	c := &fakeContainer{
+		id:      fmt.Sprintf("container-%d", d.nextID),
+		name:    name,
+		config:  config,
+		imageID: img.ID,
+		state:   container.StateCreated,
+	}
+	d.containers[c.id] = c
+	d.created = append(d.created, name)
+	return container.CreateResponse{ID: c.id}, nil
+}
+
+func (d *fakeDocker) ContainerStart(ctx context.Context, id string, options container.StartOptions) error {
+	c, err := d.container(id)
+	if err != nil {
+		return err
+	}
+	c.state = container.StateRunning
+	return nil
+}
+
+func (d *fakeDocker) ContainerStop(ctx context.Context, id string, options container.StopOptions) error {
+	c, err := d.container(id)
+	if err != nil {
+		return err
+	}
+	c.state = container.StateExited
+	return nil
+}
+
+func (d *fakeDocker) ContainerRemove(ctx context.Context, id string, options container.RemoveOptions) error {
+	c, err := d.container(id)
+	if err != nil {
+		return err
+	}
+	delete(d.containers, id)
+	d.removed = append(d.removed, c.name)
+	return nil
+}
+
+func (d *fakeDocker) ContainerInspect(ctx context.Context, id string) (container.InspectResponse, error) {
+	c, err := d.container(id)
+	if err != nil {
+		return container.InspectResponse{}, err
+	}
+	return container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:    c.id,
+			Name:  "/" + c.name,
+			Image: c.imageID,
+			State: &container.State{Status: c.state},
+		},
+		Config: c.config,
+	}, nil
+}
+
+func (d *fakeDocker) container(id string) (*fakeContainer, error) {
+	c, ok := d.containers[id]
+	if !ok {
+		return nil, errdefs.NotFound(fmt.Errorf("no such container: %s", id))
+	}
+	return c, nil
+}
+
+// only returns the daemon's only container.
+func (d *fakeDocker) only(t *testing.T) *fakeContainer {
+	t.Helper()
+	if len(d.containers) != 1 {
+		t.Fatalf("got %d containers, want 1", len(d.containers))
+	}
+	for _, c := range d.containers {
+		return c
+	}
+	return nil
+}
+
+const testDeployFile = `services:
+  web:
+    image: ghcr.io/baely/web:latest
+    pull_policy: never
+    environment:
+      GREETING: %s
+`
+
+// testProject writes a deploy file into dir whose container has GREETING set
+// to greeting and loads it as a project named web. Every deploy of a service
+// loads it from the same directory, so tests do too.
+func testProject(t *testing.T, dir, greeting string) *types.Project {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, deployFileName), []byte(fmt.Sprintf(testDeployFile, greeting)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := loadProject(context.Background(), dir, cli.WithName("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+func newTestEngine() (*dockerEngine, *fakeDocker) {
+	d := newFakeDocker()
+	d.images["ghcr.io/baely/web:latest"] = image.InspectResponse{ID: "sha256:aaa"}
+	return &dockerEngine{client: d, onPullProgress: func(pullProgress) {}}, d
+}
+
+// upAction brings the project up and returns what happened to its only container.
+func upAction(t *testing.T, e *dockerEngine, project *types.Project) containerAction {
+	t.Helper()
+	result, err := e.Up(context.Background(), project)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(result.Containers) != 1 {
+		t.Fatalf("Up returned %d containers, want 1", len(result.Containers))
+	}
+	return result.Containers[0].Action
+}
+
+// planAction plans the project and returns what would happen to its only container.
+func planAction(t *testing.T, e *dockerEngine, project *types.Project) containerAction {
+	t.Helper()
+	result, err := e.Plan(context.Background(), project)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(result.Containers) != 1 {
+		t.Fatalf("Plan returned %d containers, want 1", len(result.Containers))
+	}
+	return result.Containers[0].Action
+}
+
+func TestUpCreatesContainer(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	project := testProject(t, dir, "hello")
+
+	if got := planAction(t, e, project); got != containerCreated {
+		t.Errorf("planned %s, want %s", got, containerCreated)
+	}
+	if got := upAction(t, e, project); got != containerCreated {
+		t.Errorf("got %s, want %s", got, containerCreated)
+	}
+
+	c := d.only(t)
+	if c.name != "web-web-1" {
+		t.Errorf("container is named %s, want web-web-1", c.name)
+	}
+	if c.state != container.StateRunning {
+		t.Errorf("container is %s, want %s", c.state, container.StateRunning)
+	}
+	if c.config.Labels[labelConfigHash] == "" {
+		t.Errorf("container has no %s label", labelConfigHash)
+	}
+	if !d.networks["web_default"] {
+		t.Errorf("network web_default wasn't created")
+	}
+}
+
+func TestUpLeavesUpToDateContainer(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	upAction(t, e, testProject(t, dir, "hello"))
+	id := d.only(t).id
+
+	project := testProject(t, dir, "hello")
+	if got := planAction(t, e, project); got != containerUnchanged {
+		t.Errorf("planned %s, want %s", got, containerUnchanged)
+	}
+	if got := upAction(t, e, project); got != containerUnchanged {
+		t.Errorf("got %s, want %s", got, containerUnchanged)
+	}
+	if got := d.only(t).id; got != id {
+		t.Errorf("container was replaced by %s", got)
+	}
+	if len(d.created) != 1 || len(d.removed) != 0 {
+		t.Errorf("created %v and removed %v, want only the first container created", d.created, d.removed)
+	}
+}
+
+func TestUpStartsStoppedContainer(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	upAction(t, e, testProject(t, dir, "hello"))
+	c := d.only(t)
+	c.state = container.StateExited
+
+	project := testProject(t, dir, "hello")
+	if got := planAction(t, e, project); got != containerStarted {
+		t.Errorf("planned %s, want %s", got, containerStarted)
+	}
+	if got := upAction(t, e, project); got != containerStarted {
+		t.Errorf("got %s, want %s", got, containerStarted)
+	}
+	if got := d.only(t); got.id != c.id || got.state != container.StateRunning {
+		t.Errorf("container %s is %s, want %s running", got.id, got.state, c.id)
+	}
+}
+
+func TestUpRecreatesChangedContainer(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	upAction(t, e, testProject(t, dir, "hello"))
+	old := d.only(t)
+
+	project := testProject(t, dir, "goodbye")
+	if got := planAction(t, e, project); got != containerRecreated {
+		t.Errorf("planned %s, want %s", got, containerRecreated)
+	}
+	if got := upAction(t, e, project); got != containerRecreated {
+		t.Errorf("got %s, want %s", got, containerRecreated)
+	}
+
+	c := d.only(t)
+	if c.id == old.id {
+		t.Fatalf("container %s wasn't replaced", c.id)
+	}
+	if c.config.Labels[labelConfigHash] == old.config.Labels[labelConfigHash] {
+		t.Errorf("recreated container kept the old config hash")
+	}
+	if !slices.Contains(c.config.Env, "GREETING=goodbye") {
+		t.Errorf("recreated container has env %v, want GREETING=goodbye", c.config.Env)
+	}
+}
+
+func TestUpRecreatesContainerWithDriftedConfigHash(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	upAction(t, e, testProject(t, dir, "hello"))
+	old := d.only(t)
+	hash := old.config.Labels[labelConfigHash]
+	// The container was changed outside Coach, e.g. recreated by hand with
+	// another config, so its hash no longer matches the service's.
+	old.config.Labels[labelConfigHash] = "drifted"
+
+	project := testProject(t, dir, "hello")
+	if got := planAction(t, e, project); got != containerRecreated {
+		t.Errorf("planned %s, want %s", got, containerRecreated)
+	}
+	if got := upAction(t, e, project); got != containerRecreated {
+		t.Errorf("got %s, want %s", got, containerRecreated)
+	}
+
+	c := d.only(t)
+	if c.id == old.id {
+		t.Fatalf("container %s wasn't replaced", c.id)
+	}
+	if got := c.config.Labels[labelConfigHash]; got != hash {
+		t.Errorf("recreated container has config hash %s, want %s", got, hash)
+	}
+}
+
+func TestUpRecreatesContainerWhenImageChanges(t *testing.T) {
+	e, d := newTestEngine()
+	dir := t.TempDir()
+	upAction(t, e, testProject(t, dir, "hello"))
+	old := d.only(t)
+	d.images["ghcr.io/baely/web:latest"] = image.InspectResponse{ID: "sha256:bbb"}
+
+	if got := upAction(t, e, testProject(t, dir, "hello")); got != containerRecreated {
+		t.Errorf("got %s, want %s", got, containerRecreated)
+	}
+	c := d.only(t)
+	if c.id == old.id || c.imageID != "sha256:bbb" {
+		t.Errorf("container %s runs %s, want a new container running sha256:bbb", c.id, c.imageID)
+	}
+}
+
+// loadTestProject loads a deploy file for a project named web.
+func loadTestProject(t *testing.T, deployFile string) *types.Project {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, deployFileName), []byte(deployFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := loadProject(context.Background(), dir, cli.WithName("web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+func TestNewContainerSpecResources(t *testing.T) {
+	project := loadTestProject(t, `services:
+  web:
+    image: ghcr.io/baely/web:latest
+    shm_size: 64m
+    pid: service:db
+    ipc: host
+    group_add: [video]
+    volumes_from: [db:ro, container:backup]
+    devices:
+      - /dev/dri:/dev/dri
+    ulimits:
+      nofile:
+        soft: 1024
+        hard: 2048
+      nproc: 512
+    deploy:
+      resources:
+        limits:
+          cpus: '0.5'
+          memory: 512m
+          pids: 100
+        reservations:
+          memory: 128m
+  db:
+    image: ghcr.io/baely/db:latest
+`)
+
+	spec, err := newContainerSpec(project, project.Services["web"])
+	if err != nil {
+		t.Fatalf("newContainerSpec: %v", err)
+	}
+	hc := spec.hostConfig
+	if hc.Memory != 512<<20 {
+		t.Errorf("memory limit is %d, want 512m", hc.Memory)
+	}
+	if hc.MemoryReservation != 128<<20 {
+		t.Errorf("memory reservation is %d, want 128m", hc.MemoryReservation)
+	}
+	if hc.NanoCPUs != 5e8 {
+		t.Errorf("NanoCPUs is %d, want 5e8", hc.NanoCPUs)
+	}
+	if hc.PidsLimit == nil || *hc.PidsLimit != 100 {
+		t.Errorf("pids limit is %v, want 100", hc.PidsLimit)
+	}
+	if hc.ShmSize != 64<<20 {
+		t.Errorf("shm size is %d, want 64m", hc.ShmSize)
+	}
+	if hc.PidMode != "container:web-db-1" {
+		t.Errorf("pid mode is %s, want container:web-db-1", hc.PidMode)
+	}
+	if hc.IpcMode != "host" {
+		t.Errorf("ipc mode is %s, want host", hc.IpcMode)
+	}
+	if !slices.Equal(hc.GroupAdd, []string{"video"}) {
+		t.Errorf("group_add is %v, want [video]", hc.GroupAdd)
+	}
+	if want := []string{"web-db-1:ro", "backup"}; !slices.Equal(hc.VolumesFrom, want) {
+		t.Errorf("volumes_from is %v, want %v", hc.VolumesFrom, want)
+	}
+	if len(hc.Devices) != 1 || hc.Devices[0] != (container.DeviceMapping{PathOnHost: "/dev/dri", PathInContainer: "/dev/dri", CgroupPermissions: "rwm"}) {
+		t.Errorf("devices are %+v, want /dev/dri", hc.Devices)
+	}
+	var ulimits []string
+	for _, u := range hc.Ulimits {
+		ulimits = append(ulimits, u.String())
+	}
+	if want := []string{"nofile=1024:2048", "nproc=512:512"}; !slices.Equal(ulimits, want) {
+		t.Errorf("ulimits are %v, want %v", ulimits, want)
+	}
+}
+
+func TestNewContainerSpecTopLevelLimits(t *testing.T) {
+	project := loadTestProject(t, `services:
+  web:
+    image: ghcr.io/baely/web:latest
+    mem_limit: 256m
+    cpus: 1.5
+    pids_limit: 50
+`)
+
+	spec, err := newContainerSpec(project, project.Services["web"])
+	if err != nil {
+		t.Fatalf("newContainerSpec: %v", err)
+	}
+	hc := spec.hostConfig
+	if hc.Memory != 256<<20 || hc.NanoCPUs != 15e8 || hc.PidsLimit == nil || *hc.PidsLimit != 50 {
+		t.Errorf("got memory %d, NanoCPUs %d and pids limit %v, want 256m, 1.5 CPUs and 50", hc.Memory, hc.NanoCPUs, hc.PidsLimit)
+	}
+}
+
+func TestUnsupportedFields(t *testing.T) {
+	tests := []struct {
+		service string
+		want    []string
+	}{
+		{"    restart: always\n    mem_limit: 1g\n", nil},
+		{"    depends_on:\n      db:\n        condition: service_started\n", nil},
+		{"    depends_on:\n      db:\n        condition: service_healthy\n", []string{"depends_on.db.condition"}},
+		{"    links: [db]\n", []string{"links"}},
+		{"    platform: linux/arm64\n", []string{"platform"}},
+		{"    deploy:\n      replicas: 2\n", []string{"deploy.replicas"}},
+		{"    deploy:\n      restart_policy:\n        condition: on-failure\n", []string{"deploy.restart_policy"}},
+		{"    deploy:\n      resources:\n        reservations:\n          cpus: '0.5'\n", []string{"deploy.resources.reservations.cpus"}},
+		{"    networks:\n      default:\n        mac_address: 02:42:ac:11:65:43\n", []string{"networks.default.mac_address"}},
+	}
+
+	for _, tt := range tests {
+		project := loadTestProject(t, "services:\n  web:\n    image: ghcr.io/baely/web:latest\n"+tt.service+"  db:\n    image: ghcr.io/baely/db:latest\n")
+		service := project.Services["web"]
+		if got := unsupportedFields(service); !slices.Equal(got, tt.want) {
+			t.Errorf("unsupportedFields(%q) = %v, want %v", tt.service, got, tt.want)
+		}
+		_, err := newContainerSpec(project, service)
+		if (err != nil) != (len(tt.want) > 0) {
+			t.Errorf("newContainerSpec(%q) returned %v", tt.service, err)
+		}
+	}
+}
+
+// TestRepoDeployFilesAreSupported makes sure every service in the infra repo
+// only uses compose fields Coach applies.
+func TestRepoDeployFilesAreSupported(t *testing.T) {
+	dirs, err := filepath.Glob(filepath.Join("..", "..", "..", "docker", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		project, err := loadProject(context.Background(), dir, cli.WithoutEnvironmentResolution)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(dir), err)
+			continue
+		}
+		for _, name := range project.ServiceNames() {
+			if fields := unsupportedFields(project.Services[name]); len(fields) > 0 {
+				t.Errorf("%s: service %s uses unsupported compose fields %v", filepath.Base(dir), name, fields)
+			}
+		}
+	}
+}
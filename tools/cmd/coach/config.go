@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// defaultEnvironment is the environment of deploys that don't name one, unless
+// the config file sets another.
+const defaultEnvironment = "prod"
+
+// environmentNamePattern is the names environments can have. They are used in
+// directory and compose project names.
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// config is Coach's own configuration, read from the file named by COACH_CONFIG.
+// Everything in it is optional; without it every service is deployed to the
+// local docker daemon.
+type config struct {
+	// Runtimes are the backends on this host services can be deployed to, keyed by name.
+	Runtimes map[string]runtimeConfig `yaml:"runtimes"`
+	// Hosts are remote machines services can be deployed to, keyed by name.
+	Hosts map[string]hostConfig `yaml:"hosts"`
+	// Services holds per-service settings, keyed by service name (e.g. github.com_baely_blog).
+	Services map[string]serviceConfig `yaml:"services"`
+	// Environments holds per-environment settings, keyed by environment name (e.g. stage).
+	Environments map[string]environmentConfig `yaml:"environments"`
+	// Environment is the environment of deploys that don't name one (default: prod).
+	Environment string `yaml:"environment"`
+	// AllowedRegistries are the registries service images may be pulled from
+	// (default: registry.baileys.dev).
+	AllowedRegistries []string `yaml:"allowed_registries"`
+	// Previews configures pull request previews.
+	Previews previewConfig `yaml:"previews"`
+	// GC is the retention policy of garbage collection.
+	GC gcConfig `yaml:"gc"`
+}
+
+func (c *config) environment() string {
+	if c.Environment == "" {
+		return defaultEnvironment
+	}
+	return c.Environment
+}
+
+// hasEnvironment reports whether services can be deployed to the environment:
+// the default environment, or one listed under environments.
+func (c *config) hasEnvironment(name string) bool {
+	if name == c.environment() {
+		return true
+	}
+	_, ok := c.Environments[name]
+	return ok
+}
+
+// allowedRegistries returns the registries images may be pulled from in an environment.
+func (c *config) allowedRegistries(environment string) []string {
+	if registries := c.Environments[environment].AllowedRegistries; len(registries) > 0 {
+		return registries
+	}
+	if len(c.AllowedRegistries) == 0 {
+		return defaultAllowedRegistries
+	}
+	return c.AllowedRegistries
+}
+
+type environmentConfig struct {
+	// Host is the name of the host services in the environment are deployed to.
+	// It takes precedence over each service's configured host.
+	Host string `yaml:"host"`
+	// Domain is passed to templates as .domain, e.g. stage.baileys.dev.
+	Domain string `yaml:"domain"`
+	// AllowedRegistries overrides the top-level allowed_registries for the environment.
+	AllowedRegistries []string `yaml:"allowed_registries"`
+}
+
+type previewConfig struct {
+	// Environment is the environment whose host, domain and registries previews
+	// use (default: the default environment).
+	Environment string `yaml:"environment"`
+	// TTL is how long a preview lives unless it is created with its own TTL (default: 72h).
+	TTL time.Duration `yaml:"ttl"`
+}
+
+func (c *config) previewEnvironment() string {
+	if c.Previews.Environment == "" {
+		return c.environment()
+	}
+	return c.Previews.Environment
+}
+
+func (p previewConfig) ttl() time.Duration {
+	if p.TTL == 0 {
+		return defaultPreviewTTL
+	}
+	return p.TTL
+}
+
+type gcConfig struct {
+	// Interval is how often GC runs in the background (default: 24h). A
+	// negative interval only runs it at startup and on request.
+	Interval time.Duration `yaml:"interval"`
+	// KeepImages is how many of the newest images of each repository are
+	// kept for rollbacks even when no container uses them (default: 3).
+	KeepImages int `yaml:"keep_images"`
+	// MaxImageAge is how old an unused image has to be before it is removed (default: 168h).
+	MaxImageAge time.Duration `yaml:"max_image_age"`
+	// MaxBuildCacheAge is how long build cache is kept after it was last used (default: 168h).
+	MaxBuildCacheAge time.Duration `yaml:"max_build_cache_age"`
+	// MaxTempDirAge is how old a temp or staging directory has to be before it
+	// is removed. It must be longer than any build or deploy (default: 6h).
+	MaxTempDirAge time.Duration `yaml:"max_temp_dir_age"`
+}
+
+func (g gcConfig) interval() time.Duration {
+	if g.Interval == 0 {
+		return defaultGCInterval
+	}
+	return g.Interval
+}
+
+func (g gcConfig) keepImages() int {
+	if g.KeepImages == 0 {
+		return defaultKeepImages
+	}
+	return g.KeepImages
+}
+
+func (g gcConfig) maxImageAge() time.Duration {
+	if g.MaxImageAge == 0 {
+		return defaultMaxImageAge
+	}
+	return g.MaxImageAge
+}
+
+func (g gcConfig) maxBuildCacheAge() time.Duration {
+	if g.MaxBuildCacheAge == 0 {
+		return defaultMaxBuildCacheAge
+	}
+	return g.MaxBuildCacheAge
+}
+
+func (g gcConfig) maxTempDirAge() time.Duration {
+	if g.MaxTempDirAge == 0 {
+		return defaultMaxTempDirAge
+	}
+	return g.MaxTempDirAge
+}
+
+type runtimeConfig struct {
+	// Type is the kind of backend: docker or podman.
+	Type string `yaml:"type"`
+	// Host is the daemon socket, e.g. unix:///run/user/1000/podman/podman.sock.
+	// It defaults to the standard socket for the runtime type.
+	Host string `yaml:"host"`
+}
+
+type hostConfig struct {
+	// Type is the kind of daemon on the host: docker (default) or podman.
+	Type string `yaml:"type"`
+	// Address is the daemon address, either ssh://user@host or tcp://host:port.
+	Address string `yaml:"address"`
+	// IdentityFile is the ssh private key used for ssh:// addresses.
+	IdentityFile string `yaml:"identity_file"`
+	// TLS holds client certificates for tcp:// addresses.
+	TLS *tlsConfig `yaml:"tls"`
+}
+
+type tlsConfig struct {
+	CA   string `yaml:"ca"`
+	Cert string `yaml:"cert"`
+	Key  string `yaml:"key"`
+}
+
+func (h hostConfig) runtimeType() string {
+	if h.Type == "" {
+		return runtimeDocker
+	}
+	return h.Type
+}
+
+type serviceConfig struct {
+	// Runtime is the name of the runtime the service is deployed to (default: docker).
+	// It only applies to services deployed to the local host.
+	Runtime string `yaml:"runtime"`
+	// Host is the name of the host the service is deployed to (default: local).
+	Host string `yaml:"host"`
+	// Strategy is how new containers replace old ones: recreate (default) or blue-green.
+	Strategy string `yaml:"strategy"`
+	// HealthTimeout is how long blue-green deploys wait for new containers to become healthy.
+	HealthTimeout time.Duration `yaml:"health_timeout"`
+	// DrainPeriod is how long blue-green deploys keep the old containers running
+	// after the new ones are healthy, giving traefik time to route to them.
+	DrainPeriod time.Duration `yaml:"drain_period"`
+}
+
+func (s serviceConfig) strategy() string {
+	if s.Strategy == "" {
+		return strategyRecreate
+	}
+	return s.Strategy
+}
+
+func (s serviceConfig) healthTimeout() time.Duration {
+	if s.HealthTimeout == 0 {
+		return defaultHealthTimeout
+	}
+	return s.HealthTimeout
+}
+
+func (s serviceConfig) drainPeriod() time.Duration {
+	if s.DrainPeriod == 0 {
+		return defaultDrainPeriod
+	}
+	return s.DrainPeriod
+}
+
+func loadConfig(filename string) (*config, error) {
+	c := &config{}
+	if filename == "" {
+		return c, nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *config) validate() error {
+	for name, r := range c.Runtimes {
+		switch r.Type {
+		case runtimeDocker, runtimePodman:
+		default:
+			return fmt.Errorf("runtime %s: unknown type %q", name, r.Type)
+		}
+	}
+
+	for name, h := range c.Hosts {
+		if name == localHost {
+			return fmt.Errorf("host %s: name is reserved for the local host", name)
+		}
+		switch h.runtimeType() {
+		case runtimeDocker, runtimePodman:
+		default:
+			return fmt.Errorf("host %s: unknown type %q", name, h.Type)
+		}
+		if h.Address == "" {
+			return fmt.Errorf("host %s: address is required", name)
+		}
+	}
+
+	if !environmentNamePattern.MatchString(c.environment()) {
+		return fmt.Errorf("invalid environment name %q", c.environment())
+	}
+	for name, e := range c.Environments {
+		if !environmentNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment name %q", name)
+		}
+		if e.Host != "" && e.Host != localHost {
+			if _, ok := c.Hosts[e.Host]; !ok {
+				return fmt.Errorf("environment %s: unknown host %q", name, e.Host)
+			}
+		}
+	}
+
+	if !c.hasEnvironment(c.previewEnvironment()) {
+		return fmt.Errorf("previews: unknown environment %q", c.Previews.Environment)
+	}
+
+	if c.GC.KeepImages < 0 {
+		return fmt.Errorf("gc: keep_images can't be negative")
+	}
+	if c.GC.MaxImageAge < 0 || c.GC.MaxBuildCacheAge < 0 || c.GC.MaxTempDirAge < 0 {
+		return fmt.Errorf("gc: ages can't be negative")
+	}
+
+	for name, s := range c.Services {
+		switch s.strategy() {
+		case strategyRecreate, strategyBlueGreen:
+		default:
+			return fmt.Errorf("service %s: unknown strategy %q", name, s.Strategy)
+		}
+		if s.Host != "" && s.Host != localHost {
+			if _, ok := c.Hosts[s.Host]; !ok {
+				return fmt.Errorf("service %s: unknown host %q", name, s.Host)
+			}
+			if s.Runtime != "" {
+				return fmt.Errorf("service %s: runtime can only be set for services on the local host", name)
+			}
+		}
+		if s.Runtime == "" || s.Runtime == runtimeDocker {
+			continue
+		}
+		if _, ok := c.Runtimes[s.Runtime]; !ok {
+			return fmt.Errorf("service %s: unknown runtime %q", name, s.Runtime)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/distribution/reference"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/moby/go-archive"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// engine is the container engine Coach assembles images with. It is also the
+// default runtime services are deployed to. Everything Assemble and Start need
+// from Docker goes through it, so both flows can be exercised against a fake
+// instead of a daemon.
+type engine interface {
+	runtime
+
+	// Build builds an image from a local build context and optionally pushes it.
+	Build(ctx context.Context, opts buildOptions) (*buildResult, error)
+	// RemoteDigest returns the digest the registry currently has for an image.
+	RemoteDigest(ctx context.Context, ref string) (string, error)
+	// PruneBuildCache removes build cache that hasn't been used for maxAge.
+	// It returns nil if there was nothing to remove.
+	PruneBuildCache(ctx context.Context, maxAge time.Duration, dryRun bool) (*gcItem, error)
+}
+
+type buildOptions struct {
+	// ContextDir is the absolute path of the build context.
+	ContextDir string
+	// Dockerfile is the absolute path of the Dockerfile, which must be inside ContextDir.
+	Dockerfile string
+	Tag        string
+	Platform   string
+	Push       bool
+}
+
+type buildResult struct {
+	Image   string
+	ImageID string
+	// Digest is the registry digest of the pushed image, if it was pushed.
+	Digest string
+}
+
+// buildError is returned when the daemon reports a failure part way through a build or push.
+type buildError struct {
+	Message string
+	// Log holds the tail of the build output leading up to the failure.
+	Log []string
+}
+
+func (e *buildError) Error() string {
+	return e.Message
+}
+
+type pullResult struct {
+	Images []pulledImage
+}
+
+type pulledImage struct {
+	Service string
+	Image   string
+	ImageID string
+	Digest  string
+	// Status is the final status reported by the daemon, e.g. "Image is up to date for ...".
+	Status string
+}
+
+// pullProgress is a single progress update for one layer of an image being pulled.
+type pullProgress struct {
+	Image   string
+	Layer   string
+	Status  string
+	Current int64
+	Total   int64
+}
+
+type upResult struct {
+	Containers []containerState
+}
+
+type containerAction string
+
+const (
+	containerCreated   containerAction = "created"
+	containerRecreated containerAction = "recreated"
+	containerStarted   containerAction = "started"
+	containerUnchanged containerAction = "unchanged"
+	// containerRemoved is only planned, for the containers a blue/green deploy replaces.
+	containerRemoved containerAction = "removed"
+)
+
+type containerState struct {
+	Service string
+	ID      string
+	Name    string
+	Image   string
+	ImageID string
+	Digest  string
+	// State is the docker container state, e.g. "running" or "exited".
+	State  string
+	Health string
+	Action containerAction
+}
+
+// buildLogLines is how much build output is kept for a buildError.
+const buildLogLines = 20
+
+// dockerEngine implements engine against the Docker Engine API, or anything that
+// speaks it such as podman's compatibility socket.
+type dockerEngine struct {
+	// client is the daemon's API. It is an interface so tests can run the
+	// engine against an in-memory daemon.
+	client client.APIClient
+	// onPullProgress is called for every layer status change while pulling images.
+	onPullProgress func(pullProgress)
+}
+
+func newDockerEngine(opts ...client.Opt) (*dockerEngine, error) {
+	opts = append([]client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}, opts...)
+	c, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+
+	return &dockerEngine{
+		client:         c,
+		onPullProgress: logPullProgress,
+	}, nil
+}
+
+// labelAssembled marks the images Coach builds, so GC can tell them apart
+// from images it didn't create.
+const labelAssembled = "baileys.coach.assembled"
+
+func (e *dockerEngine) Build(ctx context.Context, opts buildOptions) (*buildResult, error) {
+	dockerfile, err := filepath.Rel(opts.ContextDir, opts.Dockerfile)
+	if err != nil || strings.HasPrefix(dockerfile, "..") {
+		return nil, fmt.Errorf("dockerfile %s is not inside build context %s", opts.Dockerfile, opts.ContextDir)
+	}
+
+	excludes, err := readDockerignore(opts.ContextDir)
+	if err != nil {
+		return nil, err
+	}
+
+	buildContext, err := archive.TarWithOptions(opts.ContextDir, &archive.TarOptions{
+		ExcludePatterns: excludes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive build context: %w", err)
+	}
+	defer buildContext.Close()
+
+	log.Printf("Building image %s from %s", opts.Tag, opts.ContextDir)
+	resp, err := e.client.ImageBuild(ctx, buildContext, build.ImageBuildOptions{
+		Tags:       []string{opts.Tag},
+		Labels:     map[string]string{labelAssembled: "true"},
+		Dockerfile: filepath.ToSlash(dockerfile),
+		Platform:   opts.Platform,
+		Version:    build.BuilderBuildKit,
+		Remove:     true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start build: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := &buildResult{Image: opts.Tag}
+	var output []string
+	err = readJSONMessages(resp.Body, func(msg jsonmessage.JSONMessage) error {
+		if msg.Stream != "" {
+			output = appendTail(output, strings.TrimRight(msg.Stream, "\n"), buildLogLines)
+		}
+		if msg.Aux != nil && msg.ID == "moby.image.id" {
+			var r build.Result
+			if err := json.Unmarshal(*msg.Aux, &r); err == nil {
+				result.ImageID = r.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &buildError{Message: err.Error(), Log: output}
+	}
+	log.Printf("Built image %s (%s)", opts.Tag, result.ImageID)
+
+	if !opts.Push {
+		return result, nil
+	}
+
+	auth, err := registryAuth(opts.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Pushing image %s", opts.Tag)
+	pushResp, err := e.client.ImagePush(ctx, opts.Tag, image.PushOptions{RegistryAuth: auth})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start push: %w", err)
+	}
+	defer pushResp.Close()
+
+	err = readJSONMessages(pushResp, func(msg jsonmessage.JSONMessage) error {
+		if msg.Aux != nil {
+			var r dockertypes.PushResult
+			if err := json.Unmarshal(*msg.Aux, &r); err == nil && r.Digest != "" {
+				result.Digest = r.Digest
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, &buildError{Message: fmt.Sprintf("push failed: %v", err)}
+	}
+	log.Printf("Pushed image %s (%s)", opts.Tag, result.Digest)
+
+	return result, nil
+}
+
+func (e *dockerEngine) Pull(ctx context.Context, project *types.Project) (*pullResult, error) {
+	result := &pullResult{}
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		if service.Image == "" || service.PullPolicy == types.PullPolicyNever || service.PullPolicy == types.PullPolicyBuild {
+			continue
+		}
+
+		pulled, err := e.pullImage(ctx, service.Image)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull image for service %s: %w", name, err)
+		}
+		pulled.Service = name
+		result.Images = append(result.Images, *pulled)
+	}
+	return result, nil
+}
+
+func (e *dockerEngine) pullImage(ctx context.Context, ref string) (*pulledImage, error) {
+	auth, err := registryAuth(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Pulling image %s", ref)
+	resp, err := e.client.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: auth})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	pulled := &pulledImage{Image: ref}
+	layers := make(map[string]string)
+	err = readJSONMessages(resp, func(msg jsonmessage.JSONMessage) error {
+		if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+			if msg.Status != "" {
+				pulled.Status = msg.Status
+			}
+			return nil
+		}
+
+		// Only report a layer when its status changes, not on every byte downloaded.
+		if layers[msg.ID] == msg.Status {
+			return nil
+		}
+		layers[msg.ID] = msg.Status
+
+		progress := pullProgress{Image: ref, Layer: msg.ID, Status: msg.Status}
+		if msg.Progress != nil {
+			progress.Current = msg.Progress.Current
+			progress.Total = msg.Progress.Total
+		}
+		if e.onPullProgress != nil {
+			e.onPullProgress(progress)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := e.client.ImageInspect(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image: %w", err)
+	}
+	pulled.ImageID = inspect.ID
+	pulled.Digest = repoDigest(ref, inspect.RepoDigests)
+
+	log.Printf("Pulled image %s (%s)", ref, pulled.Digest)
+	return pulled, nil
+}
+
+func logPullProgress(p pullProgress) {
+	log.Printf("Pull %s: %s %s", p.Image, p.Layer, p.Status)
+}
+
+// readJSONMessages decodes a stream of daemon progress messages, calling fn for each one.
+// An error reported by the daemon in the stream is returned as an error.
+func readJSONMessages(r io.Reader, fn func(jsonmessage.JSONMessage) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to decode daemon response: %w", err)
+		}
+
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func appendTail(lines []string, line string, n int) []string {
+	lines = append(lines, line)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// repoDigest picks the digest of ref's repository out of an image's repo digests.
+func repoDigest(ref string, repoDigests []string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+
+	for _, rd := range repoDigests {
+		digested, err := reference.ParseNormalizedNamed(rd)
+		if err != nil || digested.Name() != named.Name() {
+			continue
+		}
+		if canonical, ok := digested.(reference.Canonical); ok {
+			return canonical.Digest().String()
+		}
+	}
+	return ""
+}
+
+func readDockerignore(contextDir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open .dockerignore: %w", err)
+	}
+	defer f.Close()
+
+	excludes, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	return excludes, nil
+}
+
+// registryAuth returns the encoded credentials for ref's registry from the docker CLI
+// config file, the same place `docker` itself would have looked them up.
+func registryAuth(ref string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %s: %w", ref, err)
+	}
+	domain := reference.Domain(named)
+
+	configDir := os.Getenv("DOCKER_CONFIG")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		configDir = filepath.Join(home, ".docker")
+	}
+
+	b, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read docker config: %w", err)
+	}
+
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return "", fmt.Errorf("failed to parse docker config: %w", err)
+	}
+
+	entry, ok := config.Auths[domain]
+	if !ok || entry.Auth == "" {
+		return "", nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return "", fmt.Errorf("invalid auth for registry %s: %w", domain, err)
+	}
+	username, password, _ := strings.Cut(string(decoded), ":")
+
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      username,
+		Password:      password,
+		ServerAddress: domain,
+	})
+}
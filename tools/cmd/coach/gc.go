@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/build"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+)
+
+const (
+	defaultGCInterval       = 24 * time.Hour
+	defaultKeepImages       = 3
+	defaultMaxImageAge      = 7 * 24 * time.Hour
+	defaultMaxBuildCacheAge = 7 * 24 * time.Hour
+	defaultMaxTempDirAge    = 6 * time.Hour
+)
+
+// Kinds of things GC removes.
+const (
+	gcImage      = "image"
+	gcBuildCache = "build_cache"
+	gcNetwork    = "network"
+	gcVolume     = "volume"
+	gcDirectory  = "directory"
+)
+
+// tempDirPatterns match the directories Coach creates in the system temp
+// directory. coach-service-* was used to stage service config before there
+// were workspaces and is only left behind by old versions.
+var tempDirPatterns = []string{"coach-assemble-*", planTempDirPrefix + "*", "coach-service-*"}
+
+// previewProjectPattern matches the compose project names of previews.
+var previewProjectPattern = regexp.MustCompile(`-pr-[0-9]+$`)
+
+// gcItem is something GC removed, or would remove in a dry run.
+type gcItem struct {
+	Kind string
+	// Runtime is the runtime or host the item is on, if it isn't a directory.
+	Runtime string
+	Name    string
+	Size    int64
+	Reason  string
+}
+
+// imagePolicy decides which images are kept. Only images Coach is
+// responsible for are ever removed: those it built, and those of the
+// repositories its deployed services use. Anything else on a host is left alone.
+type imagePolicy struct {
+	// Keep is how many of the newest images of each repository are kept.
+	Keep int
+	// MaxAge is how old an unused image has to be before it is removed.
+	MaxAge time.Duration
+	// StateDir is Coach's state directory. Projects whose working directory
+	// is inside it were deployed by Coach.
+	StateDir string
+}
+
+// deployed reports whether a container belongs to a project Coach deployed.
+func (p imagePolicy) deployed(labels map[string]string) bool {
+	return strings.HasPrefix(labels[labelWorkingDir], p.StateDir+string(filepath.Separator))
+}
+
+// gcReport collects what a GC run removed and what it failed to.
+type gcReport struct {
+	dryRun  bool
+	removed []gcItem
+	errors  []string
+}
+
+func (r *gcReport) add(runtime string, items []gcItem, err error) {
+	for _, item := range items {
+		item.Runtime = runtime
+		if r.dryRun {
+			log.Printf("GC: would remove %s %s (%s)", item.Kind, item.Name, item.Reason)
+		} else {
+			log.Printf("GC: removed %s %s (%s)", item.Kind, item.Name, item.Reason)
+		}
+		r.removed = append(r.removed, item)
+	}
+	if err != nil {
+		if runtime != "" {
+			err = fmt.Errorf("%s: %w", runtime, err)
+		}
+		log.Printf("Warning: GC: %v", err)
+		r.errors = append(r.errors, err.Error())
+	}
+}
+
+func (r *gcReport) toProto() *squadv1alpha1.RunGCResponse {
+	resp := &squadv1alpha1.RunGCResponse{Errors: r.errors}
+	for _, item := range r.removed {
+		resp.Removed = append(resp.Removed, &squadv1alpha1.GCItem{
+			Kind:      item.Kind,
+			Runtime:   item.Runtime,
+			Name:      item.Name,
+			SizeBytes: item.Size,
+			Reason:    item.Reason,
+		})
+		resp.ReclaimedBytes += item.Size
+	}
+	return resp
+}
+
+func (s *coachService) RunGC(ctx context.Context, req *squadv1alpha1.RunGCRequest) (*squadv1alpha1.RunGCResponse, error) {
+	log.Printf("Running GC, dry run: %t", req.DryRun)
+
+	if !s.gcMu.TryLock() {
+		return nil, status.Error(codes.Aborted, "GC is already running")
+	}
+	defer s.gcMu.Unlock()
+
+	return s.gc(ctx, s.config.GC.maxTempDirAge(), req.DryRun).toProto(), nil
+}
+
+// scheduleGC runs GC now and then at the configured interval, until ctx is done.
+func (s *coachService) scheduleGC(ctx context.Context) {
+	interval := s.config.GC.interval()
+	for {
+		s.gcMu.Lock()
+		report := s.gc(ctx, s.config.GC.maxTempDirAge(), false)
+		s.gcMu.Unlock()
+		log.Printf("GC removed %d items with %d errors", len(report.removed), len(report.errors))
+
+		if interval < 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// removeTempDirs removes every temp and staging directory. It must only be
+// called before Coach starts serving, when none of them can be in use.
+func (s *coachService) removeTempDirs() {
+	s.gcTempDirs(&gcReport{}, 0)
+}
+
+// gc removes what the retention policy doesn't keep. Temp and staging
+// directories, and preview workspaces without a record, are only removed
+// once they are older than tempDirAge, so ones in use by requests are left.
+func (s *coachService) gc(ctx context.Context, tempDirAge time.Duration, dryRun bool) *gcReport {
+	report := &gcReport{dryRun: dryRun}
+
+	s.gcTempDirs(report, tempDirAge)
+	live := s.gcPreviewWorkspaces(ctx, report, tempDirAge)
+
+	orphaned := func(projectName string) bool {
+		return previewProjectPattern.MatchString(projectName) && !live[projectName]
+	}
+	policy := imagePolicy{
+		Keep:     s.config.GC.keepImages(),
+		MaxAge:   s.config.GC.maxImageAge(),
+		StateDir: s.stateDir,
+	}
+
+	runtimes := make(map[string]runtime)
+	for name, rt := range s.runtimes {
+		runtimes[name] = rt
+	}
+	for name, rt := range s.hosts {
+		runtimes[name] = rt
+	}
+	names := make([]string, 0, len(runtimes))
+	for name := range runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// Networks and volumes go first, as images can't be removed while a
+		// leftover container still uses them.
+		items, err := runtimes[name].PruneProjects(ctx, orphaned, dryRun)
+		report.add(name, items, err)
+
+		items, err = runtimes[name].PruneImages(ctx, policy, dryRun)
+		report.add(name, items, err)
+	}
+
+	var items []gcItem
+	item, err := s.engine.PruneBuildCache(ctx, s.config.GC.maxBuildCacheAge(), dryRun)
+	if item != nil {
+		items = append(items, *item)
+	}
+	report.add(runtimeDocker, items, err)
+
+	return report
+}
+
+// gcTempDirs removes temp and staging directories older than maxAge.
+func (s *coachService) gcTempDirs(report *gcReport, maxAge time.Duration) {
+	var dirs []string
+	for _, pattern := range tempDirPatterns {
+		matches, err := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		if err != nil {
+			report.add("", nil, err)
+			continue
+		}
+		dirs = append(dirs, matches...)
+	}
+	staged, err := filepath.Glob(filepath.Join(s.stateDir, "staging", "*"))
+	if err != nil {
+		report.add("", nil, err)
+	}
+	dirs = append(dirs, staged...)
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() || info.ModTime().After(cutoff) {
+			continue
+		}
+		item := gcItem{
+			Kind:   gcDirectory,
+			Name:   dir,
+			Size:   dirSize(dir),
+			Reason: fmt.Sprintf("left behind since %s", info.ModTime().Format(time.RFC3339)),
+		}
+		if !report.dryRun {
+			if err := os.RemoveAll(dir); err != nil {
+				report.add("", nil, fmt.Errorf("failed to remove %s: %w", dir, err))
+				continue
+			}
+		}
+		report.add("", []gcItem{item}, nil)
+	}
+}
+
+// gcPreviewWorkspaces takes down previews whose workspace has no record,
+// which is left behind when creating a preview fails part way through. It
+// returns the compose projects of the previews that remain.
+func (s *coachService) gcPreviewWorkspaces(ctx context.Context, report *gcReport, maxAge time.Duration) map[string]bool {
+	live := make(map[string]bool)
+
+	dirs, err := filepath.Glob(filepath.Join(s.stateDir, "previews", "*", "pr-*"))
+	if err != nil {
+		report.add("", nil, err)
+		return live
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, dir := range dirs {
+		var pullRequest int
+		if _, err := fmt.Sscanf(filepath.Base(dir), "pr-%d", &pullRequest); err != nil {
+			continue
+		}
+		service := filepath.Base(filepath.Dir(dir))
+		ws := previewWorkspace(s.stateDir, s.config.previewEnvironment(), service, pullRequest)
+
+		p, err := readPreview(dir)
+		info, statErr := os.Stat(dir)
+		if err != nil || p != nil || statErr != nil || info.ModTime().After(cutoff) {
+			live[ws.projectName()] = true
+			continue
+		}
+
+		item := gcItem{
+			Kind:   gcDirectory,
+			Name:   dir,
+			Size:   dirSize(dir),
+			Reason: fmt.Sprintf("preview of %s for pull request %d was never recorded", service, pullRequest),
+		}
+		if !report.dryRun {
+			removed, err := s.removeUnrecordedPreview(ctx, ws)
+			if err != nil {
+				report.add("", nil, err)
+			}
+			if !removed {
+				live[ws.projectName()] = true
+				continue
+			}
+		}
+		report.add("", []gcItem{item}, nil)
+	}
+	return live
+}
+
+// removeUnrecordedPreview takes down a preview that has no record. It
+// reports false if the preview was recorded before it could be removed.
+func (s *coachService) removeUnrecordedPreview(ctx context.Context, ws *workspace) (bool, error) {
+	defer s.locks.lock(ws.service)()
+
+	if p, err := readPreview(ws.dir); err != nil || p != nil {
+		return false, err
+	}
+
+	rt, err := s.runtimeFor(ws.service, ws.environment, "")
+	if err != nil {
+		return false, err
+	}
+	if err := rt.Down(ctx, ws.projectName()); err != nil {
+		return false, fmt.Errorf("failed to stop preview %s: %w", ws.projectName(), err)
+	}
+	if err := os.RemoveAll(ws.dir); err != nil {
+		return false, fmt.Errorf("failed to remove preview workspace: %w", err)
+	}
+	return true, nil
+}
+
+// dirSize returns the total size of the files in dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func (e *dockerEngine) PruneImages(ctx context.Context, policy imagePolicy, dryRun bool) ([]gcItem, error) {
+	containers, err := e.client.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	images, err := e.client.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	byID := make(map[string]image.Summary, len(images))
+	for _, img := range images {
+		byID[img.ID] = img
+	}
+
+	inUse := make(map[string]bool)
+	deployedRepositories := make(map[string]bool)
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+		if !policy.deployed(c.Labels) {
+			continue
+		}
+		if named, err := reference.ParseNormalizedNamed(c.Image); err == nil {
+			deployedRepositories[named.Name()] = true
+		}
+		for _, repository := range imageRepositories(byID[c.ImageID]) {
+			deployedRepositories[repository] = true
+		}
+	}
+
+	kept := newestImages(images, policy.Keep)
+	cutoff := time.Now().Add(-policy.MaxAge)
+
+	var items []gcItem
+	var errs []error
+	for _, img := range images {
+		if inUse[img.ID] || kept[img.ID] || time.Unix(img.Created, 0).After(cutoff) {
+			continue
+		}
+		if !ownedImage(img, deployedRepositories) {
+			continue
+		}
+
+		item := gcItem{
+			Kind:   gcImage,
+			Name:   imageName(img),
+			Size:   img.Size,
+			Reason: fmt.Sprintf("unused, older than %s and not one of the %d newest images of its repository", policy.MaxAge, policy.Keep),
+		}
+		if len(imageRepositories(img)) == 0 {
+			item.Reason = fmt.Sprintf("dangling and older than %s", policy.MaxAge)
+		}
+
+		if !dryRun {
+			if err := e.removeImage(ctx, img); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove image %s: %w", item.Name, err))
+				continue
+			}
+		}
+		items = append(items, item)
+	}
+	return items, errors.Join(errs...)
+}
+
+// ownedImage reports whether Coach is responsible for an image: it was built
+// by Coach, or is in one of the repositories of Coach's deployed services.
+func ownedImage(img image.Summary, deployedRepositories map[string]bool) bool {
+	if img.Labels[labelAssembled] != "" {
+		return true
+	}
+	for _, repository := range imageRepositories(img) {
+		if deployedRepositories[repository] {
+			return true
+		}
+	}
+	return false
+}
+
+// removeImage removes an unused image without forcing it. An image with more
+// than one tag can't be removed by ID without force, so each tag is removed
+// instead, and the image goes with the last one.
+func (e *dockerEngine) removeImage(ctx context.Context, img image.Summary) error {
+	refs := []string{img.ID}
+	var tags []string
+	for _, tag := range img.RepoTags {
+		if !strings.HasPrefix(tag, "<none>") {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > 1 {
+		refs = tags
+	}
+
+	for _, ref := range refs {
+		if _, err := e.client.ImageRemove(ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newestImages returns the IDs of the keep newest images of every repository.
+func newestImages(images []image.Summary, keep int) map[string]bool {
+	byRepository := make(map[string][]image.Summary)
+	for _, img := range images {
+		for _, repository := range imageRepositories(img) {
+			byRepository[repository] = append(byRepository[repository], img)
+		}
+	}
+
+	kept := make(map[string]bool)
+	for _, images := range byRepository {
+		sort.Slice(images, func(i, j int) bool {
+			return images[i].Created > images[j].Created
+		})
+		for i := 0; i < len(images) && i < keep; i++ {
+			kept[images[i].ID] = true
+		}
+	}
+	return kept
+}
+
+// imageRepositories returns the repositories an image is tagged or was pulled
+// by digest in. Dangling images have none.
+func imageRepositories(img image.Summary) []string {
+	seen := make(map[string]bool)
+	var repositories []string
+	for _, ref := range append(append([]string{}, img.RepoTags...), img.RepoDigests...) {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil || seen[named.Name()] {
+			continue
+		}
+		seen[named.Name()] = true
+		repositories = append(repositories, named.Name())
+	}
+	return repositories
+}
+
+func imageName(img image.Summary) string {
+	for _, ref := range append(append([]string{}, img.RepoTags...), img.RepoDigests...) {
+		if !strings.HasPrefix(ref, "<none>") {
+			return ref
+		}
+	}
+	return img.ID
+}
+
+func (e *dockerEngine) PruneProjects(ctx context.Context, orphaned func(projectName string) bool, dryRun bool) ([]gcItem, error) {
+	projectFilter := filters.NewArgs(filters.Arg("label", labelProject))
+
+	// empty caches whether each orphaned project has no containers left.
+	empty := make(map[string]bool)
+	isEmpty := func(projectName string) (bool, error) {
+		if v, ok := empty[projectName]; ok {
+			return v, nil
+		}
+		containers, err := e.projectContainers(ctx, projectName)
+		if err != nil {
+			return false, err
+		}
+		empty[projectName] = len(containers) == 0
+		return empty[projectName], nil
+	}
+
+	var items []gcItem
+	var errs []error
+	remove := func(kind, name, projectName string, rm func() error) {
+		if !orphaned(projectName) {
+			return
+		}
+		ok, err := isEmpty(projectName)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if !ok {
+			return
+		}
+		if !dryRun {
+			if err := rm(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove %s %s: %w", kind, name, err))
+				return
+			}
+		}
+		items = append(items, gcItem{
+			Kind:   kind,
+			Name:   name,
+			Reason: fmt.Sprintf("project %s is gone", projectName),
+		})
+	}
+
+	networks, err := e.client.NetworkList(ctx, network.ListOptions{Filters: projectFilter})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+	for _, n := range networks {
+		remove(gcNetwork, n.Name, n.Labels[labelProject], func() error {
+			return e.client.NetworkRemove(ctx, n.ID)
+		})
+	}
+
+	volumes, err := e.client.VolumeList(ctx, volume.ListOptions{Filters: projectFilter})
+	if err != nil {
+		return items, errors.Join(append(errs, fmt.Errorf("failed to list volumes: %w", err))...)
+	}
+	for _, v := range volumes.Volumes {
+		remove(gcVolume, v.Name, v.Labels[labelProject], func() error {
+			return e.client.VolumeRemove(ctx, v.Name, false)
+		})
+	}
+
+	return items, errors.Join(errs...)
+}
+
+func (e *dockerEngine) PruneBuildCache(ctx context.Context, maxAge time.Duration, dryRun bool) (*gcItem, error) {
+	var records int
+	var size int64
+
+	if dryRun {
+		usage, err := e.client.DiskUsage(ctx, dockertypes.DiskUsageOptions{
+			Types: []dockertypes.DiskUsageObject{dockertypes.BuildCacheObject},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read build cache usage: %w", err)
+		}
+		cutoff := time.Now().Add(-maxAge)
+		for _, record := range usage.BuildCache {
+			lastUsed := record.CreatedAt
+			if record.LastUsedAt != nil {
+				lastUsed = *record.LastUsedAt
+			}
+			if record.InUse || lastUsed.After(cutoff) {
+				continue
+			}
+			records++
+			size += record.Size
+		}
+	} else {
+		report, err := e.client.BuildCachePrune(ctx, build.CachePruneOptions{
+			Filters: filters.NewArgs(filters.Arg("until", maxAge.String())),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to prune build cache: %w", err)
+		}
+		records = len(report.CachesDeleted)
+		size = int64(report.SpaceReclaimed)
+	}
+
+	if records == 0 {
+		return nil, nil
+	}
+	return &gcItem{
+		Kind:   gcBuildCache,
+		Name:   fmt.Sprintf("%d build cache records", records),
+		Size:   size,
+		Reason: fmt.Sprintf("unused for %s", maxAge),
+	}, nil
+}
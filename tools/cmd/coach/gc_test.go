@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// gcDocker is a daemon with the images and containers PruneImages looks at.
+// Calling anything else panics on the nil embedded client.
+type gcDocker struct {
+	client.APIClient
+
+	images     []image.Summary
+	containers []container.Summary
+	// removed are the refs images were removed by, in order.
+	removed []string
+}
+
+func (d *gcDocker) ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error) {
+	return d.containers, nil
+}
+
+func (d *gcDocker) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	return d.images, nil
+}
+
+func (d *gcDocker) ImageRemove(ctx context.Context, ref string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	d.removed = append(d.removed, ref)
+	return nil, nil
+}
+
+func TestPruneImages(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-30 * 24 * time.Hour).Unix()
+	d := &gcDocker{
+		images: []image.Summary{
+			// web is deployed by Coach. Its newest image is kept, the one
+			// its container runs is in use, and the rest are removed
+			// unless they are recent.
+			{ID: "web-newest", RepoTags: []string{"registry.baileys.dev/web:v4"}, Created: now.Add(-time.Hour).Unix()},
+			{ID: "web-running", RepoTags: []string{"registry.baileys.dev/web:v3"}, Created: old},
+			{ID: "web-recent", RepoTags: []string{"registry.baileys.dev/web:v2"}, Created: now.Add(-2 * time.Hour).Unix()},
+			{ID: "web-old", RepoTags: []string{"registry.baileys.dev/web:v1", "registry.baileys.dev/web:stable"}, Created: old - 1},
+			{ID: "web-dangling", RepoDigests: []string{"registry.baileys.dev/web@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}, Created: old - 2},
+			// Dangling images of other repositories aren't Coach's.
+			{ID: "dangling", RepoTags: []string{"<none>:<none>"}, Created: old},
+			// postgres isn't deployed by Coach, so it is left alone.
+			{ID: "postgres-newest", RepoTags: []string{"postgres:17"}, Created: old},
+			{ID: "postgres-old", RepoTags: []string{"postgres:16"}, Created: old - 1},
+			// Images Coach built are its own, wherever they are used.
+			{ID: "assembled-newest", RepoTags: []string{"registry.baileys.dev/tool:v2"}, Created: old, Labels: map[string]string{labelAssembled: "true"}},
+			{ID: "assembled-old", RepoTags: []string{"registry.baileys.dev/tool:v1"}, Created: old - 1, Labels: map[string]string{labelAssembled: "true"}},
+		},
+		containers: []container.Summary{
+			{Image: "registry.baileys.dev/web:v3", ImageID: "web-running", Labels: map[string]string{labelWorkingDir: filepath.Join(stateDir, "services", "web")}},
+			{Image: "postgres:17", ImageID: "postgres-newest", Labels: map[string]string{labelWorkingDir: "/home/user/db"}},
+		},
+	}
+	e := &dockerEngine{client: d}
+	policy := imagePolicy{Keep: 1, MaxAge: 7 * 24 * time.Hour, StateDir: stateDir}
+
+	items, err := e.PruneImages(context.Background(), policy, true)
+	if err != nil {
+		t.Fatalf("PruneImages: %v", err)
+	}
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	want := []string{"registry.baileys.dev/web:v1", "registry.baileys.dev/web@sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "registry.baileys.dev/tool:v1"}
+	if !slices.Equal(names, want) {
+		t.Errorf("PruneImages would remove %v, want %v", names, want)
+	}
+	if len(d.removed) != 0 {
+		t.Errorf("dry run removed %v", d.removed)
+	}
+
+	if _, err := e.PruneImages(context.Background(), policy, false); err != nil {
+		t.Fatalf("PruneImages: %v", err)
+	}
+	// An image with more than one tag is removed by each of them.
+	if want := []string{"registry.baileys.dev/web:v1", "registry.baileys.dev/web:stable", "web-dangling", "assembled-old"}; !slices.Equal(d.removed, want) {
+		t.Errorf("PruneImages removed %v, want %v", d.removed, want)
+	}
+}
+
+func TestNewestImages(t *testing.T) {
+	images := []image.Summary{
+		{ID: "a1", RepoTags: []string{"registry.baileys.dev/a:1"}, Created: 1},
+		{ID: "a3", RepoTags: []string{"registry.baileys.dev/a:3"}, Created: 3},
+		{ID: "a2", RepoTags: []string{"registry.baileys.dev/a:2"}, Created: 2},
+		{ID: "b1", RepoTags: []string{"registry.baileys.dev/b:1"}, Created: 1},
+		{ID: "dangling", Created: 4},
+	}
+
+	kept := newestImages(images, 2)
+	var ids []string
+	for _, img := range images {
+		if kept[img.ID] {
+			ids = append(ids, img.ID)
+		}
+	}
+	if want := []string{"a3", "a2", "b1"}; !slices.Equal(ids, want) {
+		t.Errorf("newestImages kept %v, want %v", ids, want)
+	}
+}
+
+// testDir creates a directory last modified age ago.
+func testDir(t *testing.T, dir string, age time.Duration) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-age)
+	if err := os.Chtimes(dir, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCTempDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+	s := &coachService{config: &config{}, stateDir: t.TempDir()}
+
+	testDir(t, filepath.Join(tempDir, "coach-assemble-old"), 7*time.Hour)
+	testDir(t, filepath.Join(tempDir, "coach-assemble-new"), time.Minute)
+	testDir(t, filepath.Join(tempDir, "unrelated-old"), 7*time.Hour)
+	testDir(t, filepath.Join(s.stateDir, "staging", "web-old"), 7*time.Hour)
+	testDir(t, filepath.Join(s.stateDir, "staging", "web-new"), time.Minute)
+
+	report := &gcReport{}
+	s.gcTempDirs(report, defaultMaxTempDirAge)
+
+	var removed []string
+	for _, item := range report.removed {
+		removed = append(removed, item.Name)
+	}
+	want := []string{filepath.Join(tempDir, "coach-assemble-old"), filepath.Join(s.stateDir, "staging", "web-old")}
+	if !slices.Equal(removed, want) {
+		t.Errorf("gcTempDirs removed %v, want %v", removed, want)
+	}
+	for _, dir := range want {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s wasn't removed: %v", dir, err)
+		}
+	}
+	for _, dir := range []string{"coach-assemble-new", "unrelated-old"} {
+		if _, err := os.Stat(filepath.Join(tempDir, dir)); err != nil {
+			t.Errorf("%s was removed: %v", dir, err)
+		}
+	}
+}
+
+func TestGCPreviewWorkspaces(t *testing.T) {
+	rt := &fakeRuntime{}
+	s := &coachService{config: &config{}, stateDir: t.TempDir(), runtimes: map[string]runtime{runtimeDocker: rt}}
+
+	recorded := previewWorkspace(s.stateDir, defaultEnvironment, "web", 1)
+	testDir(t, recorded.dir, 7*time.Hour)
+	if err := writePreview(recorded.dir, &preview{Service: "web", PullRequest: 1}); err != nil {
+		t.Fatal(err)
+	}
+	unrecorded := previewWorkspace(s.stateDir, defaultEnvironment, "web", 2)
+	testDir(t, unrecorded.dir, 7*time.Hour)
+	creating := previewWorkspace(s.stateDir, defaultEnvironment, "web", 3)
+	testDir(t, creating.dir, time.Minute)
+
+	report := &gcReport{}
+	live := s.gcPreviewWorkspaces(context.Background(), report, defaultMaxTempDirAge)
+
+	if len(report.removed) != 1 || report.removed[0].Name != unrecorded.dir {
+		t.Errorf("gcPreviewWorkspaces removed %+v, want %s", report.removed, unrecorded.dir)
+	}
+	if !slices.Equal(rt.down, []string{unrecorded.projectName()}) {
+		t.Errorf("gcPreviewWorkspaces took down %v, want %s", rt.down, unrecorded.projectName())
+	}
+	if _, err := os.Stat(unrecorded.dir); !os.IsNotExist(err) {
+		t.Errorf("%s wasn't removed: %v", unrecorded.dir, err)
+	}
+	want := map[string]bool{recorded.projectName(): true, creating.projectName(): true}
+	if len(live) != len(want) || !live[recorded.projectName()] || !live[creating.projectName()] {
+		t.Errorf("gcPreviewWorkspaces left %v live, want %v", live, want)
+	}
+}
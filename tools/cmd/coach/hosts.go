@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/client"
+)
+
+// localHost is the machine Coach itself runs on.
+const localHost = "local"
+
+// newHostRuntimes creates a runtime for every remote host in the config.
+func newHostRuntimes(cfg *config) (map[string]runtime, error) {
+	hosts := make(map[string]runtime)
+	for name, hc := range cfg.Hosts {
+		r, err := newHostRuntime(hc)
+		if err != nil {
+			return nil, fmt.Errorf("host %s: %w", name, err)
+		}
+		log.Printf("Configured host %s at %s", name, hc.Address)
+		hosts[name] = &remoteRuntime{host: name, runtime: r}
+	}
+	return hosts, nil
+}
+
+func newHostRuntime(hc hostConfig) (runtime, error) {
+	u, err := url.Parse(hc.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %s: %w", hc.Address, err)
+	}
+
+	switch u.Scheme {
+	case "ssh":
+		dialer := &sshDialer{
+			destination:  u,
+			identityFile: hc.IdentityFile,
+			command:      hc.runtimeType(),
+		}
+		// The host is only used to build request URLs; every connection goes through ssh.
+		return newDockerEngine(client.WithHost("http://docker.example.com"), client.WithDialContext(dialer.DialContext))
+	case "tcp":
+		opts := []client.Opt{client.WithHost(hc.Address)}
+		if hc.TLS != nil {
+			opts = append(opts, client.WithTLSClientConfig(hc.TLS.CA, hc.TLS.Cert, hc.TLS.Key))
+		}
+		return newDockerEngine(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported address scheme %q (must be ssh or tcp)", u.Scheme)
+	}
+}
+
+// remoteRuntime deploys to a daemon on another machine. Files in a service's
+// workspace only exist on Coach's machine, so services that bind mount them
+// can't be deployed remotely.
+type remoteRuntime struct {
+	host string
+	runtime
+}
+
+func (r *remoteRuntime) Up(ctx context.Context, project *types.Project) (*upResult, error) {
+	if err := r.checkBindMounts(project); err != nil {
+		return nil, err
+	}
+	return r.runtime.Up(ctx, project)
+}
+
+func (r *remoteRuntime) Plan(ctx context.Context, project *types.Project) (*planResult, error) {
+	if err := r.checkBindMounts(project); err != nil {
+		return nil, err
+	}
+	return r.runtime.Plan(ctx, project)
+}
+
+// checkBindMounts rejects bind mounts of the service's workspace, including
+// file secrets and configs, which only exist on Coach's host.
+func (r *remoteRuntime) checkBindMounts(project *types.Project) error {
+	for _, service := range project.Services {
+		for _, v := range service.Volumes {
+			if v.Type == types.VolumeTypeBind && isWithin(project.WorkingDir, v.Source) {
+				return fmt.Errorf("service %s: bind mount of %s is not supported on remote host %s", service.Name, project.RelativePath(v.Source), r.host)
+			}
+		}
+		for _, ref := range service.Secrets {
+			if secret, ok := project.Secrets[ref.Source]; ok && isWithin(project.WorkingDir, secret.File) {
+				return fmt.Errorf("service %s: secret %s is not supported on remote host %s", service.Name, ref.Source, r.host)
+			}
+		}
+		for _, ref := range service.Configs {
+			if config, ok := project.Configs[ref.Source]; ok && isWithin(project.WorkingDir, config.File) {
+				return fmt.Errorf("service %s: config %s is not supported on remote host %s", service.Name, ref.Source, r.host)
+			}
+		}
+	}
+	return nil
+}
+
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// sshDialer connects to a remote daemon by running `<command> system dial-stdio`
+// over ssh, the same way the docker CLI handles ssh:// hosts.
+type sshDialer struct {
+	destination  *url.URL
+	identityFile string
+	command      string
+}
+
+func (d *sshDialer) DialContext(ctx context.Context, _, _ string) (net.Conn, error) {
+	args := []string{"-o", "BatchMode=yes"}
+	if d.identityFile != "" {
+		args = append(args, "-i", d.identityFile)
+	}
+	if port := d.destination.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+
+	destination := d.destination.Hostname()
+	if user := d.destination.User.Username(); user != "" {
+		destination = user + "@" + destination
+	}
+	args = append(args, "--", destination, d.command, "system", "dial-stdio")
+
+	// The connection outlives the dial, so the command must not be tied to ctx.
+	cmd := exec.Command("ssh", args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout, addr: d.destination.Host}, nil
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a running command.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	addr   string
+}
+
+func (c *commandConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *commandConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+
+func (c *commandConn) Close() error {
+	c.stdin.Close()
+	c.stdout.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr                { return commandAddr("local") }
+func (c *commandConn) RemoteAddr() net.Addr               { return commandAddr(c.addr) }
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
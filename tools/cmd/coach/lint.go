@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+
+	"github.com/google/go-github/v74/github"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+	"github.com/baely/infra/tools/internal/routing"
+)
+
+// LintRouting checks the deploy.yaml of every service in the infra repo for
+// traefik routing conflicts between services, in every environment.
+func (s *coachService) LintRouting(ctx context.Context, req *squadv1alpha1.LintRoutingRequest) (*squadv1alpha1.LintRoutingResponse, error) {
+	log.Printf("Linting routing at ref: %s", req.Ref)
+
+	services, err := s.downloadDeployFiles(ctx, req.Ref)
+	if err != nil {
+		log.Printf("Failed to download deploy files: %v", err)
+		return nil, status.Errorf(codes.Unavailable, "failed to download deploy files: %v", err)
+	}
+
+	conflicts, unlinted, err := routing.LintEnvironments(services, s.config.environment())
+	if err != nil {
+		log.Printf("Failed to lint deploy files: %v", err)
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to lint deploy files: %v", err)
+	}
+
+	resp := &squadv1alpha1.LintRoutingResponse{}
+	for _, c := range conflicts {
+		log.Printf("Routing conflict in %s: %s", c.Environment, c.Message)
+		resp.Conflicts = append(resp.Conflicts, &squadv1alpha1.RoutingConflict{
+			Kind:        c.Kind,
+			Message:     c.Message,
+			Services:    c.Owners,
+			Environment: c.Environment,
+		})
+	}
+	for _, u := range unlinted {
+		log.Printf("Not linted: %s: %s", u.Path, u.Reason)
+		resp.Unlinted = append(resp.Unlinted, &squadv1alpha1.UnlintedFile{
+			Path:   u.Path,
+			Reason: u.Reason,
+		})
+	}
+
+	log.Printf("Found %d routing conflicts across %d services", len(resp.Conflicts), len(services))
+	return resp, nil
+}
+
+// downloadDeployFiles reads the deploy files of every service in the infra
+// repo at ref: its deploy.yaml or deploy.yaml.tmpl, and those in its
+// environments/<environment>/ directories.
+func (s *coachService) downloadDeployFiles(ctx context.Context, ref string) ([]routing.Service, error) {
+	opts := &github.RepositoryContentGetOptions{Ref: ref}
+
+	serviceDirs, err := s.listRepoDir(ctx, "docker", opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	var services []routing.Service
+	for _, dir := range serviceDirs {
+		if dir.GetType() != "dir" {
+			continue
+		}
+
+		servicePath := path.Join("docker", dir.GetName())
+		paths, err := s.listDeployFiles(ctx, servicePath, ".", opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			log.Printf("Skipping %s (no %s)", dir.GetName(), deployFileName)
+			continue
+		}
+
+		service := routing.Service{Name: dir.GetName(), Files: make(map[string][]byte)}
+		for _, p := range paths {
+			filePath := path.Join(servicePath, p)
+			file, _, _, err := s.github.Repositories.GetContents(ctx, "baely", "infra", filePath, opts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get %s: %w", filePath, err)
+			}
+			content, err := file.GetContent()
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", filePath, err)
+			}
+			service.Files[p] = []byte(content)
+		}
+		services = append(services, service)
+	}
+
+	return services, nil
+}
+
+// listDeployFiles returns the paths of the deploy files in dir of a service's
+// directory, relative to the service's directory, descending into its
+// environments directory.
+func (s *coachService) listDeployFiles(ctx context.Context, servicePath, dir string, opts *github.RepositoryContentGetOptions) ([]string, error) {
+	entries, err := s.listRepoDir(ctx, path.Join(servicePath, dir), opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", path.Join(servicePath, dir), err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		p := path.Join(dir, entry.GetName())
+		switch {
+		case entry.GetType() == "file" && routing.IsDeployFile(p):
+			paths = append(paths, p)
+		case entry.GetType() == "dir" && (p == routing.EnvironmentsDir || path.Dir(p) == routing.EnvironmentsDir):
+			sub, err := s.listDeployFiles(ctx, servicePath, p, opts)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, sub...)
+		}
+	}
+	return paths, nil
+}
+
+// listRepoDir lists a directory of the infra repo. A directory that doesn't
+// exist is empty.
+func (s *coachService) listRepoDir(ctx context.Context, dir string, opts *github.RepositoryContentGetOptions) ([]*github.RepositoryContent, error) {
+	_, entries, _, err := s.github.Repositories.GetContents(ctx, "baely", "infra", dir, opts)
+	var errResp *github.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return entries, err
+}
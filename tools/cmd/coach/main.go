@@ -3,8 +3,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net"
 	"net/http"
@@ -13,7 +15,11 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
 	"github.com/google/go-github/v74/github"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -21,6 +27,8 @@ import (
 	"google.golang.org/grpc/status"
 
 	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+	"github.com/baely/infra/tools/internal/githubapp"
+	"github.com/baely/infra/tools/internal/provenance"
 )
 
 func main() {
@@ -29,7 +37,71 @@ func main() {
 		log.Fatal("COACH_AUTH_TOKEN environment variable is required")
 	}
 
-	service := &coachService{}
+	stateDir := os.Getenv("COACH_STATE_DIR")
+	if stateDir == "" {
+		stateDir = defaultStateDir
+	}
+
+	cfg, err := loadConfig(os.Getenv("COACH_CONFIG"))
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	dockerEngine, err := newDockerEngine()
+	if err != nil {
+		log.Fatalf("failed to create docker engine: %v", err)
+	}
+
+	runtimes, err := newRuntimes(cfg, dockerEngine)
+	if err != nil {
+		log.Fatalf("failed to create runtimes: %v", err)
+	}
+
+	hosts, err := newHostRuntimes(cfg)
+	if err != nil {
+		log.Fatalf("failed to create hosts: %v", err)
+	}
+
+	var secrets *secretStore
+	if keyFile := os.Getenv("COACH_AGE_KEY_FILE"); keyFile != "" {
+		// SOPS-encrypted service config is decrypted with the same key.
+		if os.Getenv("SOPS_AGE_KEY_FILE") == "" {
+			os.Setenv("SOPS_AGE_KEY_FILE", keyFile)
+		}
+
+		secrets, err = newSecretStore(filepath.Join(stateDir, "secrets"), keyFile)
+		if err != nil {
+			log.Fatalf("failed to open secret store: %v", err)
+		}
+	}
+
+	githubClient, err := newGitHubClient()
+	if err != nil {
+		log.Fatalf("failed to create github client: %v", err)
+	}
+
+	service := &coachService{
+		config:   cfg,
+		stateDir: stateDir,
+		engine:   dockerEngine,
+		runtimes: runtimes,
+		hosts:    hosts,
+		secrets:  secrets,
+		github:   githubClient,
+	}
+
+	service.removeTempDirs()
+	go service.expirePreviews(context.Background())
+	go service.scheduleGC(context.Background())
+
+	if addr := os.Getenv("COACH_CATALOG_ADDR"); addr != "" {
+		go func() {
+			log.Printf("serving service catalog on %s", addr)
+			if err := http.ListenAndServe(addr, service.catalogHandler()); err != nil {
+				log.Fatalf("failed to serve service catalog: %v", err)
+			}
+		}()
+	}
 
 	server := grpc.NewServer(
 		grpc.UnaryInterceptor(authInterceptor(authToken)),
@@ -48,8 +120,41 @@ func main() {
 	}
 }
 
+// defaultStateDir must be mounted at the same path on the host, as bind mounts
+// in a service's deploy.yaml are resolved by the host's docker daemon.
+const defaultStateDir = "/var/lib/coach"
+
 type coachService struct {
 	squadv1alpha1.UnimplementedCoachServiceServer
+
+	config   *config
+	stateDir string
+	engine   engine
+	runtimes map[string]runtime
+	hosts    map[string]runtime
+	// secrets is nil unless an age key is configured.
+	secrets *secretStore
+	// gcMu is held while GC runs.
+	gcMu sync.Mutex
+	// locks are held while a service is deployed.
+	locks serviceLocks
+	// github reads the infra repo.
+	github *github.Client
+}
+
+// newGitHubClient returns a client for the infra repo. It authenticates as
+// the GitHub App configured by GITHUB_APP_ID if there is one, and is
+// anonymous otherwise, which GitHub limits to 60 requests an hour.
+func newGitHubClient() (*github.Client, error) {
+	app, err := githubapp.FromEnv(nil)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		log.Printf("Warning: %s is not set, reading the infra repo anonymously", githubapp.IDEnv)
+		return github.NewClient(nil), nil
+	}
+	return app.Client("baely", nil), nil
 }
 
 func (s *coachService) Assemble(ctx context.Context, req *squadv1alpha1.AssembleRequest) (*squadv1alpha1.AssembleResponse, error) {
@@ -89,15 +194,32 @@ func (s *coachService) Assemble(ctx context.Context, req *squadv1alpha1.Assemble
 	dockerfile := getStringOrDefault(req.DockerfileLocation, "Dockerfile")
 	dockerContext := getStringOrDefault(req.ContextLocation, ".")
 
-	if err := s.buildDockerImage(repoDir, dockerImage, dockerfile, dockerContext); err != nil {
+	result, err := s.engine.Build(ctx, buildOptions{
+		ContextDir: filepath.Join(repoDir, dockerContext),
+		Dockerfile: filepath.Join(repoDir, dockerfile),
+		Tag:        dockerImage,
+		Platform:   "linux/amd64",
+		Push:       true,
+	})
+	if err != nil {
+		var buildErr *buildError
+		if errors.As(err, &buildErr) {
+			for _, line := range buildErr.Log {
+				log.Printf("build: %s", line)
+			}
+		}
 		return nil, fmt.Errorf("failed to build docker image: %w", err)
 	}
 
-	return &squadv1alpha1.AssembleResponse{}, nil
+	return &squadv1alpha1.AssembleResponse{
+		Image:   result.Image,
+		ImageId: result.ImageID,
+		Digest:  result.Digest,
+	}, nil
 }
 
 func (s *coachService) Start(ctx context.Context, req *squadv1alpha1.StartRequest) (*squadv1alpha1.StartResponse, error) {
-	log.Printf("Starting deployment for service: %s, ref: %s", req.Service, req.Ref)
+	log.Printf("Starting deployment for service: %s, ref: %s, environment: %s, host: %s, dry run: %t", req.Service, req.Ref, req.Environment, req.Host, req.DryRun)
 	
 	if err := validateStartRequest(req); err != nil {
 		log.Printf("Validation failed for start request: %v", err)
@@ -105,129 +227,453 @@ func (s *coachService) Start(ctx context.Context, req *squadv1alpha1.StartReques
 	}
 	log.Printf("Start request validation passed")
 
-	log.Printf("Downloading service config for %s", req.Service)
-	workDir, err := s.downloadServiceConfig(ctx, req.Service, req.Ref)
+	environment := req.Environment
+	if environment == "" {
+		environment = s.config.environment()
+	}
+	if !s.config.hasEnvironment(environment) {
+		log.Printf("Unknown environment: %s", environment)
+		return nil, status.Errorf(codes.InvalidArgument, "unknown environment %q", environment)
+	}
+
+	rt, err := s.runtimeFor(req.Service, environment, req.Host)
 	if err != nil {
-		log.Printf("Failed to download service config: %v", err)
-		return nil, fmt.Errorf("failed to download service config: %w", err)
+		log.Printf("Failed to find runtime: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	defer s.locks.lock(req.Service)()
+
+	ws := workspaceFor(s.stateDir, environment, req.Service)
+	var stagingDir string
+	if req.DryRun {
+		// A plan leaves the state directory alone, so it is staged in a temp directory.
+		stagingDir, err = os.MkdirTemp("", fmt.Sprintf("%s%s-", planTempDirPrefix, req.Service))
+	} else {
+		ws, err = createWorkspace(ws)
+		if err != nil {
+			log.Printf("Failed to open workspace: %v", err)
+			return nil, fmt.Errorf("failed to open workspace: %w", err)
+		}
+		stagingDir, err = ws.stage()
+	}
+	if err != nil {
+		log.Printf("Failed to create staging directory: %v", err)
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
 	}
-	log.Printf("Service config downloaded to: %s", workDir)
-	
 	defer func() {
-		log.Printf("Cleaning up temp directory: %s", workDir)
-		if err := os.RemoveAll(workDir); err != nil {
-			log.Printf("Warning: failed to cleanup temp directory %s: %v", workDir, err)
-		} else {
-			log.Printf("Successfully cleaned up temp directory")
+		log.Printf("Cleaning up staging directory: %s", stagingDir)
+		if err := os.RemoveAll(stagingDir); err != nil {
+			log.Printf("Warning: failed to cleanup staging directory %s: %v", stagingDir, err)
 		}
 	}()
 
-	log.Printf("Validating deploy file in: %s", workDir)
-	if err := validateDeployFile(workDir); err != nil {
-		log.Printf("Deploy file validation failed: %v", err)
+	sha, err := s.prepareConfig(ctx, ws, req.Ref, req.Host, stagingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DryRun {
+		return s.plan(ctx, rt, ws, stagingDir)
+	}
+
+	up, err := s.deploy(ctx, rt, ws, stagingDir, nil)
+	if err != nil {
 		return nil, err
 	}
+
+	if err := ws.writeDeployment(&deployment{Ref: req.Ref, SHA: sha, Host: req.Host, DeployedAt: time.Now()}); err != nil {
+		log.Printf("Warning: failed to record deployment: %v", err)
+	}
+
+	return &squadv1alpha1.StartResponse{
+		Containers: containersToProto(up.Containers),
+	}, nil
+}
+
+// stageConfig downloads the service's config at ref into a new staging
+// directory next to the workspace and gets it ready to deploy: encrypted files
+// are decrypted, stored secrets written and templates rendered, and the
+// result is validated. It returns the staging directory, which the caller
+// must remove, and the commit ref resolved to.
+func (s *coachService) stageConfig(ctx context.Context, ws *workspace, ref, host string) (string, string, error) {
+	stagingDir, err := ws.stage()
+	if err != nil {
+		log.Printf("Failed to create staging directory: %v", err)
+		return "", "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	sha, err := s.prepareConfig(ctx, ws, ref, host, stagingDir)
+	if err != nil {
+		if err := os.RemoveAll(stagingDir); err != nil {
+			log.Printf("Warning: failed to cleanup staging directory %s: %v", stagingDir, err)
+		}
+		return "", "", err
+	}
+	return stagingDir, sha, nil
+}
+
+func (s *coachService) prepareConfig(ctx context.Context, ws *workspace, ref, host, stagingDir string) (string, error) {
+	sha, err := s.resolveRef(ctx, ref)
+	if err != nil {
+		log.Printf("Failed to resolve ref %s: %v", ref, err)
+		return "", fmt.Errorf("failed to resolve ref: %w", err)
+	}
+	log.Printf("Resolved ref %s to %s", ref, sha)
+
+	log.Printf("Downloading service config for %s", ws.service)
+	if err := s.downloadServiceConfig(ctx, ws, sha, stagingDir); err != nil {
+		log.Printf("Failed to download service config: %v", err)
+		return "", fmt.Errorf("failed to download service config: %w", err)
+	}
+	log.Printf("Service config downloaded to: %s", stagingDir)
+
+	if s.secrets != nil {
+		if err := s.secrets.materialize(ws.service, stagingDir); err != nil {
+			log.Printf("Failed to write stored secrets: %v", err)
+			return "", fmt.Errorf("failed to write stored secrets: %w", err)
+		}
+	}
+
+	vars, err := readVars(stagingDir)
+	if err != nil {
+		log.Printf("Failed to read template variables: %v", err)
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	data := templateData{
+		SHA:         sha,
+		Service:     ws.service,
+		Host:        s.hostFor(ws.service, ws.environment, host),
+		Environment: ws.environment,
+		Domain:      s.config.Environments[ws.environment].Domain,
+		Vars:        vars,
+	}
+	if err := renderTemplates(stagingDir, data, templateFuncs(ctx, s.engine)); err != nil {
+		log.Printf("Failed to render templates: %v", err)
+		return "", status.Errorf(codes.InvalidArgument, "failed to render templates: %v", err)
+	}
+
+	log.Printf("Validating deploy file in: %s", stagingDir)
+	if err := s.validateDeployFile(ctx, ws, stagingDir); err != nil {
+		log.Printf("Deploy file validation failed: %v", err)
+		return "", err
+	}
 	log.Printf("Deploy file validation passed")
 
-	log.Printf("Pulling docker images for service: %s", req.Service)
-	if err := s.runDockerCompose(workDir, "pull"); err != nil {
+	return sha, nil
+}
+
+// deploy commits the staged config to the workspace and brings the project's
+// containers up to date with it. edit, if set, changes the project after it
+// is loaded and before anything is pulled.
+func (s *coachService) deploy(ctx context.Context, rt runtime, ws *workspace, stagingDir string, edit func(*types.Project) error) (*upResult, error) {
+	rev, err := ws.commit(stagingDir)
+	if err != nil {
+		log.Printf("Failed to commit service config: %v", err)
+		return nil, fmt.Errorf("failed to commit service config: %w", err)
+	}
+	workDir := ws.dir
+	log.Printf("Service config committed to: %s", workDir)
+
+	up, err := s.up(ctx, rt, ws, edit)
+	if err != nil {
+		// Up may have removed the old containers before failing to start
+		// the new ones, so the previous config is brought back up too.
+		log.Printf("Restoring the previous config of %s", ws.service)
+		if rerr := ws.rollback(rev); rerr != nil {
+			log.Printf("Warning: failed to restore previous config: %v", rerr)
+			return nil, err
+		}
+		if len(rev.previous) > 0 {
+			log.Printf("Bringing %s back up with its previous config", ws.service)
+			if _, rerr := s.up(ctx, rt, ws, edit); rerr != nil {
+				log.Printf("Warning: failed to bring back the previous config: %v", rerr)
+			}
+		}
+		return nil, err
+	}
+
+	if err := ws.prune(rev); err != nil {
+		log.Printf("Warning: failed to remove stale files: %v", err)
+	}
+
+	return up, nil
+}
+
+// up brings the project in the workspace's committed config up.
+func (s *coachService) up(ctx context.Context, rt runtime, ws *workspace, edit func(*types.Project) error) (*upResult, error) {
+	project, err := loadProject(ctx, ws.dir, cli.WithName(ws.projectName()))
+	if err != nil {
+		log.Printf("Failed to load project: %v", err)
+		return nil, err
+	}
+	if edit != nil {
+		if err := edit(project); err != nil {
+			log.Printf("Failed to prepare project: %v", err)
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	log.Printf("Pulling docker images for service: %s", ws.service)
+	pulled, err := rt.Pull(ctx, project)
+	if err != nil {
 		log.Printf("Failed to pull docker images: %v", err)
 		return nil, fmt.Errorf("failed to pull images: %w", err)
 	}
-	log.Printf("Successfully pulled docker images")
+	log.Printf("Successfully pulled %d docker images", len(pulled.Images))
 
-	log.Printf("Starting service containers for: %s", req.Service)
-	if err := s.runDockerCompose(workDir, "up", "-d"); err != nil {
+	log.Printf("Starting service containers for: %s", ws.service)
+	var up *upResult
+	switch sc := s.config.Services[ws.service]; {
+	case sc.strategy() == strategyBlueGreen && ws.preview == 0:
+		up, err = deployBlueGreen(ctx, rt, project, sc)
+	default:
+		up, err = rt.Up(ctx, project)
+	}
+	if err != nil {
 		log.Printf("Failed to start service containers: %v", err)
 		return nil, fmt.Errorf("failed to start service: %w", err)
 	}
-	log.Printf("Successfully started service: %s", req.Service)
+	log.Printf("Successfully started service: %s", ws.service)
 
-	return &squadv1alpha1.StartResponse{}, nil
+	return up, nil
 }
 
-func (s *coachService) runDockerCompose(workDir string, args ...string) error {
-	fullArgs := append([]string{"compose", "-f", "deploy.yaml"}, args...)
-	log.Printf("Running docker command: docker %v", fullArgs)
-	log.Printf("Working directory: %s", workDir)
-	
-	cmd := exec.Command("docker", fullArgs...)
-	cmd.Dir = workDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	
-	if err := cmd.Run(); err != nil {
-		log.Printf("Docker compose command failed: %v", err)
-		return err
+// resolveRef returns the commit SHA of a ref in the infra repo.
+func (s *coachService) resolveRef(ctx context.Context, ref string) (string, error) {
+	sha, _, err := s.github.Repositories.GetCommitSHA1(ctx, "baely", "infra", ref, "")
+	if err != nil {
+		return "", err
 	}
-	
-	log.Printf("Docker compose command completed successfully")
-	return nil
+	return sha, nil
 }
 
-func (s *coachService) downloadServiceConfig(ctx context.Context, serviceName, ref string) (string, error) {
+// environmentsDir holds per-environment files in a service's config. Each
+// environments/<environment>/ directory is laid over the shared config when
+// deploying to that environment, replacing files with the same name.
+const environmentsDir = "environments"
+
+func (s *coachService) downloadServiceConfig(ctx context.Context, ws *workspace, ref, serviceDir string) error {
+	serviceName, environment := ws.service, ws.environment
 	log.Printf("Downloading service config for %s at ref %s", serviceName, ref)
-	client := github.NewClient(nil)
 
 	servicePath := path.Join("docker", serviceName)
 	log.Printf("Fetching contents from GitHub path: %s", servicePath)
-	_, dirContent, _, err := client.Repositories.GetContents(ctx, "baely", "infra", servicePath, &github.RepositoryContentGetOptions{
-		Ref: ref,
-	})
+	files, err := s.listConfigFiles(ctx, servicePath, ".", ref)
 	if err != nil {
 		log.Printf("Failed to get GitHub contents for %s: %v", servicePath, err)
-		return "", fmt.Errorf("failed to get service directory contents: %w", err)
+		return fmt.Errorf("failed to get service directory contents: %w", err)
 	}
 
-	if len(dirContent) == 0 {
+	if len(files) == 0 {
 		log.Printf("No files found in GitHub service directory: %s", servicePath)
-		return "", fmt.Errorf("no files found in service directory %s", servicePath)
+		return fmt.Errorf("no files found in service directory %s", servicePath)
 	}
-	log.Printf("Found %d files in GitHub service directory", len(dirContent))
+	log.Printf("Found %d files in GitHub service directory", len(files))
 
-	tempDir, err := os.MkdirTemp("", fmt.Sprintf("coach-service-%s-", serviceName))
+	downloadedCount := downloadFiles(files, serviceDir)
+	log.Printf("Successfully downloaded %d files from GitHub", downloadedCount)
+
+	environmentDir := path.Join(environmentsDir, environment)
+	log.Printf("Fetching %s config from GitHub path: %s", environment, path.Join(servicePath, environmentDir))
+	environmentFiles, err := s.listConfigFiles(ctx, servicePath, environmentDir, ref)
+	var errResp *github.ErrorResponse
+	switch {
+	case errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound:
+		log.Printf("No %s config in %s", environment, servicePath)
+	case err != nil:
+		log.Printf("Failed to get GitHub contents for %s: %v", path.Join(servicePath, environmentDir), err)
+		return fmt.Errorf("failed to get %s config: %w", environment, err)
+	default:
+		for i := range environmentFiles {
+			environmentFiles[i].path = strings.TrimPrefix(environmentFiles[i].source, environmentDir+"/")
+			if environmentFiles[i].path == provenance.FileName {
+				return status.Errorf(codes.FailedPrecondition, "%s can't replace the service's %s", environmentFiles[i].source, provenance.FileName)
+			}
+		}
+		downloadedCount = downloadFiles(environmentFiles, serviceDir)
+		log.Printf("Successfully downloaded %d %s files from GitHub", downloadedCount, environment)
+	}
+
+	manifest, err := provenance.Read(serviceDir)
 	if err != nil {
-		log.Printf("Failed to create temp directory: %v", err)
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		return status.Errorf(codes.FailedPrecondition, "%s: %v", servicePath, err)
+	}
+	if manifest == nil {
+		if err := requireManifest(ws, servicePath); err != nil {
+			log.Printf("Config for %s can't be verified: %v", serviceName, err)
+			return err
+		}
+		log.Printf("Warning: %s has no %s, its config can't be verified", servicePath, provenance.FileName)
+	} else {
+		log.Printf("Config for %s was synced from %s at %s (%s)", serviceName, manifest.Repository, manifest.Ref, manifest.SHA)
+		deployed := func(source string) bool {
+			return !strings.HasPrefix(source, environmentsDir+"/") || strings.HasPrefix(source, environmentDir+"/")
+		}
+		if err := verifyConfig(manifest, serviceDir, append(files, environmentFiles...), deployed); err != nil {
+			log.Printf("Config for %s failed verification: %v", serviceName, err)
+			return err
+		}
 	}
-	log.Printf("Created temp directory: %s", tempDir)
 
-	serviceDir := filepath.Join(tempDir, serviceName)
-	if err := os.MkdirAll(serviceDir, 0755); err != nil {
-		log.Printf("Failed to create service subdirectory %s: %v", serviceDir, err)
-		return "", fmt.Errorf("failed to create service subdirectory: %w", err)
+	// Services whose secrets aren't committed encrypted yet still get them
+	// from the services directory mounted into Coach.
+	if err := copyMountedServiceFiles(filepath.Join(mountedServicesDir, serviceName), serviceDir); err != nil {
+		log.Printf("Failed to copy mounted service files: %v", err)
+		return fmt.Errorf("failed to copy mounted service files: %w", err)
 	}
-	log.Printf("Created service directory: %s", serviceDir)
 
-	downloadedCount := 0
+	if err := decryptSopsFiles(serviceDir); err != nil {
+		log.Printf("Failed to decrypt service config: %v", err)
+		return fmt.Errorf("failed to decrypt service config: %w", err)
+	}
+
+	log.Printf("Service config setup complete for %s at: %s", serviceName, serviceDir)
+	return nil
+}
+
+// configFile is a file of a service's config in the infra repo.
+type configFile struct {
+	// path is where the file goes in the service directory, e.g. app/config.json.
+	path string
+	// source is the file's path in the service's directory in the infra repo,
+	// e.g. environments/stage/app/config.json.
+	source string
+	url    string
+}
+
+// listConfigFiles returns every file under dir in a service's config at ref,
+// including those in subdirectories. Files keep their path relative to the
+// service directory. The environments directory is skipped, as only the
+// directory of the environment being deployed to is used.
+func (s *coachService) listConfigFiles(ctx context.Context, servicePath, dir, ref string) ([]configFile, error) {
+	_, dirContent, _, err := s.github.Repositories.GetContents(ctx, "baely", "infra", path.Join(servicePath, dir), &github.RepositoryContentGetOptions{
+		Ref: ref,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var files []configFile
 	for _, content := range dirContent {
-		rawURL := content.GetDownloadURL()
-		if rawURL == "" {
-			log.Printf("Skipping file %s (no download URL)", content.GetName())
+		source := path.Join(dir, content.GetName())
+		switch content.GetType() {
+		case "file":
+			files = append(files, configFile{path: source, source: source, url: content.GetDownloadURL()})
+		case "dir":
+			if source == environmentsDir {
+				continue
+			}
+			sub, err := s.listConfigFiles(ctx, servicePath, source, ref)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		}
+	}
+	return files, nil
+}
+
+// downloadFiles downloads files into dir, creating their directories. It
+// returns how many files were downloaded.
+func downloadFiles(files []configFile, dir string) int {
+	downloadedCount := 0
+	for _, file := range files {
+		if file.url == "" {
+			log.Printf("Skipping file %s (no download URL)", file.source)
 			continue
 		}
 
-		filename := filepath.Join(serviceDir, content.GetName())
-		log.Printf("Downloading file: %s -> %s", content.GetName(), filename)
-		if err := downloadFileToPath(rawURL, filename); err != nil {
-			log.Printf("Warning: failed to download file %s: %v", content.GetName(), err)
+		filename := filepath.Join(dir, filepath.FromSlash(file.path))
+		log.Printf("Downloading file: %s -> %s", file.source, filename)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			log.Printf("Warning: failed to create directory for %s: %v", file.source, err)
+			continue
+		}
+		if err := downloadFileToPath(file.url, filename); err != nil {
+			log.Printf("Warning: failed to download file %s: %v", file.source, err)
 			continue
 		}
 		downloadedCount++
 	}
-	log.Printf("Successfully downloaded %d files from GitHub", downloadedCount)
+	return downloadedCount
+}
 
-	// Copy files from mounted services directory if it exists
-	mountedServicePath := fmt.Sprintf("/app/services/%s", serviceName)
-	log.Printf("Checking for mounted service files at: %s", mountedServicePath)
-	if err := copyMountedServiceFiles(mountedServicePath, serviceDir); err != nil {
-		log.Printf("Warning: failed to copy mounted service files from %s: %v", mountedServicePath, err)
-	} else {
-		log.Printf("Successfully copied mounted service files from: %s", mountedServicePath)
+// requireManifest returns an error if the config deployed from a service's
+// workspace was synced by scout. Its new config should have a manifest too,
+// so config without one was most likely written by hand.
+func requireManifest(ws *workspace, servicePath string) error {
+	deployed, err := provenance.Read(ws.dir)
+	if err != nil {
+		return status.Errorf(codes.FailedPrecondition, "deployed config of %s: %v", ws.service, err)
+	}
+	if deployed != nil {
+		return status.Errorf(codes.FailedPrecondition, "%s has no %s, but its deployed config was synced from %s", servicePath, provenance.FileName, deployed.Repository)
+	}
+	return nil
+}
+
+// verifyConfig checks the config in serviceDir against the service's scout
+// manifest once every file is in place, so config edited by hand in the
+// infra repo isn't deployed. files are the files downloaded, in the order
+// they were laid over each other; deployed reports which of the manifest's
+// files should have been among them. Every file in serviceDir must be the
+// last of files written to its path and match the manifest.
+func verifyConfig(manifest *provenance.Manifest, serviceDir string, files []configFile, deployed func(source string) bool) error {
+	listed := make(map[string]bool)
+	sources := make(map[string]string)
+	for _, file := range files {
+		listed[file.source] = true
+		sources[file.path] = file.source
+	}
+
+	var problems []string
+	err := filepath.WalkDir(serviceDir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(serviceDir, filename)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if relPath == provenance.FileName {
+			return nil
+		}
+
+		source, ok := sources[relPath]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s isn't part of the service's config", relPath))
+			return nil
+		}
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if err := manifest.Check(source, b); err != nil {
+			problems = append(problems, err.Error())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to verify config: %w", err)
 	}
 
-	log.Printf("Service config setup complete for %s at: %s", serviceName, serviceDir)
-	return serviceDir, nil
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(serviceDir, filepath.FromSlash(file.path))); err != nil && sources[file.path] == file.source {
+			problems = append(problems, fmt.Sprintf("%s wasn't downloaded", file.source))
+		}
+	}
+	for _, f := range manifest.Files {
+		if deployed(f.Path) && !listed[f.Path] {
+			problems = append(problems, fmt.Sprintf("%s is missing", f.Path))
+		}
+	}
+
+	if len(problems) > 0 {
+		return status.Errorf(codes.FailedPrecondition, "config doesn't match %s: %s", provenance.FileName, strings.Join(problems, "; "))
+	}
+	return nil
 }
 
 func (s *coachService) cloneRepo(repoURL, destDir string) error {
@@ -245,19 +691,6 @@ func (s *coachService) checkoutRef(repoDir, ref string) error {
 	return cmd.Run()
 }
 
-func (s *coachService) buildDockerImage(repoDir, imageName, dockerfile, context string) error {
-	cmd := exec.Command("docker", "build",
-		"--tag", imageName,
-		"--push",
-		"--platform", "linux/amd64",
-		"--file", dockerfile,
-		context)
-	cmd.Dir = repoDir
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 func validateAssembleRequest(req *squadv1alpha1.AssembleRequest) error {
 	if req.Repo == "" {
 		return fmt.Errorf("repo name is required")
@@ -276,15 +709,19 @@ func validateStartRequest(req *squadv1alpha1.StartRequest) error {
 	
 	if req.Service == "" {
 		log.Printf("Validation failed: service name is required")
-		return fmt.Errorf("service name is required")
+		return status.Error(codes.InvalidArgument, "service name is required")
 	}
 	if req.Ref == "" {
 		log.Printf("Validation failed: ref is required")
-		return fmt.Errorf("ref is required")
+		return status.Error(codes.InvalidArgument, "ref is required")
+	}
+	if filepath.Base(req.Service) != req.Service || strings.HasPrefix(req.Service, ".") {
+		log.Printf("Validation failed: invalid service name %q", req.Service)
+		return status.Error(codes.InvalidArgument, "invalid service name")
 	}
 	if req.Service == "github.com_baely_infra" {
 		log.Printf("Validation failed: coach cannot deploy itself")
-		return fmt.Errorf("coach cannot deploy coach")
+		return status.Error(codes.InvalidArgument, "coach cannot deploy coach")
 	}
 	
 	log.Printf("Start request validation successful")
@@ -309,17 +746,21 @@ func getStringOrDefault(ptr *string, defaultValue string) string {
 	return defaultValue
 }
 
-func validateDeployFile(workDir string) error {
-	deployYamlPath := filepath.Join(workDir, "deploy.yaml")
-	log.Printf("Validating deploy file exists at: %s", deployYamlPath)
-	
-	if _, err := os.Stat(deployYamlPath); os.IsNotExist(err) {
-		log.Printf("Deploy file validation failed: deploy.yaml not found at %s", deployYamlPath)
-		return fmt.Errorf("deploy.yaml not found in service directory")
-	}
-	
-	log.Printf("Deploy file validation successful: found deploy.yaml")
-	return nil
+func containersToProto(containers []containerState) []*squadv1alpha1.Container {
+	var out []*squadv1alpha1.Container
+	for _, c := range containers {
+		out = append(out, &squadv1alpha1.Container{
+			Service:     c.Service,
+			Id:          c.ID,
+			Name:        c.Name,
+			Image:       c.Image,
+			ImageDigest: c.Digest,
+			State:       c.State,
+			Health:      c.Health,
+			Action:      string(c.Action),
+		})
+	}
+	return out
 }
 
 func downloadFileToPath(url, filepath string) error {
@@ -341,64 +782,48 @@ func downloadFileToPath(url, filepath string) error {
 	return os.WriteFile(filepath, b, 0644)
 }
 
+// mountedServicesDir is where the host's services directory is mounted into
+// Coach, holding files that aren't committed to the infra repo, e.g. .env.
+const mountedServicesDir = "/app/services"
+
+// copyMountedServiceFiles copies the files of a service's mounted directory
+// into its config. Files from the infra repo take precedence, so only those
+// the repo doesn't have are copied. It does nothing if the service has no
+// mounted directory.
 func copyMountedServiceFiles(sourcePath, destPath string) error {
-	log.Printf("Attempting to copy mounted files from %s to %s", sourcePath, destPath)
-	
-	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-		log.Printf("Mounted service directory does not exist: %s", sourcePath)
+	if _, err := os.Stat(sourcePath); errors.Is(err, fs.ErrNotExist) {
 		return nil
 	}
-	log.Printf("Found mounted service directory: %s", sourcePath)
 
-	copiedFiles := 0
-	copiedDirs := 0
-	
-	err := filepath.Walk(sourcePath, func(path string, info os.FileInfo, err error) error {
+	return filepath.WalkDir(sourcePath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Printf("Error walking path %s: %v", path, err)
 			return err
 		}
-
 		relPath, err := filepath.Rel(sourcePath, path)
 		if err != nil {
-			log.Printf("Error getting relative path for %s: %v", path, err)
 			return err
 		}
-
 		destFile := filepath.Join(destPath, relPath)
 
-		if info.IsDir() {
-			log.Printf("Creating directory: %s", destFile)
-			if err := os.MkdirAll(destFile, info.Mode()); err != nil {
-				log.Printf("Failed to create directory %s: %v", destFile, err)
-				return err
-			}
-			copiedDirs++
+		if d.IsDir() {
+			return os.MkdirAll(destFile, 0755)
+		}
+		if _, err := os.Lstat(destFile); err == nil {
+			log.Printf("Skipping mounted file %s, the infra repo has it", relPath)
 			return nil
 		}
 
-		log.Printf("Copying file: %s -> %s (size: %d bytes)", path, destFile, info.Size())
-		data, err := os.ReadFile(path)
+		info, err := d.Info()
 		if err != nil {
-			log.Printf("Failed to read file %s: %v", path, err)
 			return err
 		}
-
-		if err := os.WriteFile(destFile, data, info.Mode()); err != nil {
-			log.Printf("Failed to write file %s: %v", destFile, err)
+		data, err := os.ReadFile(path)
+		if err != nil {
 			return err
 		}
-		copiedFiles++
-		return nil
+		log.Printf("Copying mounted file: %s -> %s", path, destFile)
+		return os.WriteFile(destFile, data, info.Mode().Perm())
 	})
-	
-	if err != nil {
-		log.Printf("Error during mounted file copy operation: %v", err)
-		return err
-	}
-	
-	log.Printf("Successfully copied %d files and %d directories from mounted service directory", copiedFiles, copiedDirs)
-	return nil
 }
 
 func authInterceptor(expectedToken string) grpc.UnaryServerInterceptor {
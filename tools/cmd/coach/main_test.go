@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	"github.com/baely/infra/tools/internal/provenance"
+)
+
+// fakeRuntime records the projects it is asked to bring up and take down
+// instead of running them. If upErr is set, the next call to Up fails with it.
+type fakeRuntime struct {
+	upErr error
+	up    []*types.Project
+	// down are the projects taken down, in order.
+	down []string
+}
+
+func (r *fakeRuntime) Pull(ctx context.Context, project *types.Project) (*pullResult, error) {
+	return &pullResult{}, nil
+}
+
+func (r *fakeRuntime) Up(ctx context.Context, project *types.Project) (*upResult, error) {
+	r.up = append(r.up, project)
+	if err := r.upErr; err != nil {
+		r.upErr = nil
+		return nil, err
+	}
+	result := &upResult{}
+	for _, name := range project.ServiceNames() {
+		result.Containers = append(result.Containers, containerState{Service: name, Action: containerCreated})
+	}
+	return result, nil
+}
+
+func (r *fakeRuntime) Containers(ctx context.Context, projectName string) ([]containerState, error) {
+	return nil, nil
+}
+
+func (r *fakeRuntime) Down(ctx context.Context, projectName string) error {
+	r.down = append(r.down, projectName)
+	return nil
+}
+
+func (r *fakeRuntime) Plan(ctx context.Context, project *types.Project) (*planResult, error) {
+	return &planResult{}, nil
+}
+
+func (r *fakeRuntime) PruneImages(ctx context.Context, policy imagePolicy, dryRun bool) ([]gcItem, error) {
+	return nil, nil
+}
+
+func (r *fakeRuntime) PruneProjects(ctx context.Context, orphaned func(projectName string) bool, dryRun bool) ([]gcItem, error) {
+	return nil, nil
+}
+
+// testDeploy stages files in the workspace and deploys them to rt.
+func testDeploy(t *testing.T, s *coachService, rt runtime, ws *workspace, files map[string]string) (*upResult, error) {
+	t.Helper()
+	stagingDir, err := ws.stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stagingDir)
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(stagingDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s.deploy(context.Background(), rt, ws, stagingDir, nil)
+}
+
+func newTestWorkspace(t *testing.T) (*coachService, *workspace) {
+	t.Helper()
+	s := &coachService{config: &config{}, stateDir: t.TempDir()}
+	ws, err := createWorkspace(workspaceFor(s.stateDir, defaultEnvironment, "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, ws
+}
+
+func readTestFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDeployCommitsConfig(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	rt := &fakeRuntime{}
+
+	hello := fmt.Sprintf(testDeployFile, "hello")
+	if _, err := testDeploy(t, s, rt, ws, map[string]string{deployFileName: hello, "old.txt": "old"}); err != nil {
+		t.Fatalf("first deploy: %v", err)
+	}
+	goodbye := fmt.Sprintf(testDeployFile, "goodbye")
+	up, err := testDeploy(t, s, rt, ws, map[string]string{deployFileName: goodbye})
+	if err != nil {
+		t.Fatalf("second deploy: %v", err)
+	}
+
+	if len(up.Containers) != 1 || up.Containers[0].Service != "web" {
+		t.Errorf("deploy returned %+v, want the web container", up.Containers)
+	}
+	if got := readTestFile(t, filepath.Join(ws.dir, deployFileName)); got != goodbye {
+		t.Errorf("workspace has %s:\n%s\nwant:\n%s", deployFileName, got, goodbye)
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, "old.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale old.txt wasn't removed: %v", err)
+	}
+
+	project := rt.up[len(rt.up)-1]
+	if project.Name != ws.projectName() {
+		t.Errorf("brought up project %s, want %s", project.Name, ws.projectName())
+	}
+	if got := project.Services["web"].Environment["GREETING"]; got == nil || *got != "goodbye" {
+		t.Errorf("brought up web with GREETING %v, want goodbye", got)
+	}
+}
+
+func TestDeployRollsBackWhenUpFails(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	rt := &fakeRuntime{}
+
+	hello := fmt.Sprintf(testDeployFile, "hello")
+	if _, err := testDeploy(t, s, rt, ws, map[string]string{deployFileName: hello, "old.txt": "old"}); err != nil {
+		t.Fatalf("first deploy: %v", err)
+	}
+
+	upErr := errors.New("port is already allocated")
+	rt.upErr = upErr
+	_, err := testDeploy(t, s, rt, ws, map[string]string{deployFileName: fmt.Sprintf(testDeployFile, "goodbye"), "new.txt": "new"})
+	if err == nil || !errors.Is(err, upErr) {
+		t.Fatalf("second deploy returned %v, want %v", err, upErr)
+	}
+
+	// The failed Up may have removed the service's containers, so the
+	// previous config must have been brought back up.
+	if len(rt.up) != 3 {
+		t.Fatalf("Up was called %d times, want 3", len(rt.up))
+	}
+	if got := rt.up[2].Services["web"].Environment["GREETING"]; got == nil || *got != "hello" {
+		t.Errorf("brought web back up with GREETING %v, want hello", got)
+	}
+
+	if got := readTestFile(t, filepath.Join(ws.dir, deployFileName)); got != hello {
+		t.Errorf("workspace has %s:\n%s\nwant the previous config:\n%s", deployFileName, got, hello)
+	}
+	if got := readTestFile(t, filepath.Join(ws.dir, "old.txt")); got != "old" {
+		t.Errorf("old.txt is %q, want it restored", got)
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, "new.txt")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("new.txt was left in the workspace: %v", err)
+	}
+	managed, err := ws.managedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(managed) != 2 {
+		t.Errorf("workspace manages %v, want the previous config's files", managed)
+	}
+	backups, err := filepath.Glob(filepath.Join(ws.staging, "web-previous-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("backups %v weren't removed", backups)
+	}
+}
+
+func TestDeployDoesNotBringUpWithoutPreviousConfig(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	rt := &fakeRuntime{upErr: errors.New("port is already allocated")}
+
+	if _, err := testDeploy(t, s, rt, ws, map[string]string{deployFileName: fmt.Sprintf(testDeployFile, "hello")}); err == nil {
+		t.Fatalf("deploy succeeded, want it to fail")
+	}
+	if len(rt.up) != 1 {
+		t.Errorf("Up was called %d times, want 1", len(rt.up))
+	}
+	if _, err := os.Stat(filepath.Join(ws.dir, deployFileName)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s was left in the workspace: %v", deployFileName, err)
+	}
+}
+
+func TestCopyMountedServiceFiles(t *testing.T) {
+	mounted := t.TempDir()
+	serviceDir := t.TempDir()
+	for name, content := range map[string]string{".env": "TOKEN=mounted", deployFileName: "mounted", "data/seed.json": "{}"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(mounted, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(mounted, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(serviceDir, deployFileName), []byte("from the repo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := copyMountedServiceFiles(mounted, serviceDir); err != nil {
+		t.Fatalf("copyMountedServiceFiles: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(serviceDir, ".env")); got != "TOKEN=mounted" {
+		t.Errorf(".env is %q, want the mounted file", got)
+	}
+	if got := readTestFile(t, filepath.Join(serviceDir, "data", "seed.json")); got != "{}" {
+		t.Errorf("data/seed.json is %q, want the mounted file", got)
+	}
+	if got := readTestFile(t, filepath.Join(serviceDir, deployFileName)); got != "from the repo" {
+		t.Errorf("%s is %q, want the repo's file", deployFileName, got)
+	}
+
+	if err := copyMountedServiceFiles(filepath.Join(mounted, "missing"), serviceDir); err != nil {
+		t.Errorf("copyMountedServiceFiles without a mounted directory: %v", err)
+	}
+}
+
+func TestRequireManifest(t *testing.T) {
+	_, ws := newTestWorkspace(t)
+	if err := requireManifest(ws, "docker/web"); err != nil {
+		t.Errorf("requireManifest without a deployed manifest: %v", err)
+	}
+
+	manifest := `{"repository": "https://github.com/baely/web", "ref": "main", "sha": "0123456"}`
+	if err := os.WriteFile(filepath.Join(ws.dir, provenance.FileName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := requireManifest(ws, "docker/web"); err == nil {
+		t.Errorf("requireManifest with a deployed manifest succeeded, want an error")
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+	"github.com/pmezard/go-difflib/difflib"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+)
+
+// planTempDirPrefix names the temp directories dry runs stage config in.
+const planTempDirPrefix = "coach-plan-"
+
+// plan reports what deploying the config in stagingDir would change, without
+// committing it to the workspace or touching any containers. The workspace is
+// only read, and may not exist yet.
+func (s *coachService) plan(ctx context.Context, rt runtime, ws *workspace, stagingDir string) (*squadv1alpha1.StartResponse, error) {
+	log.Printf("Planning deployment for service: %s in %s", ws.service, ws.environment)
+
+	// Load the project under the name and paths it would have once committed,
+	// so its containers are compared like for like.
+	project, err := loadProject(ctx, stagingDir, cli.WithName(ws.projectName()))
+	if err != nil {
+		log.Printf("Failed to load project: %v", err)
+		return nil, err
+	}
+	relocateProject(project, stagingDir, ws.dir)
+
+	var result *planResult
+	if s.config.Services[ws.service].strategy() == strategyBlueGreen {
+		result, err = planBlueGreen(ctx, rt, project)
+	} else {
+		result, err = rt.Plan(ctx, project)
+	}
+	if err != nil {
+		log.Printf("Failed to plan deployment: %v", err)
+		return nil, fmt.Errorf("failed to plan deployment: %w", err)
+	}
+
+	sensitive := sensitiveFiles(project)
+	result.ConfigDiff, err = ws.diff(stagingDir, func(relPath string) bool {
+		return sensitive(relPath) || isSopsOutput(stagingDir, relPath) || isSopsOutput(ws.dir, relPath)
+	})
+	if err != nil {
+		log.Printf("Failed to diff config: %v", err)
+		return nil, fmt.Errorf("failed to diff config: %w", err)
+	}
+
+	for _, c := range result.Containers {
+		log.Printf("Plan: container %s would be %s", c.Name, c.Action)
+	}
+	for _, img := range result.Images {
+		log.Printf("Plan: image %s would be pulled", img.Image)
+	}
+
+	return &squadv1alpha1.StartResponse{Plan: planToProto(result)}, nil
+}
+
+func planToProto(result *planResult) *squadv1alpha1.Plan {
+	plan := &squadv1alpha1.Plan{ConfigDiff: result.ConfigDiff}
+	for _, c := range result.Containers {
+		plan.Containers = append(plan.Containers, &squadv1alpha1.PlannedContainer{
+			Service: c.Service,
+			Name:    c.Name,
+			Action:  string(c.Action),
+			Reason:  c.Reason,
+		})
+	}
+	for _, img := range result.Images {
+		plan.Images = append(plan.Images, &squadv1alpha1.PlannedImage{
+			Service:       img.Service,
+			Image:         img.Image,
+			CurrentDigest: img.CurrentDigest,
+			NewDigest:     img.NewDigest,
+		})
+	}
+	return plan
+}
+
+type planResult struct {
+	Containers []plannedContainer
+	// Images are the images whose digest would change when pulled, or that
+	// couldn't be resolved in their registry.
+	Images []plannedImage
+	// ConfigDiff is a unified diff from the deployed config to the new one.
+	ConfigDiff string
+}
+
+type plannedContainer struct {
+	Service string
+	Name    string
+	Action  containerAction
+	// Reason explains the action, e.g. "config changed".
+	Reason string
+}
+
+type plannedImage struct {
+	Service string
+	Image   string
+	// CurrentDigest is the digest of the local image, if there is one.
+	CurrentDigest string
+	// NewDigest is the digest in the registry, if it could be resolved.
+	NewDigest string
+}
+
+// Plan works out what Pull and Up would do to the project's containers
+// without pulling images or changing any containers.
+func (e *dockerEngine) Plan(ctx context.Context, project *types.Project) (*planResult, error) {
+	result := &planResult{}
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		if service.Image == "" {
+			return nil, fmt.Errorf("service %s: no image specified", name)
+		}
+
+		var imageID, currentDigest string
+		img, err := e.client.ImageInspect(ctx, service.Image)
+		switch {
+		case err == nil:
+			imageID = img.ID
+			currentDigest = repoDigest(service.Image, img.RepoDigests)
+		case !errdefs.IsNotFound(err):
+			return nil, fmt.Errorf("failed to inspect image %s: %w", service.Image, err)
+		}
+
+		imageChanged := imageID == ""
+		if service.PullPolicy != types.PullPolicyNever && service.PullPolicy != types.PullPolicyBuild {
+			newDigest, err := e.RemoteDigest(ctx, service.Image)
+			if err != nil {
+				log.Printf("Warning: failed to resolve %s in its registry: %v", service.Image, err)
+			}
+			if newDigest == "" || newDigest != currentDigest {
+				// An image that couldn't be resolved is still pulled, but
+				// only recreates the container if it turns out to have changed.
+				if newDigest != "" {
+					imageChanged = true
+				}
+				result.Images = append(result.Images, plannedImage{
+					Service:       name,
+					Image:         service.Image,
+					CurrentDigest: currentDigest,
+					NewDigest:     newDigest,
+				})
+			}
+		}
+
+		planned, err := e.planService(ctx, project, service, imageID, imageChanged)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		result.Containers = append(result.Containers, *planned)
+	}
+	return result, nil
+}
+
+// planService works out what upService would do with the service's container.
+func (e *dockerEngine) planService(ctx context.Context, project *types.Project, service types.ServiceConfig, imageID string, imageChanged bool) (*plannedContainer, error) {
+	spec, err := newContainerSpec(project, service)
+	if err != nil {
+		return nil, err
+	}
+	planned := &plannedContainer{Service: service.Name, Name: spec.name}
+
+	existing, err := e.serviceContainers(ctx, project.Name, service.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) == 0 {
+		planned.Action = containerCreated
+		planned.Reason = "no existing container"
+		return planned, nil
+	}
+	if imageChanged {
+		planned.Action = containerRecreated
+		planned.Reason = "image changed"
+		return planned, nil
+	}
+
+	hash, err := spec.hash(imageID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range existing {
+		if c.Labels[labelConfigHash] != hash {
+			continue
+		}
+		if c.State != container.StateRunning {
+			planned.Action = containerStarted
+			planned.Reason = fmt.Sprintf("container is %s", c.State)
+			return planned, nil
+		}
+		planned.Action = containerUnchanged
+		return planned, nil
+	}
+
+	planned.Action = containerRecreated
+	planned.Reason = "config changed"
+	return planned, nil
+}
+
+// RemoteDigest returns the digest the registry currently has for ref.
+func (e *dockerEngine) RemoteDigest(ctx context.Context, ref string) (string, error) {
+	auth, err := registryAuth(ref)
+	if err != nil {
+		return "", err
+	}
+
+	inspect, err := e.client.DistributionInspect(ctx, ref, auth)
+	if err != nil {
+		return "", err
+	}
+	return inspect.Descriptor.Digest.String(), nil
+}
+
+// relocateProject rewrites the paths of a project loaded from one directory as
+// if it had been loaded from another, so a project loaded from the staging
+// directory plans the same containers it would have from the workspace.
+func relocateProject(project *types.Project, from, to string) {
+	relocate := func(path string) string {
+		if !isWithin(from, path) {
+			return path
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return path
+		}
+		return filepath.Join(to, rel)
+	}
+
+	project.WorkingDir = relocate(project.WorkingDir)
+	for i, f := range project.ComposeFiles {
+		project.ComposeFiles[i] = relocate(f)
+	}
+	for name, service := range project.Services {
+		for i, v := range service.Volumes {
+			if v.Type == types.VolumeTypeBind {
+				service.Volumes[i].Source = relocate(v.Source)
+			}
+		}
+		for i, f := range service.EnvFiles {
+			service.EnvFiles[i].Path = relocate(f.Path)
+		}
+		project.Services[name] = service
+	}
+	for name, secret := range project.Secrets {
+		secret.File = relocate(secret.File)
+		project.Secrets[name] = secret
+	}
+	for name, config := range project.Configs {
+		config.File = relocate(config.File)
+		project.Configs[name] = config
+	}
+}
+
+// diff returns a unified diff from the workspace's config to the config in
+// stagingDir. Files that redact reports as sensitive are only listed as
+// changed, without their contents.
+func (w *workspace) diff(stagingDir string, redact func(relPath string) bool) (string, error) {
+	previous, err := w.managedFiles()
+	if err != nil {
+		return "", err
+	}
+
+	paths := make(map[string]bool)
+	for _, relPath := range previous {
+		paths[relPath] = true
+	}
+	err = filepath.WalkDir(stagingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(stagingDir, path)
+		if err != nil {
+			return err
+		}
+		paths[relPath] = true
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list staged config: %w", err)
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for relPath := range paths {
+		sorted = append(sorted, relPath)
+	}
+	sort.Strings(sorted)
+
+	var out strings.Builder
+	for _, relPath := range sorted {
+		before, err := readIfExists(filepath.Join(w.dir, relPath))
+		if err != nil {
+			return "", err
+		}
+		after, err := readIfExists(filepath.Join(stagingDir, relPath))
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(before, after) {
+			continue
+		}
+
+		if redact(relPath) {
+			fmt.Fprintf(&out, "Files a/%s and b/%s differ\n", relPath, relPath)
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(before)),
+			B:        difflib.SplitLines(string(after)),
+			FromFile: "a/" + relPath,
+			ToFile:   "b/" + relPath,
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to diff %s: %w", relPath, err)
+		}
+		out.WriteString(diff)
+	}
+	return out.String(), nil
+}
+
+func readIfExists(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// sensitiveFiles returns a function reporting whether a file in the project's
+// directory holds secrets: env files, file secrets and stored secrets.
+func sensitiveFiles(project *types.Project) func(relPath string) bool {
+	sensitive := make(map[string]bool)
+	for _, service := range project.Services {
+		for _, f := range service.EnvFiles {
+			sensitive[filepath.Clean(f.Path)] = true
+		}
+	}
+	for _, secret := range project.Secrets {
+		if secret.File != "" {
+			sensitive[filepath.Clean(secret.File)] = true
+		}
+	}
+
+	return func(relPath string) bool {
+		if filepath.Base(relPath) == ".env" || strings.HasSuffix(relPath, ".env") || isWithin(storedSecretsDir, relPath) {
+			return true
+		}
+		return sensitive[filepath.Join(project.WorkingDir, relPath)]
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+	"github.com/baely/infra/tools/internal/routing"
+)
+
+// previewFileName records a preview in its workspace.
+const previewFileName = ".coach-preview.json"
+
+const (
+	defaultPreviewTTL = 72 * time.Hour
+	// previewExpiryInterval is how often expired previews are removed.
+	previewExpiryInterval = 5 * time.Minute
+)
+
+// preview is a pull request's config deployed as a temporary copy of a service.
+type preview struct {
+	Service     string    `json:"service"`
+	PullRequest int       `json:"pull_request"`
+	Environment string    `json:"environment"`
+	Ref         string    `json:"ref"`
+	SHA         string    `json:"sha"`
+	URLs        []string  `json:"urls,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+func (p *preview) workspace(stateDir string) *workspace {
+	return previewWorkspace(stateDir, p.Environment, p.Service, p.PullRequest)
+}
+
+func (p *preview) toProto() *squadv1alpha1.Preview {
+	return &squadv1alpha1.Preview{
+		Service:     p.Service,
+		PullRequest: int32(p.PullRequest),
+		Ref:         p.Ref,
+		Sha:         p.SHA,
+		Environment: p.Environment,
+		Urls:        p.URLs,
+		CreatedAt:   timestamppb.New(p.CreatedAt),
+		ExpiresAt:   timestamppb.New(p.ExpiresAt),
+	}
+}
+
+func (s *coachService) CreatePreview(ctx context.Context, req *squadv1alpha1.CreatePreviewRequest) (*squadv1alpha1.CreatePreviewResponse, error) {
+	log.Printf("Creating preview of service %s for pull request %d at ref %s", req.Service, req.PullRequest, req.Ref)
+
+	if err := validatePreviewRequest(req.Service, req.PullRequest); err != nil {
+		return nil, err
+	}
+	if req.Ref == "" {
+		return nil, status.Error(codes.InvalidArgument, "ref is required")
+	}
+
+	ttl := s.config.Previews.ttl()
+	if req.Ttl != nil {
+		if ttl = req.Ttl.AsDuration(); ttl <= 0 {
+			return nil, status.Error(codes.InvalidArgument, "ttl must be positive")
+		}
+	}
+
+	environment := s.config.previewEnvironment()
+	rt, err := s.runtimeFor(req.Service, environment, "")
+	if err != nil {
+		log.Printf("Failed to find runtime: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	defer s.locks.lock(req.Service)()
+
+	ws, err := createWorkspace(previewWorkspace(s.stateDir, environment, req.Service, int(req.PullRequest)))
+	if err != nil {
+		log.Printf("Failed to open workspace: %v", err)
+		return nil, fmt.Errorf("failed to open workspace: %w", err)
+	}
+
+	stagingDir, sha, err := s.stageConfig(ctx, ws, req.Ref, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		log.Printf("Cleaning up staging directory: %s", stagingDir)
+		if err := os.RemoveAll(stagingDir); err != nil {
+			log.Printf("Warning: failed to cleanup staging directory %s: %v", stagingDir, err)
+		}
+	}()
+
+	var urls []string
+	up, err := s.deploy(ctx, rt, ws, stagingDir, func(project *types.Project) error {
+		var err error
+		urls, err = previewProject(project, ws.preview)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	p := &preview{
+		Service:     req.Service,
+		PullRequest: int(req.PullRequest),
+		Environment: environment,
+		Ref:         req.Ref,
+		SHA:         sha,
+		URLs:        urls,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	if previous, err := readPreview(ws.dir); err == nil && previous != nil {
+		p.CreatedAt = previous.CreatedAt
+	}
+	if err := writePreview(ws.dir, p); err != nil {
+		// Without a record the preview would never expire, so take it down again.
+		log.Printf("Failed to record preview: %v", err)
+		if err := s.removePreview(ctx, p); err != nil {
+			log.Printf("Warning: failed to remove unrecorded preview: %v", err)
+		}
+		return nil, fmt.Errorf("failed to record preview: %w", err)
+	}
+
+	log.Printf("Preview of %s for pull request %d is up at %s until %s", p.Service, p.PullRequest, strings.Join(urls, ", "), p.ExpiresAt.Format(time.RFC3339))
+	return &squadv1alpha1.CreatePreviewResponse{
+		Preview:    p.toProto(),
+		Containers: containersToProto(up.Containers),
+	}, nil
+}
+
+func (s *coachService) DeletePreview(ctx context.Context, req *squadv1alpha1.DeletePreviewRequest) (*squadv1alpha1.DeletePreviewResponse, error) {
+	log.Printf("Deleting preview of service %s for pull request %d", req.Service, req.PullRequest)
+
+	if err := validatePreviewRequest(req.Service, req.PullRequest); err != nil {
+		return nil, err
+	}
+
+	defer s.locks.lock(req.Service)()
+
+	// The environment doesn't change where the workspace is.
+	p, err := readPreview(previewWorkspace(s.stateDir, "", req.Service, int(req.PullRequest)).dir)
+	if err != nil {
+		log.Printf("Failed to read preview: %v", err)
+		return nil, fmt.Errorf("failed to read preview: %w", err)
+	}
+	if p == nil {
+		return nil, status.Errorf(codes.NotFound, "no preview of %s for pull request %d", req.Service, req.PullRequest)
+	}
+
+	if err := s.removePreview(ctx, p); err != nil {
+		log.Printf("Failed to delete preview: %v", err)
+		return nil, fmt.Errorf("failed to delete preview: %w", err)
+	}
+	return &squadv1alpha1.DeletePreviewResponse{}, nil
+}
+
+func (s *coachService) ListPreviews(ctx context.Context, req *squadv1alpha1.ListPreviewsRequest) (*squadv1alpha1.ListPreviewsResponse, error) {
+	log.Printf("Listing previews")
+
+	previews, err := listPreviews(s.stateDir)
+	if err != nil {
+		log.Printf("Failed to list previews: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to list previews: %v", err)
+	}
+
+	resp := &squadv1alpha1.ListPreviewsResponse{}
+	for _, p := range previews {
+		if req.Service != "" && p.Service != req.Service {
+			continue
+		}
+		resp.Previews = append(resp.Previews, p.toProto())
+	}
+	return resp, nil
+}
+
+func validatePreviewRequest(service string, pullRequest int32) error {
+	if service == "" || filepath.Base(service) != service || strings.HasPrefix(service, ".") {
+		return status.Error(codes.InvalidArgument, "invalid service name")
+	}
+	if service == "github.com_baely_infra" {
+		return status.Error(codes.InvalidArgument, "coach cannot preview coach")
+	}
+	if pullRequest <= 0 {
+		return status.Error(codes.InvalidArgument, "pull request number is required")
+	}
+	return nil
+}
+
+// previewProject turns a project into a preview of a pull request: every
+// traefik router and service is renamed with a -pr-<number> suffix and every
+// host name is prefixed with pr-<number>., so the preview is routed next to
+// the service instead of replacing it. A router that can't be moved to its
+// own host, e.g. one matching an IP address or no host at all, would share
+// the service's traffic, so the project can't be previewed. It returns the
+// preview's URLs, once each.
+func previewProject(project *types.Project, pullRequest int) ([]string, error) {
+	suffix := fmt.Sprintf("-pr-%d", pullRequest)
+	hostPrefix := fmt.Sprintf("pr-%d.", pullRequest)
+
+	var urls []string
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		if service.ContainerName != "" {
+			return nil, fmt.Errorf("service %s: container_name can't be used in a preview", name)
+		}
+		if len(service.Ports) > 0 {
+			return nil, fmt.Errorf("service %s: published ports can't be used in a preview", name)
+		}
+
+		for _, router := range routing.Routers(name, routing.Labels(service.Labels)) {
+			if router.Protocol == "tcp" && strings.Contains(router.Rule, "HostSNI(`*`)") {
+				return nil, fmt.Errorf("service %s: router %s takes all of its entrypoint's traffic and can't be previewed", name, router.ID())
+			}
+			if !router.HostBound() {
+				return nil, fmt.Errorf("service %s: router %s doesn't only match host names, so its preview would share the service's traffic", name, router.ID())
+			}
+		}
+
+		labels := routing.Rename(routing.Labels(service.Labels), suffix, hostPrefix)
+		for _, router := range routing.Routers(name, labels) {
+			if router.Protocol != "http" {
+				continue
+			}
+			for _, host := range routing.RuleHosts(router.Rule) {
+				urls = append(urls, "https://"+host)
+			}
+		}
+		service.Labels = types.Labels(labels)
+		project.Services[name] = service
+	}
+
+	sort.Strings(urls)
+	return slices.Compact(urls), nil
+}
+
+// removePreview stops a preview's containers and removes its workspace. Its
+// networks and volumes are left for GC.
+func (s *coachService) removePreview(ctx context.Context, p *preview) error {
+	ws := p.workspace(s.stateDir)
+
+	rt, err := s.runtimeFor(p.Service, p.Environment, "")
+	if err != nil {
+		return err
+	}
+	if err := rt.Down(ctx, ws.projectName()); err != nil {
+		return fmt.Errorf("failed to stop preview: %w", err)
+	}
+
+	if err := os.RemoveAll(ws.dir); err != nil {
+		return fmt.Errorf("failed to remove preview workspace: %w", err)
+	}
+	log.Printf("Removed preview of %s for pull request %d", p.Service, p.PullRequest)
+	return nil
+}
+
+// expirePreviews removes previews once their TTL has passed, until ctx is done.
+func (s *coachService) expirePreviews(ctx context.Context) {
+	ticker := time.NewTicker(previewExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		previews, err := listPreviews(s.stateDir)
+		if err != nil {
+			log.Printf("Warning: failed to list previews: %v", err)
+		}
+		for _, p := range previews {
+			if time.Now().Before(p.ExpiresAt) {
+				continue
+			}
+			if err := s.expirePreview(ctx, p); err != nil {
+				log.Printf("Warning: failed to remove expired preview: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirePreview removes a preview if it has still expired once no deploy of
+// its service is running, as CreatePreview may have just refreshed it.
+func (s *coachService) expirePreview(ctx context.Context, p *preview) error {
+	defer s.locks.lock(p.Service)()
+
+	current, err := readPreview(p.workspace(s.stateDir).dir)
+	if err != nil {
+		return err
+	}
+	if current == nil || time.Now().Before(current.ExpiresAt) {
+		return nil
+	}
+	log.Printf("Preview of %s for pull request %d expired at %s", current.Service, current.PullRequest, current.ExpiresAt.Format(time.RFC3339))
+	return s.removePreview(ctx, current)
+}
+
+// listPreviews returns every preview, sorted by service and pull request.
+func listPreviews(stateDir string) ([]*preview, error) {
+	files, err := filepath.Glob(filepath.Join(stateDir, "previews", "*", "pr-*", previewFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var previews []*preview
+	for _, file := range files {
+		p, err := readPreview(filepath.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		if p != nil {
+			previews = append(previews, p)
+		}
+	}
+
+	sort.Slice(previews, func(i, j int) bool {
+		if previews[i].Service != previews[j].Service {
+			return previews[i].Service < previews[j].Service
+		}
+		return previews[i].PullRequest < previews[j].PullRequest
+	})
+	return previews, nil
+}
+
+// readPreview returns the preview recorded in dir, or nil if there isn't one.
+func readPreview(dir string) (*preview, error) {
+	b, err := os.ReadFile(filepath.Join(dir, previewFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview: %w", err)
+	}
+
+	p := &preview{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("failed to parse preview %s: %w", dir, err)
+	}
+	return p, nil
+}
+
+func writePreview(dir string, p *preview) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, previewFileName), b, 0644)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/types"
+
+	"github.com/baely/infra/tools/internal/routing"
+)
+
+// loadServiceProject loads a service's deploy.yaml from the repo's docker directory.
+func loadServiceProject(t *testing.T, service string) *types.Project {
+	t.Helper()
+	project, err := loadProject(context.Background(), filepath.Join("..", "..", "..", "docker", service))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+func TestPreviewProjectRenamesRouters(t *testing.T) {
+	project := loadServiceProject(t, "github.com_baely_blog")
+
+	urls, err := previewProject(project, 12)
+	if err != nil {
+		t.Fatalf("previewProject: %v", err)
+	}
+	if want := []string{"https://pr-12.blog.baileys.dev"}; !slices.Equal(urls, want) {
+		t.Errorf("got URLs %v, want %v", urls, want)
+	}
+
+	labels := routing.Labels(project.Services["blog"].Labels)
+	if got, want := labels["traefik.http.routers.blog-pr-12.rule"], "Host(`pr-12.blog.baileys.dev`)"; got != want {
+		t.Errorf("preview router rule is %q, want %q", got, want)
+	}
+	if _, ok := labels["traefik.http.routers.blog.rule"]; ok {
+		t.Errorf("preview still declares the service's router")
+	}
+	if got := labels["traefik.http.services.blog-pr-12.loadbalancer.server.port"]; got != "80" {
+		t.Errorf("preview service port is %q, want 80", got)
+	}
+}
+
+func TestPreviewProjectRejectsSharedTraffic(t *testing.T) {
+	tests := []struct {
+		name    string
+		project func(t *testing.T) *types.Project
+		want    string
+	}{
+		{
+			name: "ip host",
+			project: func(t *testing.T) *types.Project {
+				return loadServiceProject(t, "github.com_baely_ip")
+			},
+			want: "router http/ip",
+		},
+		{
+			name: "HostSNI wildcard",
+			project: func(t *testing.T) *types.Project {
+				return loadServiceProject(t, "github.com_baely_sh")
+			},
+			want: "takes all of its entrypoint's traffic",
+		},
+		{
+			name: "path prefix without a host",
+			project: func(t *testing.T) *types.Project {
+				return labelledProject("traefik.http.routers.web.rule=PathPrefix(`/api`)")
+			},
+			want: "router http/web",
+		},
+		{
+			name: "host or path prefix",
+			project: func(t *testing.T) *types.Project {
+				return labelledProject("traefik.http.routers.web.rule=Host(`web.baileys.dev`) || PathPrefix(`/api`)")
+			},
+			want: "router http/web",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, err := previewProject(tt.project(t), 12)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("previewProject returned URLs %v and error %v, want an error containing %q", urls, err, tt.want)
+			}
+		})
+	}
+}
+
+// labelledProject is a project with a single service, web, with the given labels.
+func labelledProject(labels ...string) *types.Project {
+	service := types.ServiceConfig{Name: "web", Image: "ghcr.io/baely/web:latest", Labels: types.Labels{}}
+	for _, label := range labels {
+		k, v, _ := strings.Cut(label, "=")
+		service.Labels[k] = v
+	}
+	return &types.Project{Name: "web", Services: types.Services{"web": service}}
+}
+
+func TestPreviewProjectRenamesEveryRouter(t *testing.T) {
+	project := labelledProject(
+		"traefik.http.routers.web.rule=Host(`web.baileys.dev`) || Host(`www.baileys.dev`)",
+		"traefik.http.routers.web.service=web",
+		"traefik.http.routers.web.tls.domains[0].main=web.baileys.dev",
+		"traefik.http.routers.api.rule=Host(`web.baileys.dev`) && PathPrefix(`/api`)",
+		"traefik.http.services.web.loadbalancer.server.port=8080",
+		"traefik.tcp.routers.db.rule=HostSNI(`db.baileys.dev`)",
+	)
+
+	urls, err := previewProject(project, 3)
+	if err != nil {
+		t.Fatalf("previewProject: %v", err)
+	}
+	if want := []string{"https://pr-3.web.baileys.dev", "https://pr-3.www.baileys.dev"}; !slices.Equal(urls, want) {
+		t.Errorf("got URLs %v, want %v", urls, want)
+	}
+
+	want := routing.Labels{
+		"traefik.http.routers.web-pr-3.rule":                      "Host(`pr-3.web.baileys.dev`) || Host(`pr-3.www.baileys.dev`)",
+		"traefik.http.routers.web-pr-3.service":                   "web-pr-3",
+		"traefik.http.routers.web-pr-3.tls.domains[0].main":       "pr-3.web.baileys.dev",
+		"traefik.http.routers.api-pr-3.rule":                      "Host(`pr-3.web.baileys.dev`) && PathPrefix(`/api`)",
+		"traefik.http.services.web-pr-3.loadbalancer.server.port": "8080",
+		"traefik.tcp.routers.db-pr-3.rule":                        "HostSNI(`pr-3.db.baileys.dev`)",
+	}
+	if got := routing.Labels(project.Services["web"].Labels); !maps.Equal(got, want) {
+		t.Errorf("preview labels are %v, want %v", got, want)
+	}
+}
+
+func TestPreviewProjectRejectsFixedNames(t *testing.T) {
+	named := labelledProject()
+	web := named.Services["web"]
+	web.ContainerName = "web"
+	named.Services["web"] = web
+	if _, err := previewProject(named, 3); err == nil || !strings.Contains(err.Error(), "container_name") {
+		t.Errorf("previewProject with a container_name returned %v, want a container_name error", err)
+	}
+
+	published := labelledProject()
+	web = published.Services["web"]
+	web.Ports = []types.ServicePortConfig{{Target: 80, Published: "8080"}}
+	published.Services["web"] = web
+	if _, err := previewProject(published, 3); err == nil || !strings.Contains(err.Error(), "published ports") {
+		t.Errorf("previewProject with published ports returned %v, want a published ports error", err)
+	}
+}
+
+func TestValidatePreviewRequest(t *testing.T) {
+	tests := []struct {
+		service     string
+		pullRequest int32
+		wantErr     bool
+	}{
+		{"github.com_baely_blog", 12, false},
+		{"", 12, true},
+		{"../blog", 12, true},
+		{".hidden", 12, true},
+		{"github.com_baely_infra", 12, true},
+		{"github.com_baely_blog", 0, true},
+	}
+
+	for _, tt := range tests {
+		if err := validatePreviewRequest(tt.service, tt.pullRequest); (err != nil) != tt.wantErr {
+			t.Errorf("validatePreviewRequest(%q, %d) = %v, want error %v", tt.service, tt.pullRequest, err, tt.wantErr)
+		}
+	}
+}
+
+func TestListPreviews(t *testing.T) {
+	stateDir := t.TempDir()
+	if previews, err := listPreviews(stateDir); err != nil || len(previews) != 0 {
+		t.Fatalf("listPreviews without previews = %v, %v, want none", previews, err)
+	}
+
+	created := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	for _, p := range []*preview{
+		{Service: "web", PullRequest: 12, Environment: "stage", CreatedAt: created, ExpiresAt: created.Add(defaultPreviewTTL)},
+		{Service: "blog", PullRequest: 3, Environment: defaultEnvironment, URLs: []string{"https://pr-3.blog.baileys.dev"}, CreatedAt: created, ExpiresAt: created.Add(defaultPreviewTTL)},
+		{Service: "web", PullRequest: 2, Environment: defaultEnvironment, CreatedAt: created, ExpiresAt: created.Add(defaultPreviewTTL)},
+	} {
+		ws, err := createWorkspace(p.workspace(stateDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writePreview(ws.dir, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previews, err := listPreviews(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range previews {
+		got = append(got, fmt.Sprintf("%s#%d", p.Service, p.PullRequest))
+	}
+	if want := []string{"blog#3", "web#2", "web#12"}; !slices.Equal(got, want) {
+		t.Errorf("listPreviews = %v, want %v", got, want)
+	}
+	if p := previews[0]; !slices.Equal(p.URLs, []string{"https://pr-3.blog.baileys.dev"}) || !p.ExpiresAt.Equal(created.Add(defaultPreviewTTL)) {
+		t.Errorf("listPreviews read %+v, want the preview that was written", p)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+)
+
+// Promote deploys the commit a service was last deployed from in one
+// environment to another. The service must be running in the environment it
+// is promoted from, so only a ref that has been verified there moves forward.
+func (s *coachService) Promote(ctx context.Context, req *squadv1alpha1.PromoteRequest) (*squadv1alpha1.PromoteResponse, error) {
+	log.Printf("Promoting service %s from %s to %s, dry run: %t", req.Service, req.From, req.To, req.DryRun)
+
+	if req.Service == "" || filepath.Base(req.Service) != req.Service || strings.HasPrefix(req.Service, ".") {
+		return nil, status.Error(codes.InvalidArgument, "invalid service name")
+	}
+	if req.From == "" || req.To == "" {
+		return nil, status.Error(codes.InvalidArgument, "from and to environments are required")
+	}
+	if req.From == req.To {
+		return nil, status.Error(codes.InvalidArgument, "from and to environments must differ")
+	}
+	for _, environment := range []string{req.From, req.To} {
+		if !s.config.hasEnvironment(environment) {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown environment %q", environment)
+		}
+	}
+
+	ws := workspaceFor(s.stateDir, req.From, req.Service)
+	d, err := ws.deployment()
+	if err != nil {
+		log.Printf("Failed to read deployment: %v", err)
+		return nil, status.Errorf(codes.Internal, "failed to read %s deployment: %v", req.From, err)
+	}
+	if d == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "service %s has not been deployed to %s", req.Service, req.From)
+	}
+	if d.SHA == "" {
+		return nil, status.Errorf(codes.FailedPrecondition, "the %s deployment of %s has no recorded commit; deploy it again first", req.From, req.Service)
+	}
+
+	if _, st := s.liveStatus(ctx, ws, d.Host); st != statusRunning {
+		return nil, status.Errorf(codes.FailedPrecondition, "service %s is %s in %s; only running deployments can be promoted", req.Service, st, req.From)
+	}
+
+	log.Printf("Promoting %s at %s (deployed to %s from %s)", req.Service, d.SHA, req.From, d.Ref)
+	resp, err := s.Start(ctx, &squadv1alpha1.StartRequest{
+		Service:     req.Service,
+		Ref:         d.SHA,
+		Environment: req.To,
+		DryRun:      req.DryRun,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &squadv1alpha1.PromoteResponse{
+		Sha:        d.SHA,
+		Containers: resp.Containers,
+		Plan:       resp.Plan,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/docker/docker/client"
+)
+
+// Runtime types that can be configured.
+const (
+	runtimeDocker = "docker"
+	runtimePodman = "podman"
+)
+
+// defaultPodmanHost is the rootful podman socket. Rootless podman listens on
+// $XDG_RUNTIME_DIR/podman/podman.sock instead, which has to be configured explicitly.
+const defaultPodmanHost = "unix:///run/podman/podman.sock"
+
+// runtime is a backend that services can be deployed to.
+type runtime interface {
+	// Pull pulls the image of every service in the project.
+	Pull(ctx context.Context, project *types.Project) (*pullResult, error)
+	// Up creates or recreates the project's containers so they match its config.
+	Up(ctx context.Context, project *types.Project) (*upResult, error)
+	// Containers returns the state of every container in the named project.
+	Containers(ctx context.Context, projectName string) ([]containerState, error)
+	// Down stops and removes every container in the named project.
+	Down(ctx context.Context, projectName string) error
+	// Plan works out what Pull and Up would change without changing anything.
+	Plan(ctx context.Context, project *types.Project) (*planResult, error)
+	// PruneImages removes the images the policy doesn't keep and returns them.
+	// With dryRun it only returns them.
+	PruneImages(ctx context.Context, policy imagePolicy, dryRun bool) ([]gcItem, error)
+	// PruneProjects removes the networks and volumes of the projects orphaned
+	// reports, once they have no containers left. With dryRun it only returns them.
+	PruneProjects(ctx context.Context, orphaned func(projectName string) bool, dryRun bool) ([]gcItem, error)
+}
+
+// newRuntimes creates every configured runtime. The docker runtime is always
+// available and defaults to the daemon from the environment.
+func newRuntimes(cfg *config, defaultEngine *dockerEngine) (map[string]runtime, error) {
+	runtimes := map[string]runtime{
+		runtimeDocker: defaultEngine,
+	}
+
+	for name, rc := range cfg.Runtimes {
+		r, err := newRuntime(rc)
+		if err != nil {
+			return nil, fmt.Errorf("runtime %s: %w", name, err)
+		}
+		log.Printf("Configured %s runtime %s", rc.Type, name)
+		runtimes[name] = r
+	}
+
+	return runtimes, nil
+}
+
+func newRuntime(rc runtimeConfig) (runtime, error) {
+	switch rc.Type {
+	case runtimeDocker:
+		if rc.Host == "" {
+			return newDockerEngine()
+		}
+		return newDockerEngine(client.WithHost(rc.Host))
+	case runtimePodman:
+		return newPodmanRuntime(rc.Host)
+	default:
+		return nil, fmt.Errorf("unknown runtime type %q", rc.Type)
+	}
+}
+
+// newPodmanRuntime deploys to podman through its Docker-compatible API socket,
+// so services are managed exactly as they are on docker.
+func newPodmanRuntime(host string) (runtime, error) {
+	if host == "" {
+		host = defaultPodmanHost
+	}
+	return newDockerEngine(client.WithHost(host))
+}
+
+// runtimeFor returns the runtime a service is deployed to in an environment.
+// host overrides the configured host when it is set.
+func (s *coachService) runtimeFor(service, environment, host string) (runtime, error) {
+	sc := s.config.Services[service]
+	host = s.hostFor(service, environment, host)
+
+	if host != localHost {
+		r, ok := s.hosts[host]
+		if !ok {
+			return nil, fmt.Errorf("unknown host %q", host)
+		}
+		return r, nil
+	}
+
+	name := sc.Runtime
+	if name == "" {
+		name = runtimeDocker
+	}
+
+	r, ok := s.runtimes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown runtime %q", name)
+	}
+	return r, nil
+}
+
+// hostFor returns the name of the host a service is deployed to in an
+// environment: host if it is set, then the environment's host, then the
+// service's configured host.
+func (s *coachService) hostFor(service, environment, host string) string {
+	if host == "" {
+		host = s.config.Environments[environment].Host
+	}
+	if host == "" {
+		host = s.config.Services[service].Host
+	}
+	if host == "" {
+		host = localHost
+	}
+	return host
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/compose-spec/compose-go/v2/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	squadv1alpha1 "github.com/baely/infra/tools/gen/squad/v1alpha1"
+)
+
+const (
+	// storedSecretsDir is where a service's stored secrets are written in its
+	// workspace for the deploy to use.
+	storedSecretsDir = ".coach-secrets"
+	// storedSecretsEnvFile holds every stored secret that can be used as an
+	// environment variable, relative to the workspace.
+	storedSecretsEnvFile = storedSecretsDir + "/secrets.env"
+
+	secretFileExt = ".age"
+)
+
+var (
+	secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+	envNamePattern    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// secretStore keeps each service's secrets as age-encrypted files under
+// <dir>/<service>/<name>.age, encrypted to Coach's own age identity.
+type secretStore struct {
+	dir        string
+	identities []age.Identity
+	recipient  age.Recipient
+}
+
+// storedSecret describes a secret without its value.
+type storedSecret struct {
+	Name      string
+	UpdatedAt time.Time
+}
+
+// newSecretStore opens the store in dir with the age identities in keyFile.
+// Secrets are encrypted to the first identity; the others can only decrypt,
+// so keys can be rotated by putting the new one first.
+func newSecretStore(dir, keyFile string) (*secretStore, error) {
+	identities, err := readAgeIdentities(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	x25519, ok := identities[0].(*age.X25519Identity)
+	if !ok {
+		return nil, fmt.Errorf("first identity in %s is not an X25519 identity", keyFile)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	return &secretStore{
+		dir:        dir,
+		identities: identities,
+		recipient:  x25519.Recipient(),
+	}, nil
+}
+
+func readAgeIdentities(keyFile string) ([]age.Identity, error) {
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open age key: %w", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age key %s: %w", keyFile, err)
+	}
+	return identities, nil
+}
+
+func validateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q", name)
+	}
+	return nil
+}
+
+func (s *secretStore) path(service, name string) string {
+	return filepath.Join(s.dir, service, name+secretFileExt)
+}
+
+func (s *secretStore) Set(service, name string, value []byte) error {
+	if err := os.MkdirAll(filepath.Join(s.dir, service), 0700); err != nil {
+		return fmt.Errorf("failed to create secret directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, s.recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	if _, err := w.Write(value); err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	return writeFileAtomic(s.path(service, name), buf.Bytes(), 0600)
+}
+
+func (s *secretStore) Get(service, name string) ([]byte, error) {
+	b, err := os.ReadFile(s.path(service, name))
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(b), s.identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+	}
+	return io.ReadAll(r)
+}
+
+// List returns the service's secrets sorted by name.
+func (s *secretStore) List(service string) ([]storedSecret, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, service))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	var secrets []storedSecret
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), secretFileExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, storedSecret{Name: name, UpdatedAt: info.ModTime()})
+	}
+
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+func (s *secretStore) Delete(service, name string) error {
+	return os.Remove(s.path(service, name))
+}
+
+// materialize decrypts every secret of the service into dir/.coach-secrets,
+// one file per secret, plus an env file of those that can be used as
+// environment variables.
+func (s *secretStore) materialize(service, dir string) error {
+	secrets, err := s.List(service)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	secretsDir := filepath.Join(dir, storedSecretsDir)
+	if err := os.MkdirAll(secretsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", secretsDir, err)
+	}
+
+	var env strings.Builder
+	for _, secret := range secrets {
+		value, err := s.Get(service, secret.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(secretsDir, secret.Name), value, 0600); err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", secret.Name, err)
+		}
+
+		// Values are single quoted so they are used as is, without interpolation.
+		if !envNamePattern.MatchString(secret.Name) {
+			log.Printf("Secret %s of %s is not a valid environment variable name; it is only written to %s/%s", secret.Name, service, storedSecretsDir, secret.Name)
+			continue
+		}
+		if bytes.ContainsAny(value, "'\n") {
+			log.Printf("Secret %s of %s contains a quote or newline; it is only written to %s/%s", secret.Name, service, storedSecretsDir, secret.Name)
+			continue
+		}
+		fmt.Fprintf(&env, "%s='%s'\n", secret.Name, value)
+	}
+
+	return os.WriteFile(filepath.Join(dir, storedSecretsEnvFile), []byte(env.String()), 0600)
+}
+
+// useStoredSecrets points the project's external secrets at the files the
+// secret store writes into the workspace.
+func useStoredSecrets(project *types.Project) {
+	for name, secret := range project.Secrets {
+		if !secret.External {
+			continue
+		}
+		secret.External = false
+		secret.File = filepath.Join(project.WorkingDir, storedSecretsDir, name)
+		project.Secrets[name] = secret
+	}
+}
+
+func (s *coachService) secretStore() (*secretStore, error) {
+	if s.secrets == nil {
+		return nil, status.Error(codes.FailedPrecondition, "secret store is not configured (COACH_AGE_KEY_FILE is not set)")
+	}
+	return s.secrets, nil
+}
+
+func validateSecretService(service string) error {
+	if service == "" {
+		return status.Error(codes.InvalidArgument, "service name is required")
+	}
+	if filepath.Base(service) != service || strings.HasPrefix(service, ".") {
+		return status.Error(codes.InvalidArgument, "invalid service name")
+	}
+	return nil
+}
+
+func validateSecretRequest(service, name string) error {
+	if err := validateSecretService(service); err != nil {
+		return err
+	}
+	if err := validateSecretName(name); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+func (s *coachService) SetSecret(ctx context.Context, req *squadv1alpha1.SetSecretRequest) (*squadv1alpha1.SetSecretResponse, error) {
+	log.Printf("Setting secret %s for service %s", req.Name, req.Service)
+
+	if err := validateSecretRequest(req.Service, req.Name); err != nil {
+		return nil, err
+	}
+	store, err := s.secretStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Set(req.Service, req.Name, req.Value); err != nil {
+		log.Printf("Failed to set secret: %v", err)
+		return nil, fmt.Errorf("failed to set secret: %w", err)
+	}
+
+	log.Printf("Secret %s set for service %s", req.Name, req.Service)
+	return &squadv1alpha1.SetSecretResponse{}, nil
+}
+
+func (s *coachService) ListSecrets(ctx context.Context, req *squadv1alpha1.ListSecretsRequest) (*squadv1alpha1.ListSecretsResponse, error) {
+	log.Printf("Listing secrets for service %s", req.Service)
+
+	if err := validateSecretService(req.Service); err != nil {
+		return nil, err
+	}
+	store, err := s.secretStore()
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := store.List(req.Service)
+	if err != nil {
+		log.Printf("Failed to list secrets: %v", err)
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	resp := &squadv1alpha1.ListSecretsResponse{}
+	for _, secret := range secrets {
+		resp.Secrets = append(resp.Secrets, &squadv1alpha1.SecretInfo{
+			Name:      secret.Name,
+			UpdatedAt: timestamppb.New(secret.UpdatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (s *coachService) DeleteSecret(ctx context.Context, req *squadv1alpha1.DeleteSecretRequest) (*squadv1alpha1.DeleteSecretResponse, error) {
+	log.Printf("Deleting secret %s for service %s", req.Name, req.Service)
+
+	if err := validateSecretRequest(req.Service, req.Name); err != nil {
+		return nil, err
+	}
+	store, err := s.secretStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Delete(req.Service, req.Name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, status.Errorf(codes.NotFound, "secret %s not found", req.Name)
+		}
+		log.Printf("Failed to delete secret: %v", err)
+		return nil, fmt.Errorf("failed to delete secret: %w", err)
+	}
+
+	log.Printf("Secret %s deleted for service %s; it is removed from the service on its next deploy", req.Name, req.Service)
+	return &squadv1alpha1.DeleteSecretResponse{}, nil
+}
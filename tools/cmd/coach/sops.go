@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/getsops/sops/v3/decrypt"
+
+	"github.com/baely/infra/tools/internal/sopsfile"
+)
+
+// decryptSopsFiles decrypts every SOPS-encrypted file in dir next to it. The
+// encrypted files are kept, so the workspace mirrors the repo.
+//
+// SOPS finds the age key through SOPS_AGE_KEY_FILE, which defaults to
+// COACH_AGE_KEY_FILE.
+func decryptSopsFiles(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		output, format, ok := sopsfile.Decrypted(d.Name())
+		if !ok {
+			return nil
+		}
+
+		if os.Getenv("SOPS_AGE_KEY_FILE") == "" && os.Getenv("SOPS_AGE_KEY") == "" {
+			return fmt.Errorf("%s is encrypted but no age key is configured", d.Name())
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", d.Name(), err)
+		}
+
+		cleartext, err := decrypt.Data(data, format)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", d.Name(), err)
+		}
+
+		outputPath := filepath.Join(filepath.Dir(path), output)
+		log.Printf("Decrypted %s -> %s", d.Name(), output)
+		if err := os.WriteFile(outputPath, cleartext, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		return nil
+	})
+}
+
+// isSopsOutput reports whether a file in dir was decrypted from a SOPS-encrypted file.
+func isSopsOutput(dir, relPath string) bool {
+	for _, source := range sopsfile.Sources(filepath.Base(relPath)) {
+		if _, err := os.Stat(filepath.Join(dir, filepath.Dir(relPath), source)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/baely/infra/tools/internal/templatefuncs"
+)
+
+const (
+	// templateExt marks the config files that are rendered at Start, e.g.
+	// deploy.yaml.tmpl is rendered to deploy.yaml. Files without it are
+	// deployed as they are, so a literal {{ in them needs no escaping.
+	templateExt = ".tmpl"
+	// varsFileName holds template variables. Each environment can replace it
+	// with its own in environments/<environment>/vars.yaml.
+	varsFileName = "vars.yaml"
+)
+
+// templateData is what *.tmpl files are rendered with.
+type templateData struct {
+	// SHA is the infra repo commit the config was downloaded from, not the
+	// commit of the service's own repository; scout's {{sha}} placeholder is that.
+	SHA         string
+	Service     string
+	Host        string
+	Environment string
+	// Domain is the environment's configured domain.
+	Domain string
+	// Vars are read from vars.yaml.
+	Vars map[string]any
+}
+
+func (d templateData) values() map[string]any {
+	shortSHA := d.SHA
+	if len(shortSHA) > 7 {
+		shortSHA = shortSHA[:7]
+	}
+
+	vars := d.Vars
+	if vars == nil {
+		vars = map[string]any{}
+	}
+
+	return map[string]any{
+		"sha":         d.SHA,
+		"short_sha":   shortSHA,
+		"service":     d.Service,
+		"host":        d.Host,
+		"environment": d.Environment,
+		"domain":      d.Domain,
+		"vars":        vars,
+	}
+}
+
+// templateFuncs is the fixed set of functions templates can use, one for
+// each of templatefuncs.Names.
+func templateFuncs(ctx context.Context, e engine) template.FuncMap {
+	return template.FuncMap{
+		// digest returns the registry digest of an image, e.g.
+		// image: registry.baileys.dev/blog@{{ digest "registry.baileys.dev/blog:latest" }}
+		templatefuncs.Digest: func(ref string) (string, error) {
+			d, err := e.RemoteDigest(ctx, ref)
+			if err != nil {
+				return "", fmt.Errorf("failed to resolve digest of %s: %w", ref, err)
+			}
+			return d, nil
+		},
+		templatefuncs.Default: func(def, v any) any {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+		templatefuncs.Required: func(msg string, v any) (any, error) {
+			if v == nil || v == "" {
+				return nil, errors.New(msg)
+			}
+			return v, nil
+		},
+		templatefuncs.Quote: func(v any) string {
+			return fmt.Sprintf("%q", fmt.Sprint(v))
+		},
+		templatefuncs.Lower:      strings.ToLower,
+		templatefuncs.Upper:      strings.ToUpper,
+		templatefuncs.TrimPrefix: func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		templatefuncs.TrimSuffix: func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		templatefuncs.Replace:    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	}
+}
+
+// readVars reads the template variables from the service's config. A missing
+// vars file means there are no variables.
+func readVars(dir string) (map[string]any, error) {
+	b, err := os.ReadFile(filepath.Join(dir, varsFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vars: %w", err)
+	}
+
+	var vars map[string]any
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", varsFileName, err)
+	}
+	return vars, nil
+}
+
+// renderTemplates renders every *.tmpl file in dir to the same name without
+// the extension. A template can't be committed next to the file it renders to.
+func renderTemplates(dir string, data templateData, funcs template.FuncMap) error {
+	values := data.values()
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		output, ok := strings.CutSuffix(path, templateExt)
+		if !ok {
+			return nil
+		}
+		if _, err := os.Lstat(output); err == nil {
+			return fmt.Errorf("%s and %s can't both be committed", relPath, strings.TrimSuffix(relPath, templateExt))
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(relPath).Funcs(funcs).Option("missingkey=error").Parse(string(b))
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, values); err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Rendered template %s", relPath)
+		return os.WriteFile(output, buf.Bytes(), info.Mode().Perm())
+	})
+}
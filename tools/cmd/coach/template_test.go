@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/baely/infra/tools/internal/templatefuncs"
+)
+
+func TestTemplateFuncsAreNamed(t *testing.T) {
+	got := slices.Sorted(maps.Keys(templateFuncs(context.Background(), nil)))
+	want := slices.Sorted(slices.Values(templatefuncs.Names))
+	if !slices.Equal(got, want) {
+		t.Errorf("templateFuncs defines %q, want templatefuncs.Names %q", got, want)
+	}
+}
+
+// digestEngine resolves every image to the same digest.
+type digestEngine struct {
+	engine
+}
+
+func (digestEngine) RemoteDigest(ctx context.Context, ref string) (string, error) {
+	if ref == "registry.baileys.dev/missing:latest" {
+		return "", errors.New("manifest unknown")
+	}
+	return "sha256:0123", nil
+}
+
+func writeTemplateFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRenderTemplates(t *testing.T) {
+	data := templateData{
+		SHA:         "0123456789abcdef",
+		Service:     "github.com_baely_blog",
+		Host:        "local",
+		Environment: "stage",
+		Domain:      "stage.baileys.dev",
+		Vars:        map[string]any{"replicas": 2, "name": "Blog"},
+	}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"data", "{{ .service }}@{{ .short_sha }} ({{ .sha }}) on {{ .host }} in {{ .environment }}", "github.com_baely_blog@0123456 (0123456789abcdef) on local in stage"},
+		{"vars", "{{ .vars.name }} x{{ .vars.replicas }} at blog.{{ .domain }}", "Blog x2 at blog.stage.baileys.dev"},
+		{"digest", `image: registry.baileys.dev/blog@{{ digest "registry.baileys.dev/blog:latest" }}`, "image: registry.baileys.dev/blog@sha256:0123"},
+		{"default", `{{ index .vars "level" | default "info" }} {{ index .vars "name" | default "info" }}`, "info Blog"},
+		{"strings", `{{ quote .vars.name }} {{ lower .vars.name }} {{ upper .vars.name }} {{ trimPrefix "github.com_" .service }} {{ trimSuffix "_blog" .service }} {{ replace "_" "/" .service }}`, `"Blog" blog BLOG baely_blog github.com_baely github.com/baely/blog`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTemplateFiles(t, map[string]string{"config/app.yaml.tmpl": tt.template, "plain.yaml": "{{ .sha }}"})
+			if err := renderTemplates(dir, data, templateFuncs(context.Background(), digestEngine{})); err != nil {
+				t.Fatalf("renderTemplates: %v", err)
+			}
+			if got := readTestFile(t, filepath.Join(dir, "config", "app.yaml")); got != tt.want {
+				t.Errorf("rendered %q, want %q", got, tt.want)
+			}
+			if got := readTestFile(t, filepath.Join(dir, "plain.yaml")); got != "{{ .sha }}" {
+				t.Errorf("plain.yaml was rendered to %q, want it left as it is", got)
+			}
+			info, err := os.Stat(filepath.Join(dir, "config", "app.yaml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("rendered file has mode %v, want the template's 0640", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestRenderTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"template next to its output", map[string]string{"deploy.yaml.tmpl": "a", "deploy.yaml": "b"}, "deploy.yaml.tmpl and deploy.yaml can't both be committed"},
+		{"missing key", map[string]string{"deploy.yaml.tmpl": "{{ .image }}"}, `map has no entry for key "image"`},
+		{"required", map[string]string{"deploy.yaml.tmpl": `{{ index .vars "image" | required "vars.image is required" }}`}, "vars.image is required"},
+		{"digest", map[string]string{"deploy.yaml.tmpl": `{{ digest "registry.baileys.dev/missing:latest" }}`}, "failed to resolve digest of registry.baileys.dev/missing:latest: manifest unknown"},
+		{"unknown function", map[string]string{"deploy.yaml.tmpl": `{{ env "HOME" }}`}, `function "env" not defined`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTemplateFiles(t, tt.files)
+			err := renderTemplates(dir, templateData{}, templateFuncs(context.Background(), digestEngine{}))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("renderTemplates returned %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadVars(t *testing.T) {
+	vars, err := readVars(t.TempDir())
+	if err != nil || vars != nil {
+		t.Errorf("readVars without %s = %v, %v, want nil, nil", varsFileName, vars, err)
+	}
+
+	dir := writeTemplateFiles(t, map[string]string{varsFileName: "replicas: 2\nname: Blog\n"})
+	vars, err = readVars(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"replicas": 2, "name": "Blog"}; !maps.Equal(vars, want) {
+		t.Errorf("readVars = %v, want %v", vars, want)
+	}
+
+	dir = writeTemplateFiles(t, map[string]string{varsFileName: "replicas: [\n"})
+	if _, err := readVars(dir); err == nil {
+		t.Errorf("readVars of an invalid %s succeeded", varsFileName)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/baely/infra/tools/internal/routing"
+	"github.com/compose-spec/compose-go/v2/cli"
+	"github.com/compose-spec/compose-go/v2/types"
+	"github.com/distribution/reference"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultAllowedRegistries are the registries service images may come from
+// when the config doesn't list any.
+var defaultAllowedRegistries = []string{"registry.baileys.dev"}
+
+// deployProblem is a single reason a deploy.yaml can't be deployed.
+type deployProblem struct {
+	// Field is where the problem is, e.g. services.blog.image.
+	Field   string
+	Message string
+}
+
+// validateDeployFile checks a service's deploy.yaml against the Compose spec and
+// our own rules before anything is pulled or started. Every problem found is
+// reported in a single InvalidArgument error.
+func (s *coachService) validateDeployFile(ctx context.Context, ws *workspace, workDir string) error {
+	deployYamlPath := filepath.Join(workDir, deployFileName)
+	log.Printf("Validating deploy file at: %s", deployYamlPath)
+
+	if _, err := os.Stat(deployYamlPath); os.IsNotExist(err) {
+		log.Printf("Deploy file validation failed: deploy.yaml not found at %s", deployYamlPath)
+		return status.Error(codes.InvalidArgument, "deploy.yaml not found in service directory")
+	}
+
+	// Env files are checked below alongside everything else, rather than
+	// failing the load on the first missing one.
+	project, err := loadProject(ctx, workDir, cli.WithoutEnvironmentResolution)
+	if err != nil {
+		return deployProblemsError([]deployProblem{{Field: deployFileName, Message: err.Error()}})
+	}
+
+	problems := checkHouseRules(project, s.config.allowedRegistries(ws.environment))
+	problems = append(problems, s.checkRouterConflicts(project, ws)...)
+	if len(problems) > 0 {
+		return deployProblemsError(problems)
+	}
+
+	log.Printf("Deploy file validation successful")
+	return nil
+}
+
+func deployProblemsError(problems []deployProblem) error {
+	var lines []string
+	badRequest := &errdetails.BadRequest{}
+	for _, p := range problems {
+		log.Printf("Deploy file problem: %s: %s", p.Field, p.Message)
+		lines = append(lines, fmt.Sprintf("%s: %s", p.Field, p.Message))
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       p.Field,
+			Description: p.Message,
+		})
+	}
+
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s:\n%s", deployFileName, strings.Join(lines, "\n")))
+	if withDetails, err := st.WithDetails(badRequest); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// checkHouseRules checks the rules every service we run has to follow, on top of the Compose spec.
+func checkHouseRules(project *types.Project, allowedRegistries []string) []deployProblem {
+	var problems []deployProblem
+
+	routers := make(map[string]string)
+	for _, name := range sortedServiceNames(project) {
+		service := project.Services[name]
+		field := fmt.Sprintf("services.%s", name)
+
+		if service.Image == "" {
+			problems = append(problems, deployProblem{field + ".image", "image is required"})
+		} else if !imageFromRegistry(service.Image, allowedRegistries) {
+			problems = append(problems, deployProblem{field + ".image", fmt.Sprintf("image %s is not from an allowed registry (%s)", service.Image, strings.Join(allowedRegistries, ", "))})
+		}
+
+		for _, f := range unsupportedFields(service) {
+			problems = append(problems, deployProblem{field + "." + f, "Coach doesn't support this compose field"})
+		}
+
+		if service.Restart == "" || service.Restart == types.RestartPolicyNo {
+			problems = append(problems, deployProblem{field + ".restart", "a restart policy is required"})
+		}
+
+		for _, envFile := range service.EnvFiles {
+			if !envFile.Required {
+				continue
+			}
+			if problem := checkPathResolves(project, envFile.Path); problem != "" {
+				problems = append(problems, deployProblem{field + ".env_file", problem})
+			}
+		}
+
+		for _, s := range service.Secrets {
+			secret, ok := project.Secrets[s.Source]
+			if !ok || secret.File == "" {
+				continue
+			}
+			if problem := checkPathResolves(project, secret.File); problem != "" {
+				if isWithin(filepath.Join(project.WorkingDir, storedSecretsDir), secret.File) {
+					problem = fmt.Sprintf("external secret %s is not set in Coach's secret store", s.Source)
+				}
+				problems = append(problems, deployProblem{fmt.Sprintf("secrets.%s", s.Source), problem})
+			}
+		}
+
+		for _, router := range routing.Routers(name, routing.Labels(service.Labels)) {
+			if other, ok := routers[router.ID()]; ok {
+				problems = append(problems, deployProblem{field + ".labels", fmt.Sprintf("traefik router %s is also defined by service %s", router.ID(), other)})
+				continue
+			}
+			routers[router.ID()] = name
+		}
+	}
+
+	return problems
+}
+
+// checkPathResolves reports a problem if a file in the service's workspace doesn't exist.
+// Paths outside the workspace are on the docker host, which Coach can't see.
+func checkPathResolves(project *types.Project, path string) string {
+	if !isWithin(project.WorkingDir, path) {
+		return ""
+	}
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%s not found", project.RelativePath(path))
+	}
+	return ""
+}
+
+// checkRouterConflicts reports traefik routers that are already defined by
+// another service Coach has deployed to the same environment.
+func (s *coachService) checkRouterConflicts(project *types.Project, ws *workspace) []deployProblem {
+	deployed, err := s.deployedRouters(ws.environment, ws.service)
+	if err != nil {
+		log.Printf("Warning: failed to read routers of deployed services: %v", err)
+		return nil
+	}
+
+	var problems []deployProblem
+	for _, name := range sortedServiceNames(project) {
+		for _, router := range routing.Routers(name, routing.Labels(project.Services[name].Labels)) {
+			if other, ok := deployed[router.ID()]; ok {
+				problems = append(problems, deployProblem{
+					Field:   fmt.Sprintf("services.%s.labels", name),
+					Message: fmt.Sprintf("traefik router %s is already defined by deployed service %s", router.ID(), other),
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// deployedRouters maps each traefik router of the services deployed to an
+// environment, other than exclude, to the service that defines it.
+func (s *coachService) deployedRouters(environment, exclude string) (map[string]string, error) {
+	stacks, err := routing.LoadDir(environmentDir(s.stateDir, environment))
+	if err != nil {
+		return nil, err
+	}
+
+	routers := make(map[string]string)
+	for _, stack := range stacks {
+		if stack.Name == exclude {
+			continue
+		}
+		for service, labels := range stack.Services {
+			for _, router := range routing.Routers(service, labels) {
+				routers[router.ID()] = stack.Name
+			}
+		}
+	}
+	return routers, nil
+}
+
+func imageFromRegistry(image string, registries []string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+
+	domain := reference.Domain(named)
+	for _, registry := range registries {
+		if domain == registry {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedServiceNames(project *types.Project) []string {
+	names := project.ServiceNames()
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckHouseRulesRejectsUnsupportedFields(t *testing.T) {
+	project := loadTestProject(t, `services:
+  web:
+    image: registry.baileys.dev/web:latest
+    restart: unless-stopped
+    links: [db]
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: registry.baileys.dev/db:latest
+    restart: unless-stopped
+`)
+
+	var fields []string
+	for _, p := range checkHouseRules(project, defaultAllowedRegistries) {
+		fields = append(fields, p.Field)
+	}
+	if want := []string{"services.web.links", "services.web.depends_on.db.condition"}; !slices.Equal(fields, want) {
+		t.Errorf("got problems in %v, want %v", fields, want)
+	}
+}
+
+// validateTestDeployFile validates deployFile in ws, with files next to it,
+// and returns the fields of the problems found.
+func validateTestDeployFile(t *testing.T, s *coachService, ws *workspace, deployFile string, files map[string]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	files[deployFileName] = deployFile
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.validateDeployFile(context.Background(), ws, dir)
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("validateDeployFile returned %v, want InvalidArgument", err)
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func TestValidateDeployFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployFile string
+		files      map[string]string
+		want       []string
+	}{
+		{
+			name: "valid",
+			deployFile: `services:
+  web:
+    image: registry.baileys.dev/web:latest
+    restart: unless-stopped
+    env_file: .env
+    secrets: [token]
+secrets:
+  token:
+    file: token.txt
+`,
+			files: map[string]string{".env": "GREETING=hello", "token.txt": "secret"},
+		},
+		{
+			name:       "invalid compose",
+			deployFile: "services:\n  web:\n    image: [\n",
+			want:       []string{deployFileName},
+		},
+		{
+			name: "image and restart policy",
+			deployFile: `services:
+  db:
+    image: postgres:16
+    restart: "no"
+  web:
+    build: .
+`,
+			want: []string{"services.db.image", "services.db.restart", "services.web.image", "services.web.restart"},
+		},
+		{
+			name: "missing files",
+			deployFile: `services:
+  web:
+    image: registry.baileys.dev/web:latest
+    restart: unless-stopped
+    env_file:
+      - .env
+      - path: optional.env
+        required: false
+    secrets: [token, api_key]
+secrets:
+  token:
+    file: token.txt
+  api_key:
+    external: true
+`,
+			want: []string{"services.web.env_file", "secrets.token", "secrets.api_key"},
+		},
+		{
+			name: "router defined twice",
+			deployFile: `services:
+  api:
+    image: registry.baileys.dev/api:latest
+    restart: unless-stopped
+    labels:
+      - traefik.http.routers.web.rule=Host(` + "`api.baileys.dev`" + `)
+  web:
+    image: registry.baileys.dev/web:latest
+    restart: unless-stopped
+    labels:
+      - traefik.http.routers.web.rule=Host(` + "`web.baileys.dev`" + `)
+`,
+			want: []string{"services.web.labels"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ws := newTestWorkspace(t)
+			if tt.files == nil {
+				tt.files = map[string]string{}
+			}
+			if got := validateTestDeployFile(t, s, ws, tt.deployFile, tt.files); !slices.Equal(got, tt.want) {
+				t.Errorf("got problems in %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDeployFileAllowedRegistries(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	s.config = &config{
+		AllowedRegistries: []string{"ghcr.io"},
+		Environments: map[string]environmentConfig{
+			"stage": {AllowedRegistries: []string{"registry.baileys.dev"}},
+		},
+	}
+	deployFile := `services:
+  web:
+    image: ghcr.io/baely/web:latest
+    restart: unless-stopped
+`
+
+	if got := validateTestDeployFile(t, s, ws, deployFile, map[string]string{}); got != nil {
+		t.Errorf("got problems in %v, want none", got)
+	}
+	ws.environment = "stage"
+	if got, want := validateTestDeployFile(t, s, ws, deployFile, map[string]string{}), []string{"services.web.image"}; !slices.Equal(got, want) {
+		t.Errorf("got problems in %v in stage, want %v", got, want)
+	}
+}
+
+func TestValidateDeployFileRequiresDeployFile(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	err := s.validateDeployFile(context.Background(), ws, t.TempDir())
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("validateDeployFile without a %s returned %v, want InvalidArgument", deployFileName, err)
+	}
+}
+
+func TestCheckRouterConflicts(t *testing.T) {
+	s, ws := newTestWorkspace(t)
+	deployed := map[string]string{
+		"blog": "services: {web: {labels: [traefik.http.routers.blog.rule=Host(`blog.baileys.dev`)]}}",
+		"web":  "services: {web: {labels: [traefik.http.routers.web.rule=Host(`web.baileys.dev`)]}}",
+	}
+	for service, deployFile := range deployed {
+		dir := workspaceFor(s.stateDir, defaultEnvironment, service).dir
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, deployFileName), []byte(deployFile), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	project := loadTestProject(t, `services:
+  web:
+    image: registry.baileys.dev/web:latest
+    labels:
+      - traefik.http.routers.blog.rule=Host(`+"`blog.baileys.dev`"+`)
+      - traefik.http.routers.web.rule=Host(`+"`web.baileys.dev`"+`)
+`)
+
+	problems := s.checkRouterConflicts(project, ws)
+	want := []deployProblem{{Field: "services.web.labels", Message: "traefik router http/blog is already defined by deployed service blog"}}
+	if !slices.Equal(problems, want) {
+		t.Errorf("checkRouterConflicts() = %+v, want %+v", problems, want)
+	}
+
+	stage, err := createWorkspace(workspaceFor(s.stateDir, "stage", "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := s.checkRouterConflicts(project, stage); len(problems) != 0 {
+		t.Errorf("checkRouterConflicts() in stage = %+v, want none", problems)
+	}
+}
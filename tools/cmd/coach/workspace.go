@@ -0,0 +1,402 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose-spec/compose-go/v2/loader"
+)
+
+// managedFilesName records which files in a workspace were written by Coach,
+// so that anything else (e.g. data directories created by bind mounts) is left alone.
+const managedFilesName = ".coach-managed.json"
+
+// deploymentFileName records the last successful deploy of a workspace.
+const deploymentFileName = ".coach-deployment.json"
+
+// deployment is a successful deploy of a service.
+type deployment struct {
+	Ref        string    `json:"ref"`
+	SHA        string    `json:"sha,omitempty"`
+	Host       string    `json:"host,omitempty"`
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+// workspace is the durable directory a service is deployed from in an environment.
+//
+// The service directory doubles as the compose project directory, so relative
+// bind mounts such as ./data resolve to the same host path on every deploy.
+type workspace struct {
+	service     string
+	environment string
+	// preview is the pull request the workspace previews, or 0.
+	preview int
+	dir     string
+	staging string
+}
+
+// environmentDir is the directory holding the workspaces of an environment.
+// Prod services stay in services/, where they were deployed before there
+// were environments, so their bind mounts keep their host paths.
+func environmentDir(stateDir, environment string) string {
+	if environment == defaultEnvironment {
+		return filepath.Join(stateDir, "services")
+	}
+	return filepath.Join(stateDir, "environments", environment)
+}
+
+// workspaceFor returns a service's workspace in an environment without creating it.
+func workspaceFor(stateDir, environment, service string) *workspace {
+	return &workspace{
+		service:     service,
+		environment: environment,
+		dir:         filepath.Join(environmentDir(stateDir, environment), service),
+		staging:     filepath.Join(stateDir, "staging"),
+	}
+}
+
+// previewWorkspace returns the workspace of a service's pull request preview
+// without creating it. Previews are kept apart from every environment's
+// workspaces, in previews/<service>/pr-<number>.
+func previewWorkspace(stateDir, environment, service string, pullRequest int) *workspace {
+	return &workspace{
+		service:     service,
+		environment: environment,
+		preview:     pullRequest,
+		dir:         filepath.Join(stateDir, "previews", service, fmt.Sprintf("pr-%d", pullRequest)),
+		staging:     filepath.Join(stateDir, "staging"),
+	}
+}
+
+// serviceLocks serializes changes to each service's workspaces, so two
+// deploys of a service never write to the same workspace at once.
+type serviceLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock blocks until no one else holds the service's lock and returns the
+// function that releases it.
+func (l *serviceLocks) lock(service string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.locks[service]
+	if !ok {
+		m = &sync.Mutex{}
+		l.locks[service] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// createWorkspace creates the workspace's directories if they don't exist.
+func createWorkspace(w *workspace) (*workspace, error) {
+	for _, dir := range []string{w.dir, w.staging} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+
+	return w, nil
+}
+
+// listWorkspaces returns the workspace of every service that has been
+// deployed, in every environment.
+func listWorkspaces(stateDir string) ([]*workspace, error) {
+	environments := []string{defaultEnvironment}
+	entries, err := os.ReadDir(filepath.Join(stateDir, "environments"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list environments: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != defaultEnvironment {
+			environments = append(environments, entry.Name())
+		}
+	}
+
+	var workspaces []*workspace
+	for _, environment := range environments {
+		entries, err := os.ReadDir(environmentDir(stateDir, environment))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workspaces: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				workspaces = append(workspaces, workspaceFor(stateDir, environment, entry.Name()))
+			}
+		}
+	}
+	return workspaces, nil
+}
+
+// projectName is the compose project the service is deployed as. Prod keeps
+// the service's own name; other environments and previews are suffixed so
+// they can share a host with prod.
+func (w *workspace) projectName() string {
+	if w.preview != 0 {
+		return loader.NormalizeProjectName(fmt.Sprintf("%s-pr-%d", w.service, w.preview))
+	}
+	if w.environment == defaultEnvironment {
+		return loader.NormalizeProjectName(w.service)
+	}
+	return loader.NormalizeProjectName(w.service + "-" + w.environment)
+}
+
+// stage creates an empty directory on the same filesystem as the workspace
+// for the new config to be downloaded into.
+func (w *workspace) stage() (string, error) {
+	return os.MkdirTemp(w.staging, fmt.Sprintf("%s-", w.service))
+}
+
+// revision is a config committed to a workspace. The files it replaced are
+// kept aside until the new containers are up, so the workspace can be rolled
+// back to the config it had before.
+type revision struct {
+	// previous are the managed files before the commit.
+	previous []string
+	// added are the committed files that didn't exist before.
+	added []string
+	// replaced are the committed files that overwrote an existing file; the
+	// old ones are in backup.
+	replaced []string
+	// stale are previously managed files that are not part of the new config.
+	stale  []string
+	backup string
+}
+
+// commit moves every file in stagingDir into the workspace. Each file is
+// renamed into place so a reader never sees a partially written config.
+// The returned revision must be passed to prune once the new containers are
+// up, or to rollback if they couldn't be started. If commit fails, the
+// workspace is rolled back before it returns.
+func (w *workspace) commit(stagingDir string) (*revision, error) {
+	previous, err := w.managedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := os.MkdirTemp(w.staging, fmt.Sprintf("%s-previous-", w.service))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	rev := &revision{previous: previous, backup: backup}
+
+	var current []string
+	err = filepath.WalkDir(stagingDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(stagingDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." || relPath == managedFilesName || relPath == deploymentFileName || relPath == previewFileName {
+			return nil
+		}
+
+		destPath := filepath.Join(w.dir, relPath)
+		if d.IsDir() {
+			// Directories keep the mode they were staged with, e.g. 0700 for stored secrets.
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(destPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chmod(destPath, info.Mode().Perm())
+		}
+
+		if _, err := os.Lstat(destPath); err == nil {
+			backupPath := filepath.Join(backup, relPath)
+			if err := os.MkdirAll(filepath.Dir(backupPath), 0700); err != nil {
+				return err
+			}
+			if err := os.Rename(destPath, backupPath); err != nil {
+				return err
+			}
+			rev.replaced = append(rev.replaced, relPath)
+		} else if errors.Is(err, fs.ErrNotExist) {
+			rev.added = append(rev.added, relPath)
+		} else {
+			return err
+		}
+
+		log.Printf("Committing file: %s -> %s", path, destPath)
+		if err := os.Rename(path, destPath); err != nil {
+			return err
+		}
+		current = append(current, relPath)
+		return nil
+	})
+	if err == nil {
+		err = w.writeManagedFiles(current)
+	}
+	if err != nil {
+		if rerr := w.rollback(rev); rerr != nil {
+			log.Printf("Warning: failed to roll back workspace %s: %v", w.dir, rerr)
+		}
+		return nil, fmt.Errorf("failed to commit staged config: %w", err)
+	}
+
+	keep := make(map[string]bool, len(current))
+	for _, relPath := range current {
+		keep[relPath] = true
+	}
+	for _, relPath := range previous {
+		if !keep[relPath] {
+			rev.stale = append(rev.stale, relPath)
+		}
+	}
+
+	return rev, nil
+}
+
+// rollback restores the config the workspace had before rev was committed.
+func (w *workspace) rollback(rev *revision) error {
+	var errs []error
+	for _, relPath := range rev.added {
+		path := filepath.Join(w.dir, relPath)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		removeEmptyDirs(w.dir, filepath.Dir(path))
+	}
+	for _, relPath := range rev.replaced {
+		log.Printf("Restoring file: %s", filepath.Join(w.dir, relPath))
+		if err := os.Rename(filepath.Join(rev.backup, relPath), filepath.Join(w.dir, relPath)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) == 0 {
+		if err := w.writeManagedFiles(rev.previous); err != nil {
+			errs = append(errs, err)
+		}
+		if err := os.RemoveAll(rev.backup); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// prune removes the files left over from the deploy before rev, and any
+// directories that are empty without them.
+func (w *workspace) prune(rev *revision) error {
+	var errs []error
+	for _, relPath := range rev.stale {
+		path := filepath.Join(w.dir, relPath)
+		log.Printf("Removing stale file: %s", path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		removeEmptyDirs(w.dir, filepath.Dir(path))
+	}
+	if err := os.RemoveAll(rev.backup); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// removeEmptyDirs removes dir and its parents up to, but not including, root
+// for as long as they are empty.
+func removeEmptyDirs(root, dir string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func (w *workspace) managedFiles() ([]string, error) {
+	b, err := os.ReadFile(filepath.Join(w.dir, managedFilesName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read managed files: %w", err)
+	}
+
+	var files []string
+	if err := json.Unmarshal(b, &files); err != nil {
+		return nil, fmt.Errorf("failed to parse managed files: %w", err)
+	}
+	return files, nil
+}
+
+func (w *workspace) writeManagedFiles(files []string) error {
+	sort.Strings(files)
+	b, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(w.dir, managedFilesName), b, 0644)
+}
+
+// deployment returns the workspace's last successful deploy, or nil if it has never been deployed.
+func (w *workspace) deployment() (*deployment, error) {
+	b, err := os.ReadFile(filepath.Join(w.dir, deploymentFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deployment: %w", err)
+	}
+
+	d := &deployment{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("failed to parse deployment: %w", err)
+	}
+	return d, nil
+}
+
+func (w *workspace) writeDeployment(d *deployment) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(w.dir, deploymentFileName), b, 0644)
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and renames it into place.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to chmod temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// stageTestFiles stages files in the workspace and returns the staging directory.
+func stageTestFiles(t *testing.T, ws *workspace, files map[string]string) string {
+	t.Helper()
+	stagingDir, err := ws.stage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		filename := filepath.Join(stagingDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return stagingDir
+}
+
+func commitTestFiles(t *testing.T, ws *workspace, files map[string]string) *revision {
+	t.Helper()
+	rev, err := ws.commit(stageTestFiles(t, ws, files))
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return rev
+}
+
+func assertNotExist(t *testing.T, name string) {
+	t.Helper()
+	if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s exists: %v", name, err)
+	}
+}
+
+func TestWorkspaceCommitAndPrune(t *testing.T) {
+	_, ws := newTestWorkspace(t)
+	rev := commitTestFiles(t, ws, map[string]string{deployFileName: "v1", "nginx/default.conf": "v1", "old/data.json": "v1"})
+	if err := ws.prune(rev); err != nil {
+		t.Fatal(err)
+	}
+	// Files Coach didn't commit, such as a service's data, are never touched.
+	if err := os.WriteFile(filepath.Join(ws.dir, "nginx", "cache"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rev = commitTestFiles(t, ws, map[string]string{deployFileName: "v2", "new.env": "v2"})
+	if !slices.Equal(rev.added, []string{"new.env"}) || !slices.Equal(rev.replaced, []string{deployFileName}) {
+		t.Errorf("commit added %v and replaced %v, want [new.env] and [%s]", rev.added, rev.replaced, deployFileName)
+	}
+	if want := []string{deployFileName, "nginx/default.conf", "old/data.json"}; !slices.Equal(rev.previous, want) {
+		t.Errorf("previous files are %v, want %v", rev.previous, want)
+	}
+	if want := []string{"nginx/default.conf", "old/data.json"}; !slices.Equal(rev.stale, want) {
+		t.Errorf("stale files are %v, want %v", rev.stale, want)
+	}
+	// Stale files are only removed by prune, once the new config is up.
+	if got := readTestFile(t, filepath.Join(ws.dir, "old", "data.json")); got != "v1" {
+		t.Errorf("old/data.json is %q before prune, want v1", got)
+	}
+
+	if err := ws.prune(rev); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(ws.dir, deployFileName)); got != "v2" {
+		t.Errorf("%s is %q, want v2", deployFileName, got)
+	}
+	assertNotExist(t, filepath.Join(ws.dir, "nginx", "default.conf"))
+	assertNotExist(t, filepath.Join(ws.dir, "old"))
+	if got := readTestFile(t, filepath.Join(ws.dir, "nginx", "cache")); got != "data" {
+		t.Errorf("nginx/cache is %q, want it left alone", got)
+	}
+	assertNotExist(t, rev.backup)
+
+	managed, err := ws.managedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{deployFileName, "new.env"}; !slices.Equal(managed, want) {
+		t.Errorf("workspace manages %v, want %v", managed, want)
+	}
+}
+
+func TestWorkspaceRollback(t *testing.T) {
+	_, ws := newTestWorkspace(t)
+	if err := ws.prune(commitTestFiles(t, ws, map[string]string{deployFileName: "v1", "app.env": "v1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	rev := commitTestFiles(t, ws, map[string]string{deployFileName: "v2", "config/new.yaml": "v2"})
+	if err := ws.rollback(rev); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+
+	if got := readTestFile(t, filepath.Join(ws.dir, deployFileName)); got != "v1" {
+		t.Errorf("%s is %q, want v1", deployFileName, got)
+	}
+	if got := readTestFile(t, filepath.Join(ws.dir, "app.env")); got != "v1" {
+		t.Errorf("app.env is %q, want v1", got)
+	}
+	assertNotExist(t, filepath.Join(ws.dir, "config"))
+	assertNotExist(t, rev.backup)
+
+	managed, err := ws.managedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"app.env", deployFileName}; !slices.Equal(managed, want) {
+		t.Errorf("workspace manages %v, want %v", managed, want)
+	}
+}
+
+func TestWorkspaceCommitSkipsCoachFiles(t *testing.T) {
+	_, ws := newTestWorkspace(t)
+	if err := os.WriteFile(filepath.Join(ws.dir, deploymentFileName), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rev := commitTestFiles(t, ws, map[string]string{deployFileName: "v1", deploymentFileName: "staged", managedFilesName: "[]"})
+	if !slices.Equal(rev.added, []string{deployFileName}) {
+		t.Errorf("commit added %v, want only %s", rev.added, deployFileName)
+	}
+	if got := readTestFile(t, filepath.Join(ws.dir, deploymentFileName)); got != "{}" {
+		t.Errorf("%s is %q, want the workspace's own", deploymentFileName, got)
+	}
+}
+
+func TestWorkspaceCommitKeepsDirectoryModes(t *testing.T) {
+	_, ws := newTestWorkspace(t)
+	stagingDir := stageTestFiles(t, ws, map[string]string{deployFileName: "v1", storedSecretsDir + "/token": "secret"})
+	if err := os.Chmod(filepath.Join(stagingDir, storedSecretsDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ws.commit(stagingDir); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(ws.dir, storedSecretsDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("%s has mode %v, want 0700", storedSecretsDir, info.Mode().Perm())
+	}
+}
+
+func TestWorkspaceProjectName(t *testing.T) {
+	stateDir := t.TempDir()
+	tests := []struct {
+		ws   *workspace
+		want string
+	}{
+		{workspaceFor(stateDir, defaultEnvironment, "github.com_baely_blog"), "githubcom_baely_blog"},
+		{workspaceFor(stateDir, "stage", "github.com_baely_blog"), "githubcom_baely_blog-stage"},
+		{previewWorkspace(stateDir, "stage", "github.com_baely_blog", 12), "githubcom_baely_blog-pr-12"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.ws.projectName(); got != tt.want {
+			t.Errorf("projectName of %s = %q, want %q", tt.ws.dir, got, tt.want)
+		}
+	}
+}
@@ -3,13 +3,16 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/baely/infra/tools/gen/squad/v1alpha1"
 )
@@ -28,6 +31,27 @@ var (
 
 	service string
 	startRef string
+	startHost string
+	startPlan bool
+	startEnvironment string
+
+	promoteService string
+	promoteFrom    string
+	promoteTo      string
+	promotePlan    bool
+
+	previewService     string
+	previewPullRequest int32
+	previewRef         string
+	previewTTL         time.Duration
+
+	lintRef string
+
+	gcDryRun bool
+
+	secretService  string
+	secretName     string
+	secretFromFile string
 )
 
 func main() {
@@ -65,10 +89,118 @@ func main() {
 
 	startCmd.Flags().StringVar(&service, "service", "", "Service name (required)")
 	startCmd.Flags().StringVar(&startRef, "ref", "", "Git reference (required)")
+	startCmd.Flags().StringVar(&startHost, "host", "", "Target host (default: the service's configured host)")
+	startCmd.Flags().BoolVar(&startPlan, "plan", false, "Show what the deploy would change without changing anything")
+	startCmd.Flags().StringVar(&startEnvironment, "environment", "", "Target environment (default: Coach's default environment)")
 	startCmd.MarkFlagRequired("service")
 	startCmd.MarkFlagRequired("ref")
 
-	rootCmd.AddCommand(assembleCmd, startCmd)
+	promoteCmd := &cobra.Command{
+		Use:   "promote",
+		Short: "Deploy the commit running in one environment to another",
+		RunE:  runPromote,
+	}
+
+	promoteCmd.Flags().StringVar(&promoteService, "service", "", "Service name (required)")
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "Environment to promote from, e.g. stage (required)")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "Environment to promote to, e.g. prod (required)")
+	promoteCmd.Flags().BoolVar(&promotePlan, "plan", false, "Show what the deploy would change without changing anything")
+	promoteCmd.MarkFlagRequired("service")
+	promoteCmd.MarkFlagRequired("from")
+	promoteCmd.MarkFlagRequired("to")
+
+	previewCmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Manage pull request previews",
+	}
+
+	previewCreateCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Deploy a pull request's config as a temporary preview",
+		RunE:  runPreviewCreate,
+	}
+	previewCreateCmd.Flags().StringVar(&previewService, "service", "", "Service name (required)")
+	previewCreateCmd.Flags().Int32Var(&previewPullRequest, "pr", 0, "Pull request number (required)")
+	previewCreateCmd.Flags().StringVar(&previewRef, "ref", "", "Git reference of the pull request's config (required)")
+	previewCreateCmd.Flags().DurationVar(&previewTTL, "ttl", 0, "How long the preview lives (default: Coach's preview TTL)")
+	previewCreateCmd.MarkFlagRequired("service")
+	previewCreateCmd.MarkFlagRequired("pr")
+	previewCreateCmd.MarkFlagRequired("ref")
+
+	previewDeleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a preview",
+		RunE:  runPreviewDelete,
+	}
+	previewDeleteCmd.Flags().StringVar(&previewService, "service", "", "Service name (required)")
+	previewDeleteCmd.Flags().Int32Var(&previewPullRequest, "pr", 0, "Pull request number (required)")
+	previewDeleteCmd.MarkFlagRequired("service")
+	previewDeleteCmd.MarkFlagRequired("pr")
+
+	previewListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List previews",
+		RunE:  runPreviewList,
+	}
+	previewListCmd.Flags().StringVar(&previewService, "service", "", "Only list the service's previews")
+
+	previewCmd.AddCommand(previewCreateCmd, previewDeleteCmd, previewListCmd)
+
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check every service for traefik routing conflicts",
+		RunE:  runLint,
+	}
+
+	lintCmd.Flags().StringVar(&lintRef, "ref", "", "Git reference of the infra repo (default: the default branch)")
+
+	servicesCmd := &cobra.Command{
+		Use:   "services",
+		Short: "List deployed services",
+		RunE:  runServices,
+	}
+
+	gcCmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove unused images, build cache and leftover directories",
+		RunE:  runGC,
+	}
+
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Report what would be removed without removing anything")
+
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage a service's secrets",
+	}
+	secretsCmd.PersistentFlags().StringVar(&secretService, "service", "", "Service name (required)")
+	secretsCmd.MarkPersistentFlagRequired("service")
+
+	secretsSetCmd := &cobra.Command{
+		Use:   "set",
+		Short: "Set a secret from a file or stdin",
+		RunE:  runSecretsSet,
+	}
+	secretsSetCmd.Flags().StringVar(&secretName, "name", "", "Secret name (required)")
+	secretsSetCmd.Flags().StringVar(&secretFromFile, "from-file", "", "Read the value from a file instead of stdin")
+	secretsSetCmd.MarkFlagRequired("name")
+
+	secretsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List secrets",
+		RunE:  runSecretsList,
+	}
+
+	secretsDeleteCmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a secret",
+		RunE:  runSecretsDelete,
+	}
+	secretsDeleteCmd.Flags().StringVar(&secretName, "name", "", "Secret name (required)")
+	secretsDeleteCmd.MarkFlagRequired("name")
+
+	secretsCmd.AddCommand(secretsSetCmd, secretsListCmd, secretsDeleteCmd)
+
+	rootCmd.AddCommand(assembleCmd, startCmd, promoteCmd, previewCmd, lintCmd, servicesCmd, gcCmd, secretsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -132,12 +264,17 @@ func runAssemble(cmd *cobra.Command, args []string) error {
 		req.ContextLocation = &contextLocation
 	}
 
-	_, err = client.Assemble(ctx, req)
+	resp, err := client.Assemble(ctx, req)
 	if err != nil {
 		return fmt.Errorf("assemble failed: %w", err)
 	}
 
 	fmt.Println("Assemble request completed successfully")
+	fmt.Printf("Image: %s\n", resp.Image)
+	fmt.Printf("Image ID: %s\n", resp.ImageId)
+	if resp.Digest != "" {
+		fmt.Printf("Digest: %s\n", resp.Digest)
+	}
 	return nil
 }
 
@@ -151,15 +288,351 @@ func runStart(cmd *cobra.Command, args []string) error {
 	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
 
 	req := &squadv1alpha1.StartRequest{
-		Service: service,
-		Ref:     startRef,
+		Service:     service,
+		Ref:         startRef,
+		Host:        startHost,
+		DryRun:      startPlan,
+		Environment: startEnvironment,
 	}
 
-	_, err = client.Start(ctx, req)
+	resp, err := client.Start(ctx, req)
 	if err != nil {
 		return fmt.Errorf("start failed: %w", err)
 	}
 
+	if startPlan {
+		printPlan(resp.Plan)
+		return nil
+	}
+
 	fmt.Println("Start request completed successfully")
+	for _, c := range resp.Containers {
+		fmt.Printf("%s: %s %s (%s, %s)\n", c.Service, c.Name, c.State, c.Action, c.ImageDigest)
+	}
+	return nil
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.Promote(ctx, &squadv1alpha1.PromoteRequest{
+		Service: promoteService,
+		From:    promoteFrom,
+		To:      promoteTo,
+		DryRun:  promotePlan,
+	})
+	if err != nil {
+		return fmt.Errorf("promote failed: %w", err)
+	}
+
+	if promotePlan {
+		fmt.Printf("Promoting %s from %s to %s at %s\n", promoteService, promoteFrom, promoteTo, resp.Sha)
+		printPlan(resp.Plan)
+		return nil
+	}
+
+	fmt.Printf("Promoted %s from %s to %s at %s\n", promoteService, promoteFrom, promoteTo, resp.Sha)
+	for _, c := range resp.Containers {
+		fmt.Printf("%s: %s %s (%s, %s)\n", c.Service, c.Name, c.State, c.Action, c.ImageDigest)
+	}
+	return nil
+}
+
+func printPlan(plan *squadv1alpha1.Plan) {
+	fmt.Println("Containers:")
+	for _, c := range plan.Containers {
+		if c.Reason != "" {
+			fmt.Printf("  %s: %s would be %s (%s)\n", c.Service, c.Name, c.Action, c.Reason)
+		} else {
+			fmt.Printf("  %s: %s would be %s\n", c.Service, c.Name, c.Action)
+		}
+	}
+
+	if len(plan.Images) > 0 {
+		fmt.Println("Images to pull:")
+		for _, img := range plan.Images {
+			current, next := img.CurrentDigest, img.NewDigest
+			if current == "" {
+				current = "none"
+			}
+			if next == "" {
+				next = "unknown"
+			}
+			fmt.Printf("  %s: %s (%s -> %s)\n", img.Service, img.Image, current, next)
+		}
+	}
+
+	if plan.ConfigDiff == "" {
+		fmt.Println("No config changes")
+		return
+	}
+	fmt.Println("Config changes:")
+	fmt.Print(plan.ConfigDiff)
+}
+
+func runPreviewCreate(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	req := &squadv1alpha1.CreatePreviewRequest{
+		Service:     previewService,
+		PullRequest: previewPullRequest,
+		Ref:         previewRef,
+	}
+	if previewTTL != 0 {
+		req.Ttl = durationpb.New(previewTTL)
+	}
+
+	resp, err := client.CreatePreview(ctx, req)
+	if err != nil {
+		return fmt.Errorf("create preview failed: %w", err)
+	}
+
+	printPreview(resp.Preview)
+	for _, c := range resp.Containers {
+		fmt.Printf("%s: %s %s (%s, %s)\n", c.Service, c.Name, c.State, c.Action, c.ImageDigest)
+	}
+	return nil
+}
+
+func runPreviewDelete(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	_, err = client.DeletePreview(ctx, &squadv1alpha1.DeletePreviewRequest{
+		Service:     previewService,
+		PullRequest: previewPullRequest,
+	})
+	if err != nil {
+		return fmt.Errorf("delete preview failed: %w", err)
+	}
+
+	fmt.Printf("Preview of %s for pull request %d deleted\n", previewService, previewPullRequest)
+	return nil
+}
+
+func runPreviewList(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.ListPreviews(ctx, &squadv1alpha1.ListPreviewsRequest{Service: previewService})
+	if err != nil {
+		return fmt.Errorf("list previews failed: %w", err)
+	}
+
+	for _, p := range resp.Previews {
+		printPreview(p)
+	}
+	return nil
+}
+
+func printPreview(p *squadv1alpha1.Preview) {
+	fmt.Printf("%s #%d: ref %s (%s), expires %s\n", p.Service, p.PullRequest, p.Ref, p.Sha, p.ExpiresAt.AsTime().Local().Format(time.RFC3339))
+	for _, url := range p.Urls {
+		fmt.Printf("  %s\n", url)
+	}
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.LintRouting(ctx, &squadv1alpha1.LintRoutingRequest{Ref: lintRef})
+	if err != nil {
+		return fmt.Errorf("lint failed: %w", err)
+	}
+
+	for _, u := range resp.Unlinted {
+		fmt.Printf("not linted: %s: %s\n", u.Path, u.Reason)
+	}
+	for _, c := range resp.Conflicts {
+		fmt.Printf("%s: %s: %s\n", c.Environment, c.Kind, c.Message)
+	}
+	if len(resp.Conflicts) > 0 {
+		return fmt.Errorf("found %d routing conflicts", len(resp.Conflicts))
+	}
+
+	fmt.Println("No routing conflicts found")
+	return nil
+}
+
+func runServices(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.ListServices(ctx, &squadv1alpha1.ListServicesRequest{})
+	if err != nil {
+		return fmt.Errorf("list services failed: %w", err)
+	}
+
+	for _, s := range resp.Services {
+		fmt.Printf("%s [%s]: %s (ref %s", s.Name, s.Environment, s.Status, s.Ref)
+		if s.DeployedAt != nil {
+			fmt.Printf(", deployed %s", s.DeployedAt.AsTime().Local().Format(time.RFC3339))
+		}
+		fmt.Println(")")
+		if s.Url != "" {
+			fmt.Printf("  %s\n", s.Url)
+		}
+		if s.Source != nil {
+			fmt.Printf("  from %s at %s (%.7s)\n", s.Source.Repository, s.Source.Ref, s.Source.Sha)
+		}
+	}
+	return nil
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.RunGC(ctx, &squadv1alpha1.RunGCRequest{DryRun: gcDryRun})
+	if err != nil {
+		return fmt.Errorf("gc failed: %w", err)
+	}
+
+	for _, item := range resp.Removed {
+		location := item.Runtime
+		if location == "" {
+			location = "local"
+		}
+		fmt.Printf("%s %s [%s] %s: %s\n", item.Kind, item.Name, location, formatBytes(item.SizeBytes), item.Reason)
+	}
+	for _, e := range resp.Errors {
+		fmt.Printf("error: %s\n", e)
+	}
+
+	verb := "Removed"
+	if gcDryRun {
+		verb = "Would remove"
+	}
+	fmt.Printf("%s %d items, %s\n", verb, len(resp.Removed), formatBytes(resp.ReclaimedBytes))
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("gc failed to remove %d items", len(resp.Errors))
+	}
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func runSecretsSet(cmd *cobra.Command, args []string) error {
+	var (
+		value []byte
+		err   error
+	)
+	if secretFromFile != "" {
+		value, err = os.ReadFile(secretFromFile)
+	} else {
+		value, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secret value: %w", err)
+	}
+
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	_, err = client.SetSecret(ctx, &squadv1alpha1.SetSecretRequest{
+		Service: secretService,
+		Name:    secretName,
+		Value:   value,
+	})
+	if err != nil {
+		return fmt.Errorf("set secret failed: %w", err)
+	}
+
+	fmt.Printf("Secret %s set for %s; it is used from the service's next deploy\n", secretName, secretService)
+	return nil
+}
+
+func runSecretsList(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	resp, err := client.ListSecrets(ctx, &squadv1alpha1.ListSecretsRequest{Service: secretService})
+	if err != nil {
+		return fmt.Errorf("list secrets failed: %w", err)
+	}
+
+	for _, s := range resp.Secrets {
+		fmt.Printf("%s (updated %s)\n", s.Name, s.UpdatedAt.AsTime().Local().Format(time.RFC3339))
+	}
+	return nil
+}
+
+func runSecretsDelete(cmd *cobra.Command, args []string) error {
+	client, err := createClient()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("Bearer %s", authToken))
+
+	_, err = client.DeleteSecret(ctx, &squadv1alpha1.DeleteSecretRequest{
+		Service: secretService,
+		Name:    secretName,
+	})
+	if err != nil {
+		return fmt.Errorf("delete secret failed: %w", err)
+	}
+
+	fmt.Printf("Secret %s deleted for %s\n", secretName, secretService)
 	return nil
 }
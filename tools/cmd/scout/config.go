@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v74/github"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/baely/infra/tools/internal/githubapp"
+)
+
+// configFileName is scout's config, at the root of the infra repo.
+const configFileName = "scout.yaml"
+
+// Owner types.
+const (
+	ownerUser = "user"
+	ownerOrg  = "org"
+)
+
+// defaultTokenEnv is the environment variable tokens are read from unless an owner names another.
+const defaultTokenEnv = "GITHUB_TOKEN"
+
+// config lists the users and organizations whose repositories scout syncs.
+type config struct {
+	Owners []ownerConfig `yaml:"owners"`
+}
+
+// defaultConfig is used when there is no config file: every repository of
+// baely, with the default credentials.
+var defaultConfig = config{
+	Owners: []ownerConfig{{Name: "baely"}},
+}
+
+type ownerConfig struct {
+	// Name is the user or organization login, e.g. devhou-se.
+	Name string `yaml:"name"`
+	// Type is user (default) or org.
+	Type string `yaml:"type"`
+	// Include are glob patterns of the repositories to sync (default: all of them).
+	Include []string `yaml:"include"`
+	// Exclude are glob patterns of repositories not to sync, even if they are included.
+	Exclude []string `yaml:"exclude"`
+	// Credentials are what the owner's repositories are read with. Without
+	// any, the app from GITHUB_APP_ID is used if it is set, and GITHUB_TOKEN otherwise.
+	Credentials credentialsConfig `yaml:"credentials"`
+}
+
+type credentialsConfig struct {
+	// TokenEnv names the environment variable holding a token.
+	TokenEnv string `yaml:"token_env"`
+	// App authenticates as a GitHub App's installation on the owner instead of with a token.
+	App *appConfig `yaml:"app"`
+}
+
+// appConfig is a GitHub App. The ID and private key can be given directly or
+// read from environment variables, so secrets stay out of the config file.
+type appConfig struct {
+	ID             int64  `yaml:"id"`
+	IDEnv          string `yaml:"id_env"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PrivateKeyEnv  string `yaml:"private_key_env"`
+}
+
+// loadConfig reads scout's config. Without a config file, the default config is used.
+func loadConfig(filename string) (*config, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		c := defaultConfig
+		return &c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	c := &config{}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+	return c, nil
+}
+
+func (c *config) validate() error {
+	if len(c.Owners) == 0 {
+		return fmt.Errorf("no owners")
+	}
+
+	seen := make(map[string]bool)
+	for _, o := range c.Owners {
+		if o.Name == "" {
+			return fmt.Errorf("owner without a name")
+		}
+		if seen[strings.ToLower(o.Name)] {
+			return fmt.Errorf("owner %s is listed more than once", o.Name)
+		}
+		seen[strings.ToLower(o.Name)] = true
+
+		switch o.ownerType() {
+		case ownerUser, ownerOrg:
+		default:
+			return fmt.Errorf("owner %s: unknown type %q", o.Name, o.Type)
+		}
+		for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("owner %s: invalid pattern %q", o.Name, pattern)
+			}
+		}
+		if app := o.Credentials.App; app != nil {
+			if o.Credentials.TokenEnv != "" {
+				return fmt.Errorf("owner %s: credentials can have a token or an app, not both", o.Name)
+			}
+			if (app.ID == 0) == (app.IDEnv == "") {
+				return fmt.Errorf("owner %s: app needs exactly one of id and id_env", o.Name)
+			}
+			if (app.PrivateKeyFile == "") == (app.PrivateKeyEnv == "") {
+				return fmt.Errorf("owner %s: app needs exactly one of private_key_file and private_key_env", o.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// owner returns the config of the owner with the given login.
+func (c *config) owner(name string) (*ownerConfig, bool) {
+	for i := range c.Owners {
+		if strings.EqualFold(c.Owners[i].Name, name) {
+			return &c.Owners[i], true
+		}
+	}
+	return nil, false
+}
+
+func (o *ownerConfig) ownerType() string {
+	if o.Type == "" {
+		return ownerUser
+	}
+	return o.Type
+}
+
+// includes reports whether a repository of the owner is synced.
+func (o *ownerConfig) includes(repo string) bool {
+	for _, pattern := range o.Exclude {
+		if ok, _ := path.Match(pattern, repo); ok {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if ok, _ := path.Match(pattern, repo); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// clients creates GitHub clients for owners. An app shared by several owners
+// is only loaded once, so its installation tokens are managed in one place.
+type clients struct {
+	apps map[int64]*githubapp.App
+	// envApp is the app from GITHUB_APP_ID, once it has been loaded.
+	envApp       *githubapp.App
+	envAppLoaded bool
+}
+
+// client returns a client for the owner's repositories, and whether it is
+// authenticated as an app installation. Every client gets its own transport,
+// as each set of credentials has its own rate limit.
+func (cs *clients) client(o *ownerConfig) (*github.Client, bool, error) {
+	transport := newGitHubTransport(http.DefaultTransport)
+
+	app, err := cs.ownerApp(o)
+	if err != nil {
+		return nil, false, fmt.Errorf("owner %s: %w", o.Name, err)
+	}
+	if app != nil {
+		return app.Client(o.Name, transport), true, nil
+	}
+
+	env := o.Credentials.TokenEnv
+	if env == "" {
+		env = defaultTokenEnv
+	}
+	token := os.Getenv(env)
+	if token == "" {
+		return nil, false, fmt.Errorf("%s environment variable is required for %s", env, o.Name)
+	}
+	return github.NewClient(&http.Client{Transport: transport}).WithAuthToken(token), false, nil
+}
+
+// ownerApp returns the app an owner's repositories are read with, or nil if
+// they are read with a token.
+func (cs *clients) ownerApp(o *ownerConfig) (*githubapp.App, error) {
+	if o.Credentials.App != nil {
+		return cs.app(o.Credentials.App)
+	}
+	if o.Credentials.TokenEnv != "" {
+		return nil, nil
+	}
+
+	if !cs.envAppLoaded {
+		app, err := githubapp.FromEnv(newGitHubTransport(http.DefaultTransport))
+		if err != nil {
+			return nil, err
+		}
+		cs.envApp, cs.envAppLoaded = app, true
+	}
+	return cs.envApp, nil
+}
+
+func (cs *clients) app(ac *appConfig) (*githubapp.App, error) {
+	id := ac.ID
+	if ac.IDEnv != "" {
+		var err error
+		id, err = strconv.ParseInt(os.Getenv(ac.IDEnv), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s environment variable must be a GitHub App ID", ac.IDEnv)
+		}
+	}
+	if app, ok := cs.apps[id]; ok {
+		return app, nil
+	}
+
+	var key []byte
+	if ac.PrivateKeyEnv != "" {
+		key = []byte(os.Getenv(ac.PrivateKeyEnv))
+		if len(key) == 0 {
+			return nil, fmt.Errorf("%s environment variable is required", ac.PrivateKeyEnv)
+		}
+	} else {
+		var err error
+		key, err = os.ReadFile(ac.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+
+	app, err := githubapp.New(id, key, newGitHubTransport(http.DefaultTransport))
+	if err != nil {
+		return nil, err
+	}
+	if cs.apps == nil {
+		cs.apps = make(map[int64]*githubapp.App)
+	}
+	cs.apps[id] = app
+	return app, nil
+}
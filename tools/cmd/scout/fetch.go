@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v74/github"
+)
+
+// maxArchiveRedirects is how many redirects are followed to find a tarball.
+const maxArchiveRedirects = 3
+
+// deployFile is a file in a repository's deploy config.
+type deployFile struct {
+	// path is relative to config/, e.g. environments/stage/vars.yaml.
+	path    string
+	content []byte
+}
+
+// hasDeployConfig reports whether a repository has a config/ directory at a commit.
+// It only costs one request, so repositories without config are never downloaded.
+func hasDeployConfig(ctx context.Context, client *github.Client, owner, repo, sha string) (bool, error) {
+	tree, _, err := client.Git.GetTree(ctx, owner, repo, sha, false)
+	if err != nil {
+		return false, fmt.Errorf("failed to get tree: %w", err)
+	}
+	for _, entry := range tree.Entries {
+		if entry.GetPath() == deployDir && entry.GetType() == "tree" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fetchDeployFiles downloads the tarball of a repository at a commit and
+// returns every regular file in its config/ directory. The tarball is one
+// API request however many files there are; downloading it isn't rate limited.
+func fetchDeployFiles(ctx context.Context, client *github.Client, owner, repo, sha string) ([]deployFile, error) {
+	archiveURL, _, err := client.Repositories.GetArchiveLink(ctx, owner, repo, github.Tarball, &github.RepositoryContentGetOptions{Ref: sha}, maxArchiveRedirects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tarball link: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, archiveURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// The client's transport retries the download and honours rate limits like any other request.
+	resp, err := client.Client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tarball: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download tarball: HTTP %d", resp.StatusCode)
+	}
+
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tarball: %w", err)
+	}
+	defer gz.Close()
+
+	var files []deployFile
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tarball: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// Entries are prefixed with a directory named after the repo and commit.
+		_, name, ok := strings.Cut(header.Name, "/")
+		if !ok {
+			continue
+		}
+		rel, ok := strings.CutPrefix(path.Clean(name), deployDir+"/")
+		if !ok {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from tarball: %w", name, err)
+		}
+		files = append(files, deployFile{path: rel, content: content})
+	}
+	return files, nil
+}
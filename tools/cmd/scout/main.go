@@ -1,33 +1,40 @@
-// Scout tracks all repositories for a user and pulls down deploy config into the appropriate location.
+// Scout tracks all repositories of the configured users and organizations and pulls down deploy config into the appropriate location.
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"os"
 	"path"
 	"strings"
+	"sync"
 
 	"github.com/google/go-github/v74/github"
 	"github.com/spf13/cobra"
-)
 
-const (
-	githubUser = "baely"
-	deployDir  = "config"
+	"github.com/baely/infra/tools/internal/routing"
 )
 
+const deployDir = "config"
+
+// defaultEnvironment is the environment Coach deploys to unless it's told
+// otherwise, so the one routing conflicts are linted in first.
+const defaultEnvironment = "prod"
+
 var (
-	githubToken  = os.Getenv("GITHUB_TOKEN")
 	requiredDirs = []string{"docker"}
+
+	// force syncs repositories even if they haven't changed since they were last synced.
+	force bool
+	// concurrency is how many repositories scan syncs at once.
+	concurrency int
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "scout",
-	Short: "Scout tracks all repositories for a user and pulls down deploy config",
-	Long:  "Scout tracks all repositories for a user and pulls down deploy config into the appropriate location.",
+	Short: "Scout tracks all repositories of the configured owners and pulls down deploy config",
+	Long:  "Scout tracks all repositories of the users and organizations in scout.yaml and pulls down deploy config into the appropriate location.",
 }
 
 var scanCmd = &cobra.Command{
@@ -40,9 +47,9 @@ var scanCmd = &cobra.Command{
 }
 
 var repoCmd = &cobra.Command{
-	Use:   "repo <name> [ref]",
+	Use:   "repo <[owner/]name> [ref]",
 	Short: "Scan specific repository",
-	Long:  "Scan a specific repository at a given ref (default: HEAD)",
+	Long:  "Scan a specific repository at a given ref (default: HEAD). The owner defaults to the first one in scout.yaml.",
 	Args:  cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		repo := args[0]
@@ -54,9 +61,37 @@ var repoCmd = &cobra.Command{
 	},
 }
 
+var stageCmd = &cobra.Command{
+	Use:   "stage <[owner/]name> <ref>",
+	Short: "Sync a repository and open a pull request with its config",
+	Long:  "Sync a repository at a given ref, then commit its config to a branch of the infra repo and open or update a pull request for it.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runStage(args[0], args[1])
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check deploy config for routing conflicts",
+	Long:  "Check every service's deploy.yaml under docker/ for traefik routing conflicts between services",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLint()
+	},
+}
+
 func main() {
+	rootCmd.PersistentFlags().BoolVar(&force, "force", false, "Sync repositories even if their commit hasn't changed since the last sync")
+
+	scanCmd.Flags().IntVar(&concurrency, "concurrency", 4, "Number of repositories to sync at once")
+	verifyCmd.Flags().BoolVar(&requireManifest, "require-manifest", false, "Fail for service directories without a .scout.json instead of skipping them")
+
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(repoCmd)
+	rootCmd.AddCommand(stageCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(lintCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -82,8 +117,9 @@ func must[T any](v T, err error) T {
 
 // fetchDeployConfigs finds all GitHub repositories that contain deploy config.
 func fetchDeployConfigs(ctx context.Context) error {
-	if githubToken == "" {
-		return fmt.Errorf("GITHUB_TOKEN environment variable is required")
+	cfg, err := loadConfig(configFileName)
+	if err != nil {
+		return err
 	}
 
 	// Ensure required directories exist
@@ -93,215 +129,280 @@ func fetchDeployConfigs(ctx context.Context) error {
 		}
 	}
 
-	client := github.NewClient(nil).WithAuthToken(githubToken)
-
-	var allRepositories []*github.Repository
-	opts := &github.RepositoryListByUserOptions{
-		ListOptions: github.ListOptions{
-			Page:    0,
-			PerPage: 100,
-		},
+	state, err := loadState(stateFileName)
+	if err != nil {
+		return err
 	}
 
-	for {
-		repositories, resp, err := client.Repositories.ListByUser(ctx, githubUser, opts)
+	// An owner that can't be listed fails on its own; the rest are still synced.
+	var targets []target
+	var ownerErrs []error
+	cs := &clients{}
+	for i := range cfg.Owners {
+		owner := &cfg.Owners[i]
+		client, installation, err := cs.client(owner)
 		if err != nil {
-			return fmt.Errorf("failed to list repos: %w", err)
-		}
-
-		allRepositories = append(allRepositories, repositories...)
-
-		if resp.NextPage == 0 {
-			break
-		}
-		opts.Page = resp.NextPage
-	}
-
-	for _, repository := range allRepositories {
-		if *repository.Owner.Login != githubUser {
+			ownerErrs = append(ownerErrs, err)
 			continue
 		}
-
-		_, dirContent, _, err := client.Repositories.GetContents(ctx, *repository.Owner.Login, *repository.Name, deployDir, nil)
+		repositories, err := listRepositories(ctx, client, owner, installation)
 		if err != nil {
-			// Skip repositories without deploy config
+			ownerErrs = append(ownerErrs, fmt.Errorf("failed to list repositories of %s: %w", owner.Name, err))
 			continue
 		}
-
-		if len(dirContent) == 0 {
-			continue
+		for _, repository := range repositories {
+			targets = append(targets, target{client: client, repository: repository})
 		}
+	}
 
-		displayURL := repository.GetHTMLURL()
-		displayURL = strings.TrimPrefix(displayURL, "https://")
-		displayURL = strings.ReplaceAll(displayURL, "/", "_")
+	outcomes := syncRepositories(ctx, state, targets)
+	if err := state.save(stateFileName); err != nil {
+		return err
+	}
 
-		// Create directory if required
-		dir := path.Join("docker", displayURL)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to create directory %s: %v\n", dir, err)
-			continue
-		}
+	return summarize(outcomes, ownerErrs)
+}
 
-		// Get the latest commit SHA for the repository
-		latestCommit, _, err := client.Repositories.GetCommit(ctx, *repository.Owner.Login, *repository.Name, "HEAD", nil)
+// listRepositories returns the owner's repositories that the config includes.
+// For an app installation they are the repositories the installation can
+// read, which includes private ones.
+func listRepositories(ctx context.Context, client *github.Client, owner *ownerConfig, installation bool) ([]*github.Repository, error) {
+	var all []*github.Repository
+	opts := github.ListOptions{PerPage: 100}
+	for {
+		var repositories []*github.Repository
+		var resp *github.Response
+		var err error
+		switch {
+		case installation:
+			var list *github.ListRepositories
+			list, resp, err = client.Apps.ListRepos(ctx, &opts)
+			if list != nil {
+				repositories = list.Repositories
+			}
+		case owner.ownerType() == ownerOrg:
+			repositories, resp, err = client.Repositories.ListByOrg(ctx, owner.Name, &github.RepositoryListByOrgOptions{ListOptions: opts})
+		default:
+			repositories, resp, err = client.Repositories.ListByUser(ctx, owner.Name, &github.RepositoryListByUserOptions{ListOptions: opts})
+		}
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to get latest commit for %s: %v\n", *repository.Name, err)
-			continue
+			return nil, err
 		}
-		latestSHA := latestCommit.GetSHA()
 
-		for _, content := range dirContent {
-			rawURL := content.GetDownloadURL()
-			if rawURL == "" {
-				continue
-			}
+		all = append(all, repositories...)
 
-			filename := path.Join(dir, content.GetName())
-			if err := downloadFile(rawURL, filename); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to download %s: %v\n", content.GetName(), err)
-				continue
-			}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
 
-			// Replace SHA placeholders in the downloaded file
-			if err := replaceShaPlaceholders(filename, latestSHA); err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: failed to replace SHA placeholders in %s: %v\n", content.GetName(), err)
-			}
+	var included []*github.Repository
+	for _, repository := range all {
+		if strings.EqualFold(repository.GetOwner().GetLogin(), owner.Name) && owner.includes(repository.GetName()) {
+			included = append(included, repository)
 		}
 	}
+	return included, nil
+}
 
-	return nil
+// target is a repository to sync and the client to sync it with.
+type target struct {
+	client     *github.Client
+	repository *github.Repository
 }
 
-func downloadFile(url, filename string) error {
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
+// syncOutcome is the result of syncing one repository during a scan.
+type syncOutcome struct {
+	repository *github.Repository
+	result     *syncResult
+	err        error
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %d", resp.StatusCode)
+// syncRepositories syncs repositories at HEAD with a pool of workers. The
+// outcomes are in the same order as targets.
+func syncRepositories(ctx context.Context, state *scoutState, targets []target) []syncOutcome {
+	outcomes := make([]syncOutcome, len(targets))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				t := targets[i]
+				result, err := syncRepository(ctx, t.client, state, t.repository, "HEAD", force)
+				outcomes[i] = syncOutcome{repository: t.repository, result: result, err: err}
+			}
+		}()
 	}
 
-	b, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to read response: %w", err)
+	for i := range targets {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
 
-	return os.WriteFile(filename, b, 0644)
+	return outcomes
 }
 
-func replaceShaPlaceholders(filename, sha string) error {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+// summarize prints what a scan changed, followed by how many repositories
+// were synced, skipped and failed. It returns an error if any repository or
+// owner failed.
+func summarize(outcomes []syncOutcome, ownerErrs []error) error {
+	var synced, unchanged, skipped, noConfig int
+	var failed []syncOutcome
+	for _, o := range outcomes {
+		switch {
+		case errors.Is(o.err, errNoDeployConfig):
+			noConfig++
+			if o.result.hasChanges() {
+				printSyncResult(o.repository.GetFullName(), o.result)
+			}
+		case o.err != nil:
+			failed = append(failed, o)
+		case o.result.skipped:
+			skipped++
+		case o.result.hasChanges():
+			synced++
+			printSyncResult(o.repository.GetFullName(), o.result)
+		default:
+			unchanged++
+		}
 	}
 
-	updatedContent := strings.ReplaceAll(string(content), "{{sha}}", sha)
-
-	if err := os.WriteFile(filename, []byte(updatedContent), 0644); err != nil {
-		return fmt.Errorf("failed to write updated file: %w", err)
+	fmt.Printf("\nScanned %d repositories: %d synced, %d up to date, %d skipped as unchanged, %d without deploy config, %d failed\n",
+		len(outcomes), synced, unchanged, skipped, noConfig, len(failed))
+	for _, o := range failed {
+		fmt.Printf("  %s: %v\n", o.repository.GetFullName(), o.err)
+	}
+	for _, err := range ownerErrs {
+		fmt.Printf("  %v\n", err)
+	}
+	if len(failed) > 0 || len(ownerErrs) > 0 {
+		return fmt.Errorf("failed to sync %d repositories and %d owners", len(failed), len(ownerErrs))
 	}
-
 	return nil
 }
 
-func runSingleRepo(repoName, ref string) error {
-	if githubToken == "" {
-		return fmt.Errorf("GITHUB_TOKEN environment variable is required")
-	}
-
+func runSingleRepo(name, ref string) error {
 	// Move out of the go directory
 	if err := os.Chdir(path.Dir(must(os.Getwd()))); err != nil {
 		return fmt.Errorf("failed to change directory: %w", err)
 	}
 
+	cfg, err := loadConfig(configFileName)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = syncSingleRepo(context.Background(), cfg, &clients{}, name, ref)
+	return err
+}
+
+// syncSingleRepo syncs one repository, given as [owner/]name, at ref and
+// prints what changed.
+func syncSingleRepo(ctx context.Context, cfg *config, cs *clients, name, ref string) (*github.Repository, *syncResult, error) {
+	ownerName, repoName, ok := strings.Cut(name, "/")
+	if !ok {
+		ownerName, repoName = cfg.Owners[0].Name, name
+	}
+	owner, ok := cfg.owner(ownerName)
+	if !ok {
+		return nil, nil, fmt.Errorf("owner %s is not in %s", ownerName, configFileName)
+	}
+
 	// Ensure required directories exist
 	for _, dir := range requiredDirs {
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+			return nil, nil, fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
 
-	ctx := context.Background()
-	client := github.NewClient(nil).WithAuthToken(githubToken)
-
-	// Get repository info
-	repository, _, err := client.Repositories.Get(ctx, githubUser, repoName)
+	client, _, err := cs.client(owner)
 	if err != nil {
-		return fmt.Errorf("failed to get repository %s: %w", repoName, err)
+		return nil, nil, err
 	}
 
-	// Get deploy config directory
-	_, dirContent, _, err := client.Repositories.GetContents(ctx, githubUser, repoName, deployDir, &github.RepositoryContentGetOptions{
-		Ref: ref,
-	})
+	// Get repository info
+	repository, _, err := client.Repositories.Get(ctx, owner.Name, repoName)
 	if err != nil {
-		return fmt.Errorf("failed to get deploy config for %s at ref %s: %w", repoName, ref, err)
+		return nil, nil, fmt.Errorf("failed to get repository %s/%s: %w", owner.Name, repoName, err)
 	}
 
-	if len(dirContent) == 0 {
-		return fmt.Errorf("no deploy config found in %s at ref %s", repoName, ref)
+	state, err := loadState(stateFileName)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	displayURL := repository.GetHTMLURL()
-	displayURL = strings.TrimPrefix(displayURL, "https://")
-	displayURL = strings.ReplaceAll(displayURL, "/", "_")
-
-	// Create directory
-	dir := path.Join("docker", displayURL)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	result, err := syncRepository(ctx, client, state, repository, ref, force)
+	if errors.Is(err, errNoDeployConfig) {
+		if result.hasChanges() {
+			printSyncResult(repository.GetFullName(), result)
+			if err := state.save(stateFileName); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, fmt.Errorf("no deploy config found in %s at ref %s", repository.GetFullName(), ref)
 	}
-
-	// Get commit SHA for the specified ref
-	commit, _, err := client.Repositories.GetCommit(ctx, githubUser, repoName, ref, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get commit for %s at ref %s: %w", repoName, ref, err)
+		return nil, nil, fmt.Errorf("failed to sync %s at ref %s: %w", repository.GetFullName(), ref, err)
 	}
-	commitSHA := commit.GetSHA()
-
-	repoURL := repository.GetHTMLURL()
-
-	for _, content := range dirContent {
-		rawURL := content.GetDownloadURL()
-		if rawURL == "" {
-			continue
-		}
-
-		filename := path.Join(dir, content.GetName())
-		if err := downloadFile(rawURL, filename); err != nil {
-			return fmt.Errorf("failed to download %s: %w", content.GetName(), err)
-		}
 
-		// Replace SHA placeholders
-		if err := replaceShaPlaceholders(filename, commitSHA); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to replace SHA placeholders in %s: %v\n", content.GetName(), err)
-		}
-
-		// Add header comments
-		if err := addHeaderComments(filename, repoURL, ref); err != nil {
-			return fmt.Errorf("failed to add header comments to %s: %w", content.GetName(), err)
-		}
+	printSyncResult(repository.GetFullName(), result)
+	if err := state.save(stateFileName); err != nil {
+		return nil, nil, err
 	}
+	return repository, result, nil
+}
 
-	fmt.Printf("Successfully scanned %s at ref %s\n", repoName, ref)
-	return nil
+func printSyncResult(repoName string, result *syncResult) {
+	if result.skipped {
+		fmt.Printf("%s at %s is unchanged since the last sync, skipped\n", repoName, result.sha)
+		return
+	}
+	if !result.hasChanges() {
+		fmt.Printf("%s at %s is up to date\n", repoName, result.sha)
+		return
+	}
+	fmt.Printf("Synced %s at %s: %d added, %d changed, %d removed\n", repoName, result.sha, len(result.added), len(result.changed), len(result.removed))
+	for _, filename := range result.added {
+		fmt.Printf("  + %s\n", filename)
+	}
+	for _, filename := range result.changed {
+		fmt.Printf("  ~ %s\n", filename)
+	}
+	for _, filename := range result.removed {
+		fmt.Printf("  - %s\n", filename)
+	}
 }
 
-func addHeaderComments(filename, repoURL, ref string) error {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+func runLint() error {
+	// Move out of the go directory
+	if err := os.Chdir(path.Dir(must(os.Getwd()))); err != nil {
+		return fmt.Errorf("failed to change directory: %w", err)
 	}
 
-	header := fmt.Sprintf("# Repo: %s\n# Ref: %s\n\n", repoURL, ref)
-	updatedContent := header + string(content)
+	services, err := routing.ReadServices("docker")
+	if err != nil {
+		return fmt.Errorf("failed to load deploy config: %w", err)
+	}
 
-	if err := os.WriteFile(filename, []byte(updatedContent), 0644); err != nil {
-		return fmt.Errorf("failed to write updated file: %w", err)
+	conflicts, unlinted, err := routing.LintEnvironments(services, defaultEnvironment)
+	if err != nil {
+		return fmt.Errorf("failed to lint deploy config: %w", err)
+	}
+	for _, u := range unlinted {
+		fmt.Printf("not linted: %s: %s\n", u.Path, u.Reason)
+	}
+	for _, c := range conflicts {
+		fmt.Printf("%s: %s: %s\n", c.Environment, c.Kind, c.Message)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("found %d routing conflicts", len(conflicts))
 	}
 
+	fmt.Printf("No routing conflicts found in %d services\n", len(services))
 	return nil
 }
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v74/github"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/baely/infra/tools/internal/sopsfile"
+	"github.com/baely/infra/tools/internal/templatefuncs"
+)
+
+// repoSettingsFileName is a repository's settings for scout, in its config/
+// directory. It is read while syncing and isn't written to the infra repo.
+const repoSettingsFileName = ".scout.yaml"
+
+// placeholderPattern matches scout's placeholders, e.g. {{sha}}. Coach's
+// templates have a space or a dot inside the braces, e.g. {{ .sha }}, so
+// they are never mistaken for placeholders.
+var placeholderPattern = regexp.MustCompile(`\{\{([a-z_]+)\}\}`)
+
+// templateKeywords are Go template actions that look like placeholders, e.g.
+// {{end}}. They are left for Coach to render, as are Coach's template
+// functions, e.g. {{lower}}.
+var templateKeywords = map[string]bool{
+	"end":      true,
+	"else":     true,
+	"break":    true,
+	"continue": true,
+	"nil":      true,
+	"true":     true,
+	"false":    true,
+}
+
+// substitutedExts are the file types placeholders are substituted in unless a
+// repository's settings say otherwise.
+var substitutedExts = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".env":  true,
+	".tmpl": true,
+}
+
+// repoSettings is a repository's .scout.yaml.
+type repoSettings struct {
+	Placeholders struct {
+		// Include are glob patterns of files to substitute placeholders in, on top of the default types.
+		Include []string `yaml:"include"`
+		// Exclude are glob patterns of files to leave as they are, even if they are included.
+		Exclude []string `yaml:"exclude"`
+	} `yaml:"placeholders"`
+}
+
+// repoSettingsFrom returns the repository's settings, and its deploy files
+// without the settings file.
+func repoSettingsFrom(files []deployFile) (*repoSettings, []deployFile, error) {
+	settings := &repoSettings{}
+	var rest []deployFile
+	for _, file := range files {
+		if file.path != repoSettingsFileName {
+			rest = append(rest, file)
+			continue
+		}
+		if err := yaml.Unmarshal(file.content, settings); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", repoSettingsFileName, err)
+		}
+	}
+
+	for _, pattern := range append(append([]string{}, settings.Placeholders.Include...), settings.Placeholders.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid pattern %q in %s", pattern, repoSettingsFileName)
+		}
+	}
+	return settings, rest, nil
+}
+
+// substitutes reports whether placeholders are substituted in a file, by its
+// path relative to config/. Encrypted and binary files never are, as
+// changing them would corrupt them.
+func (s *repoSettings) substitutes(file deployFile) bool {
+	if sopsfile.IsEncrypted(path.Base(file.path)) || !isText(file.content) {
+		return false
+	}
+	if matchesAny(s.Placeholders.Exclude, file.path) {
+		return false
+	}
+	return matchesAny(s.Placeholders.Include, file.path) || substitutedExts[path.Ext(file.path)]
+}
+
+// matchesAny reports whether a file matches any of the patterns. Patterns
+// without a slash match the file's name in any directory, e.g. *.json.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := name
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(name)
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isText reports whether content is UTF-8 text without NUL bytes.
+func isText(content []byte) bool {
+	return utf8.Valid(content) && bytes.IndexByte(content, 0) < 0
+}
+
+// placeholders are the values of the placeholders for one commit of a
+// repository. Values that cost a request are only looked up once a file uses them.
+type placeholders struct {
+	ctx        context.Context
+	client     *github.Client
+	repository *github.Repository
+	ref        string
+	sha        string
+	values     map[string]string
+}
+
+func newPlaceholders(ctx context.Context, client *github.Client, repository *github.Repository, ref, sha string) *placeholders {
+	return &placeholders{
+		ctx:        ctx,
+		client:     client,
+		repository: repository,
+		ref:        ref,
+		sha:        sha,
+		values:     make(map[string]string),
+	}
+}
+
+// substitute replaces every placeholder in content and returns the names of
+// those it replaced, sorted. It fails on the first placeholder that scout
+// doesn't know.
+func (p *placeholders) substitute(content []byte) ([]byte, []string, error) {
+	var err error
+	used := make(map[string]bool)
+	out := placeholderPattern.ReplaceAllFunc(content, func(m []byte) []byte {
+		name := string(m[2 : len(m)-2])
+		if templateKeywords[name] || templatefuncs.IsName(name) || err != nil {
+			return m
+		}
+		v, verr := p.value(name)
+		if verr != nil {
+			err = verr
+			return m
+		}
+		used[name] = true
+		return []byte(v)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return out, names, nil
+}
+
+func (p *placeholders) value(name string) (string, error) {
+	if v, ok := p.values[name]; ok {
+		return v, nil
+	}
+
+	var v string
+	var err error
+	switch name {
+	case "sha":
+		v = p.sha
+	case "short_sha":
+		v = p.sha[:min(7, len(p.sha))]
+	case "ref":
+		v = p.refName()
+	case "repo":
+		v = p.repository.GetName()
+	case "owner":
+		v = p.repository.GetOwner().GetLogin()
+	case "tag":
+		v, err = p.tag()
+	case "timestamp":
+		v, err = p.timestamp()
+	default:
+		return "", fmt.Errorf("unknown placeholder {{%s}}", name)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	p.values[name] = v
+	return v, nil
+}
+
+// refName is the ref that was synced, with HEAD named as the default branch.
+func (p *placeholders) refName() string {
+	return refName(p.repository, p.ref)
+}
+
+// refName names a ref of a repository as it is recorded in manifests: HEAD
+// is named as the default branch, and refs/heads/ and refs/tags/ are dropped.
+func refName(repository *github.Repository, ref string) string {
+	if ref == "HEAD" {
+		return repository.GetDefaultBranch()
+	}
+	ref = strings.TrimPrefix(ref, "refs/heads/")
+	return strings.TrimPrefix(ref, "refs/tags/")
+}
+
+// tag is the tag that was synced, or the commit SHA if the ref isn't a tag,
+// like the image tags `coachassistant assemble --tag sha` produces.
+func (p *placeholders) tag() (string, error) {
+	if tag, ok := strings.CutPrefix(p.ref, "refs/tags/"); ok {
+		return tag, nil
+	}
+	if p.ref == "HEAD" || p.ref == p.sha || strings.HasPrefix(p.ref, "refs/") {
+		return p.sha, nil
+	}
+
+	owner, name := p.repository.GetOwner().GetLogin(), p.repository.GetName()
+	_, resp, err := p.client.Git.GetRef(p.ctx, owner, name, "tags/"+p.ref)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return p.sha, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up tag %s: %w", p.ref, err)
+	}
+	return p.ref, nil
+}
+
+// timestamp is when the commit was made, so it is the same however many times the commit is synced.
+func (p *placeholders) timestamp() (string, error) {
+	owner, name := p.repository.GetOwner().GetLogin(), p.repository.GetName()
+	commit, _, err := p.client.Git.GetCommit(p.ctx, owner, name, p.sha)
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit %s: %w", p.sha, err)
+	}
+	return commit.GetCommitter().GetDate().UTC().Format(time.RFC3339), nil
+}
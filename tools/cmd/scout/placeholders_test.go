@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v74/github"
+)
+
+func testPlaceholders() *placeholders {
+	repository := &github.Repository{
+		Name:          github.Ptr("blog"),
+		Owner:         &github.User{Login: github.Ptr("baely")},
+		DefaultBranch: github.Ptr("main"),
+	}
+	return newPlaceholders(context.Background(), nil, repository, "HEAD", "0123456789abcdef")
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		content  string
+		want     string
+		wantUsed []string
+	}{
+		{
+			content:  "image: registry.baileys.dev/{{repo}}:{{sha}}\n",
+			want:     "image: registry.baileys.dev/blog:0123456789abcdef\n",
+			wantUsed: []string{"repo", "sha"},
+		},
+		{
+			content:  "{{owner}}/{{repo}}@{{ref}} {{short_sha}} {{short_sha}}",
+			want:     "baely/blog@main 0123456 0123456",
+			wantUsed: []string{"owner", "ref", "repo", "short_sha"},
+		},
+		{
+			content:  "{{ .sha }} {{if .vars.debug}}{{lower .service}}{{else}}{{end}} {{lower}} {{trimPrefix}}",
+			want:     "{{ .sha }} {{if .vars.debug}}{{lower .service}}{{else}}{{end}} {{lower}} {{trimPrefix}}",
+			wantUsed: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		got, used, err := testPlaceholders().substitute([]byte(tt.content))
+		if err != nil {
+			t.Errorf("substitute(%q): %v", tt.content, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("substitute(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+		if !reflect.DeepEqual(used, tt.wantUsed) {
+			t.Errorf("substitute(%q) used %q, want %q", tt.content, used, tt.wantUsed)
+		}
+	}
+}
+
+func TestSubstituteUnknownPlaceholder(t *testing.T) {
+	_, _, err := testPlaceholders().substitute([]byte("{{sha}} {{branch}}"))
+	if err == nil || !strings.Contains(err.Error(), "unknown placeholder {{branch}}") {
+		t.Errorf("substitute() returned %v, want an unknown placeholder error", err)
+	}
+}
+
+func TestSubstitutes(t *testing.T) {
+	settings, _, err := repoSettingsFrom([]deployFile{{
+		path:    repoSettingsFileName,
+		content: []byte("placeholders:\n  include: [\"*.conf\", data/*.json]\n  exclude: [dashboards/*.yaml, secret.conf]\n"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		content string
+		want    bool
+	}{
+		{"deploy.yaml", "", true},
+		{"environments/stage/vars.yml", "", true},
+		{".env", "", true},
+		{"deploy.yaml.tmpl", "", true},
+		{"grafana.json", "", false},
+		{"data/seed.json", "", true},
+		{"other/data/seed.json", "", false},
+		{"nginx.conf", "", true},
+		{"nested/nginx.conf", "", true},
+		{"secret.conf", "", false},
+		{"dashboards/home.yaml", "", false},
+		{".enc.env", "", false},
+		{"logo.yaml", "\x00\x01", false},
+	}
+
+	for _, tt := range tests {
+		if got := settings.substitutes(deployFile{path: tt.path, content: []byte(tt.content)}); got != tt.want {
+			t.Errorf("substitutes(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if got := (&repoSettings{}).substitutes(deployFile{path: "grafana.json"}); got {
+		t.Errorf("substitutes(grafana.json) without settings = true, want false")
+	}
+}
+
+func TestRepoSettingsFrom(t *testing.T) {
+	files := []deployFile{{path: "deploy.yaml"}, {path: repoSettingsFileName, content: []byte("placeholders: {include: [\"*.conf\"]}")}}
+	settings, rest, err := repoSettingsFrom(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(settings.Placeholders.Include, []string{"*.conf"}) {
+		t.Errorf("include = %q, want [*.conf]", settings.Placeholders.Include)
+	}
+	if len(rest) != 1 || rest[0].path != "deploy.yaml" {
+		t.Errorf("repoSettingsFrom() kept %+v, want only deploy.yaml", rest)
+	}
+
+	if _, _, err := repoSettingsFrom([]deployFile{{path: repoSettingsFileName, content: []byte("placeholders: {exclude: [\"[\"]}")}}); err == nil {
+		t.Errorf("repoSettingsFrom() accepted an invalid pattern")
+	}
+}
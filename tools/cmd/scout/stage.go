@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/go-github/v74/github"
+	"github.com/pmezard/go-difflib/difflib"
+	"go.yaml.in/yaml/v3"
+)
+
+// Config is staged as a pull request against the infra repo's default branch.
+const (
+	infraOwner = "baely"
+	infraRepo  = "infra"
+	// stageBranchPrefix prefixes the branch each repository is staged on, e.g. scout/github.com_baely_blog.
+	stageBranchPrefix = "scout/"
+)
+
+// stagedFile is a file in a service's directory that differs from the infra repo's default branch.
+type stagedFile struct {
+	path   string
+	change string
+	// mode is the file's mode in the base tree, or empty for added files.
+	mode    string
+	before  []byte
+	content []byte
+}
+
+// Changes of staged files, as printed by repo and scan.
+const (
+	stageAdded   = "+"
+	stageChanged = "~"
+	stageRemoved = "-"
+)
+
+func runStage(name, ref string) error {
+	// Move out of the go directory
+	if err := os.Chdir(path.Dir(must(os.Getwd()))); err != nil {
+		return fmt.Errorf("failed to change directory: %w", err)
+	}
+
+	cfg, err := loadConfig(configFileName)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cs := &clients{}
+	repository, result, err := syncSingleRepo(ctx, cfg, cs, name, ref)
+	if err != nil {
+		return err
+	}
+
+	// The infra repo is read and written with its owner's credentials.
+	owner, ok := cfg.owner(infraOwner)
+	if !ok {
+		owner = &ownerConfig{Name: infraOwner}
+	}
+	client, _, err := cs.client(owner)
+	if err != nil {
+		return err
+	}
+
+	pr, err := stage(ctx, client, repository, result, ref)
+	if err != nil {
+		return fmt.Errorf("failed to stage %s: %w", repository.GetFullName(), err)
+	}
+	if pr == nil {
+		fmt.Printf("%s matches %s/%s, nothing to stage\n", result.dir, infraOwner, infraRepo)
+		return nil
+	}
+	fmt.Printf("Staged %s in %s\n", repository.GetFullName(), pr.GetHTMLURL())
+	return nil
+}
+
+// stage commits a synced repository's service directory on top of the infra
+// repo's default branch and opens a pull request for it. Each repository has
+// one branch, so restaging force-pushes it and updates the open pull request
+// instead of opening another. It returns nil if the directory already matches
+// the default branch.
+func stage(ctx context.Context, client *github.Client, repository *github.Repository, result *syncResult, ref string) (*github.PullRequest, error) {
+	infra, _, err := client.Repositories.Get(ctx, infraOwner, infraRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s/%s: %w", infraOwner, infraRepo, err)
+	}
+	base := infra.GetDefaultBranch()
+
+	baseRef, _, err := client.Git.GetRef(ctx, infraOwner, infraRepo, "refs/heads/"+base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", base, err)
+	}
+	baseSHA := baseRef.GetObject().GetSHA()
+	baseCommit, _, err := client.Git.GetCommit(ctx, infraOwner, infraRepo, baseSHA)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", baseSHA, err)
+	}
+
+	files, err := stagedFiles(ctx, client, baseCommit.GetTree().GetSHA(), result.dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	tree, err := createTree(ctx, client, baseCommit.GetTree().GetSHA(), files)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := stageBranchPrefix + path.Base(result.dir)
+	title := fmt.Sprintf("Update config for %s", repository.GetFullName())
+	if err := pushBranch(ctx, client, branch, baseSHA, tree, fmt.Sprintf("%s at %s", title, ref)); err != nil {
+		return nil, err
+	}
+
+	body := stageBody(repository, result, ref, files)
+	prs, _, err := client.PullRequests.List(ctx, infraOwner, infraRepo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  infraOwner + ":" + branch,
+		Base:  base,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if len(prs) > 0 {
+		pr, _, err := client.PullRequests.Edit(ctx, infraOwner, infraRepo, prs[0].GetNumber(), &github.PullRequest{
+			Title: &title,
+			Body:  &body,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update pull request #%d: %w", prs[0].GetNumber(), err)
+		}
+		return pr, nil
+	}
+
+	pr, _, err := client.PullRequests.Create(ctx, infraOwner, infraRepo, &github.NewPullRequest{
+		Title: &title,
+		Head:  &branch,
+		Base:  &base,
+		Body:  &body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return pr, nil
+}
+
+// stagedFiles compares the files in dir with the same directory in the base
+// tree, by their git blob hashes, and returns the ones that differ.
+func stagedFiles(ctx context.Context, client *github.Client, baseTree, dir string) ([]stagedFile, error) {
+	tree, _, err := client.Git.GetTree(ctx, infraOwner, infraRepo, baseTree, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+	if tree.GetTruncated() {
+		return nil, fmt.Errorf("tree of %s/%s is too large to compare", infraOwner, infraRepo)
+	}
+
+	upstream := make(map[string]*github.TreeEntry)
+	for _, entry := range tree.Entries {
+		if entry.GetType() == "blob" && strings.HasPrefix(entry.GetPath(), dir+"/") {
+			upstream[entry.GetPath()] = entry
+		}
+	}
+
+	var files []stagedFile
+	err = filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		p := filepath.ToSlash(filename)
+		entry, ok := upstream[p]
+		if !ok {
+			files = append(files, stagedFile{path: p, change: stageAdded, content: content})
+			return nil
+		}
+		delete(upstream, p)
+		if entry.GetSHA() == blobSHA(content) {
+			return nil
+		}
+
+		before, _, err := client.Git.GetBlobRaw(ctx, infraOwner, infraRepo, entry.GetSHA())
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", p, err)
+		}
+		files = append(files, stagedFile{path: p, change: stageChanged, mode: entry.GetMode(), before: before, content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for p, entry := range upstream {
+		before, _, err := client.Git.GetBlobRaw(ctx, infraOwner, infraRepo, entry.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", p, err)
+		}
+		files = append(files, stagedFile{path: p, change: stageRemoved, mode: entry.GetMode(), before: before})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	return files, nil
+}
+
+// blobSHA returns the hash git gives a file's content.
+func blobSHA(content []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(content))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// createTree creates a tree that is the base tree with files applied.
+// Text files are sent inline; anything else is uploaded as a blob first.
+func createTree(ctx context.Context, client *github.Client, baseTree string, files []stagedFile) (string, error) {
+	var entries []*github.TreeEntry
+	for _, file := range files {
+		mode := file.mode
+		if mode == "" {
+			mode = "100644"
+		}
+		entry := &github.TreeEntry{
+			Path: github.Ptr(file.path),
+			Mode: github.Ptr(mode),
+			Type: github.Ptr("blob"),
+		}
+
+		switch {
+		case file.change == stageRemoved:
+			// An entry without a SHA or content deletes the file.
+		case utf8.Valid(file.content):
+			entry.Content = github.Ptr(string(file.content))
+		default:
+			blob, _, err := client.Git.CreateBlob(ctx, infraOwner, infraRepo, &github.Blob{
+				Content:  github.Ptr(base64.StdEncoding.EncodeToString(file.content)),
+				Encoding: github.Ptr("base64"),
+			})
+			if err != nil {
+				return "", fmt.Errorf("failed to upload %s: %w", file.path, err)
+			}
+			entry.SHA = blob.SHA
+		}
+		entries = append(entries, entry)
+	}
+
+	tree, _, err := client.Git.CreateTree(ctx, infraOwner, infraRepo, baseTree, entries)
+	if err != nil {
+		return "", fmt.Errorf("failed to create tree: %w", err)
+	}
+	return tree.GetSHA(), nil
+}
+
+// pushBranch points branch at a commit of tree on top of parent. If the branch
+// already holds that, it is left alone so restaging unchanged config doesn't
+// rerun the pull request's checks.
+func pushBranch(ctx context.Context, client *github.Client, branch, parent, tree, message string) error {
+	ref := "refs/heads/" + branch
+	existing, resp, err := client.Git.GetRef(ctx, infraOwner, infraRepo, ref)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return fmt.Errorf("failed to get branch %s: %w", branch, err)
+	}
+
+	if existing != nil {
+		head, _, err := client.Git.GetCommit(ctx, infraOwner, infraRepo, existing.GetObject().GetSHA())
+		if err != nil {
+			return fmt.Errorf("failed to get head of %s: %w", branch, err)
+		}
+		if head.GetTree().GetSHA() == tree && len(head.Parents) == 1 && head.Parents[0].GetSHA() == parent {
+			return nil
+		}
+	}
+
+	commit, _, err := client.Git.CreateCommit(ctx, infraOwner, infraRepo, &github.Commit{
+		Message: github.Ptr(message),
+		Tree:    &github.Tree{SHA: github.Ptr(tree)},
+		Parents: []*github.Commit{{SHA: github.Ptr(parent)}},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create commit: %w", err)
+	}
+
+	reference := &github.Reference{
+		Ref:    github.Ptr(ref),
+		Object: &github.GitObject{SHA: commit.SHA},
+	}
+	if existing == nil {
+		_, _, err = client.Git.CreateRef(ctx, infraOwner, infraRepo, reference)
+	} else {
+		_, _, err = client.Git.UpdateRef(ctx, infraOwner, infraRepo, reference, true)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to push branch %s: %w", branch, err)
+	}
+	return nil
+}
+
+// stageBody renders a pull request's description: where the config came
+// from, the services it affects, and a summary of the changed files.
+func stageBody(repository *github.Repository, result *syncResult, ref string, files []stagedFile) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Updates deploy config from [%s@%.7s](%s/commit/%s)", repository.GetFullName(), result.sha, repository.GetHTMLURL(), result.sha)
+	if ref != result.sha {
+		fmt.Fprintf(&b, " (`%s`)", ref)
+	}
+	b.WriteString(".\n\n")
+
+	fmt.Fprintf(&b, "**Services:** `%s`", path.Base(result.dir))
+	if services := composeServices(result.dir); len(services) > 0 {
+		fmt.Fprintf(&b, " (`%s`)", strings.Join(services, "`, `"))
+	}
+	b.WriteString("\n\n")
+
+	b.WriteString("| | File | Lines |\n|---|---|---|\n")
+	for _, file := range files {
+		fmt.Fprintf(&b, "| %s | `%s` | %s |\n", file.change, strings.TrimPrefix(file.path, result.dir+"/"), lineSummary(file.before, file.content))
+	}
+	return b.String()
+}
+
+// composeServices returns the services in a directory's deploy.yaml, if it can be read.
+func composeServices(dir string) []string {
+	b, err := os.ReadFile(path.Join(dir, "deploy.yaml"))
+	if err != nil {
+		return nil
+	}
+	var project struct {
+		Services map[string]yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(b, &project); err != nil {
+		return nil
+	}
+
+	var services []string
+	for name := range project.Services {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// lineSummary counts the lines added and removed between two versions of a file.
+func lineSummary(before, after []byte) string {
+	if !utf8.Valid(before) || !utf8.Valid(after) {
+		return "binary"
+	}
+
+	var added, removed int
+	matcher := difflib.NewMatcher(splitLines(before), splitLines(after))
+	for _, op := range matcher.GetOpCodes() {
+		switch op.Tag {
+		case 'r':
+			removed += op.I2 - op.I1
+			added += op.J2 - op.J1
+		case 'd':
+			removed += op.I2 - op.I1
+		case 'i':
+			added += op.J2 - op.J1
+		}
+	}
+
+	var parts []string
+	if added > 0 {
+		parts = append(parts, fmt.Sprintf("+%d", added))
+	}
+	if removed > 0 {
+		parts = append(parts, fmt.Sprintf("-%d", removed))
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitLines splits a file into lines, keeping their line endings.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
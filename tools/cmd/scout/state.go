@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// stateFileName records the commit each repository was last synced at, so
+// unchanged repositories can be skipped. It lives at the root of the infra
+// repo, and is gitignored.
+const stateFileName = ".scout-state.json"
+
+type scoutState struct {
+	mu sync.Mutex
+	// Syncs is keyed by the repository's display name and the ref that was
+	// synced, e.g. github.com_baely_blog@HEAD.
+	Syncs map[string]repositoryState `json:"syncs"`
+}
+
+type repositoryState struct {
+	// SHA is the commit the repository was last synced at, whether or not it had deploy config.
+	SHA string `json:"sha"`
+	// NoDeployConfig is set if the repository had no deploy config at SHA.
+	NoDeployConfig bool `json:"no_deploy_config,omitempty"`
+}
+
+// stateKey is the key of a repository synced at a ref.
+func stateKey(display, ref string) string {
+	return display + "@" + ref
+}
+
+// loadState reads the state file. A missing file is an empty state.
+func loadState(filename string) (*scoutState, error) {
+	state := &scoutState{Syncs: make(map[string]repositoryState)}
+
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", filename, err)
+	}
+	if state.Syncs == nil {
+		state.Syncs = make(map[string]repositoryState)
+	}
+	return state, nil
+}
+
+// get returns how a repository was last synced at a ref, and whether it has been.
+func (s *scoutState) get(display, ref string) (repositoryState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.Syncs[stateKey(display, ref)]
+	return st, ok
+}
+
+// record records that a repository was synced at a ref and the commit it resolved to.
+func (s *scoutState) record(display, ref string, st repositoryState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Syncs[stateKey(display, ref)] = st
+}
+
+// save writes the state file. Its content only depends on the synced commits,
+// so syncing an unchanged repository leaves it untouched.
+func (s *scoutState) save(filename string) error {
+	s.mu.Lock()
+	b, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if _, err := writeIfChanged(filename, append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v74/github"
+
+	"github.com/baely/infra/tools/internal/provenance"
+	"github.com/baely/infra/tools/internal/sopsfile"
+)
+
+// errNoDeployConfig is returned by syncRepository for repositories without a
+// config/ directory, along with the files removed from their old mirror.
+var errNoDeployConfig = errors.New("no deploy config")
+
+// syncResult is what syncing a repository changed.
+type syncResult struct {
+	dir string
+	sha string
+	// skipped is set when the repository hadn't changed since it was last synced.
+	skipped bool
+	added   []string
+	changed []string
+	removed []string
+}
+
+func (r *syncResult) hasChanges() bool {
+	return len(r.added)+len(r.changed)+len(r.removed) > 0
+}
+
+// syncRepository mirrors a repository's config/ tree at ref into
+// docker/<display>/, removing files that are no longer upstream. scan and
+// repo both sync through it, so a repository synced by either at the same
+// commit produces byte-identical files:
+//   - ref is resolved to a commit first, and everything is fetched at that commit;
+//   - {{sha}} is replaced with the commit in every file that isn't encrypted;
+//   - files that support # comments get a header naming the repo and commit;
+//   - files are processed in path order and only written when their content changes.
+//
+// Unless force is set, a repository whose ref still resolves to the commit it
+// was last synced at is skipped, as long as its directory is still exactly
+// as scout left it. The check is a conditional request, which GitHub doesn't
+// count against the rate limit when nothing has changed.
+func syncRepository(ctx context.Context, client *github.Client, state *scoutState, repository *github.Repository, ref string, force bool) (*syncResult, error) {
+	owner, name := repository.GetOwner().GetLogin(), repository.GetName()
+	display := displayName(repository)
+	dir := path.Join("docker", display)
+
+	var lastSHA string
+	if !force {
+		lastSHA = lastSyncedSHA(state, repository, ref, dir)
+	}
+	sha, resp, err := client.Repositories.GetCommitSHA1(ctx, owner, name, ref, lastSHA)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return &syncResult{dir: dir, sha: lastSHA, skipped: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref %s: %w", ref, err)
+	}
+
+	ok, err := hasDeployConfig(ctx, client, owner, name, sha)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return removeMirror(state, display, ref, sha)
+	}
+
+	files, err := fetchDeployFiles(ctx, client, owner, name, sha)
+	if err != nil {
+		return nil, err
+	}
+	settings, files, err := repoSettingsFrom(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return removeMirror(state, display, ref, sha)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	vars := newPlaceholders(ctx, client, repository, ref, sha)
+
+	result := &syncResult{dir: dir, sha: sha}
+	upstream := make(map[string]bool)
+	write := func(filename string, b []byte) error {
+		if err := os.MkdirAll(path.Dir(filename), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", path.Dir(filename), err)
+		}
+		change, err := writeIfChanged(filename, b)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", filename, err)
+		}
+		switch change {
+		case fileAdded:
+			result.added = append(result.added, filename)
+		case fileChanged:
+			result.changed = append(result.changed, filename)
+		}
+		upstream[filename] = true
+		return nil
+	}
+
+	manifest := &provenance.Manifest{
+		Repository: repository.GetHTMLURL(),
+		Ref:        vars.refName(),
+		SHA:        sha,
+	}
+	for _, file := range files {
+		if file.path == provenance.FileName {
+			return nil, fmt.Errorf("%s/%s is reserved for scout's manifest", deployDir, provenance.FileName)
+		}
+
+		rendered, err := renderFile(file, settings, vars, repository.GetHTMLURL())
+		if err != nil {
+			return nil, err
+		}
+		if err := write(path.Join(dir, file.path), rendered.content); err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, provenance.File{
+			Path:         file.path,
+			Upstream:     path.Join(deployDir, file.path),
+			SHA256:       provenance.Checksum(rendered.content),
+			Header:       rendered.header,
+			Placeholders: rendered.placeholders,
+		})
+	}
+
+	b, err := marshalManifest(dir, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := write(path.Join(dir, provenance.FileName), b); err != nil {
+		return nil, err
+	}
+
+	result.removed, err = removeStaleFiles(dir, upstream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove stale files: %w", err)
+	}
+
+	state.record(display, ref, repositoryState{SHA: sha})
+	return result, nil
+}
+
+// lastSyncedSHA returns the commit a repository's directory was last synced
+// from at ref, or "" if it has to be synced again: because it hasn't been
+// synced at ref, or its directory has been edited, removed or synced at
+// another ref since. The directory's manifest is used when the state has no
+// record, e.g. in CI, where the state file isn't committed.
+func lastSyncedSHA(state *scoutState, repository *github.Repository, ref, dir string) string {
+	st, recorded := state.get(displayName(repository), ref)
+	if recorded && st.NoDeployConfig {
+		if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) {
+			return ""
+		}
+		return st.SHA
+	}
+
+	manifest, err := provenance.Read(dir)
+	if err != nil || manifest == nil {
+		return ""
+	}
+	if manifest.Repository != repository.GetHTMLURL() || manifest.Ref != refName(repository, ref) {
+		return ""
+	}
+	if recorded && st.SHA != manifest.SHA {
+		return ""
+	}
+	changes, err := manifest.Verify(dir)
+	if err != nil || len(changes) > 0 {
+		return ""
+	}
+	return manifest.SHA
+}
+
+// removeMirror removes the mirror of a repository that no longer has deploy
+// config, in case it had some before. It returns errNoDeployConfig, with the
+// files it removed.
+func removeMirror(state *scoutState, display, ref, sha string) (*syncResult, error) {
+	dir := path.Join("docker", display)
+	removed, err := removeStaleFiles(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+	if err := os.Remove(dir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+
+	state.record(display, ref, repositoryState{SHA: sha, NoDeployConfig: true})
+	return &syncResult{dir: dir, sha: sha, removed: removed}, errNoDeployConfig
+}
+
+// removeStaleFiles removes every file under dir that isn't upstream, then
+// any directories left empty. It returns the removed files in path order.
+func removeStaleFiles(dir string, upstream map[string]bool) ([]string, error) {
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	var removed, dirs []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		if upstream[filepath.ToSlash(p)] {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed = append(removed, filepath.ToSlash(p))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Deepest first, so parents are empty by the time they are reached.
+	for i := len(dirs) - 1; i > 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err == nil && len(entries) == 0 {
+			if err := os.Remove(dirs[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// displayName is the directory a repository's config is written to, e.g. github.com_baely_blog.
+func displayName(repository *github.Repository) string {
+	name := strings.TrimPrefix(repository.GetHTMLURL(), "https://")
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+// marshalManifest encodes a service directory's new manifest. If nothing but
+// the scan time differs from its current manifest, the current scan time is
+// kept, so syncing the same commit again leaves the directory untouched.
+func marshalManifest(dir string, manifest *provenance.Manifest) ([]byte, error) {
+	previous, err := provenance.Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		manifest.ScannedAt = previous.ScannedAt
+		b, err := manifest.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		if current, err := previous.Marshal(); err == nil && bytes.Equal(b, current) {
+			return b, nil
+		}
+	}
+
+	manifest.ScannedAt = time.Now().UTC().Truncate(time.Second)
+	return manifest.Marshal()
+}
+
+// renderedFile is a file as it is written to the infra repo.
+type renderedFile struct {
+	content []byte
+	// header is set if the # Repo and # Ref comments were prepended.
+	header bool
+	// placeholders are the names of the placeholders substituted in the file.
+	placeholders []string
+}
+
+// renderFile returns a downloaded file as it is written to the infra repo.
+// Provenance is only written into YAML and .env files, as # comments that
+// don't change what they mean; every other file's is only in the manifest.
+// Encrypted files are passed through untouched, or they could no longer be decrypted.
+func renderFile(file deployFile, settings *repoSettings, vars *placeholders, repoURL string) (*renderedFile, error) {
+	if sopsfile.IsEncrypted(path.Base(file.path)) {
+		return &renderedFile{content: file.content}, nil
+	}
+
+	rendered := &renderedFile{content: file.content}
+	if settings.substitutes(file) {
+		var err error
+		rendered.content, rendered.placeholders, err = vars.substitute(file.content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.path, err)
+		}
+	}
+
+	if supportsHashComments(path.Base(file.path)) && isText(rendered.content) {
+		header := fmt.Sprintf("# Repo: %s\n# Ref: %s\n\n", repoURL, vars.sha)
+		rendered.content = append([]byte(header), rendered.content...)
+		rendered.header = true
+	}
+	return rendered, nil
+}
+
+// supportsHashComments reports whether a file can start with # comments
+// without changing what it means. A comment would make JSON invalid, for one.
+func supportsHashComments(name string) bool {
+	switch path.Ext(name) {
+	case ".yaml", ".yml", ".env":
+		return true
+	}
+	return false
+}
+
+type fileChange int
+
+const (
+	fileUnchanged fileChange = iota
+	fileAdded
+	fileChanged
+)
+
+// writeIfChanged writes content to filename unless it already holds exactly
+// that content. It reports whether the file was added or changed.
+func writeIfChanged(filename string, content []byte) (fileChange, error) {
+	change := fileChanged
+	existing, err := os.ReadFile(filename)
+	switch {
+	case err == nil && bytes.Equal(existing, content):
+		return fileUnchanged, nil
+	case errors.Is(err, fs.ErrNotExist):
+		change = fileAdded
+	case err != nil:
+		return fileUnchanged, err
+	}
+	return change, os.WriteFile(filename, content, 0644)
+}
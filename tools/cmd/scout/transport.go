@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxAttempts is how many times a request is tried before its failure is returned.
+	maxAttempts = 4
+	// retryBackoff is the wait before the first retry. It doubles for every retry after.
+	retryBackoff = time.Second
+	// rateLimitReserve is how many requests are left unused before waiting for the rate limit to reset.
+	rateLimitReserve = 20
+)
+
+// githubTransport keeps scout within GitHub's rate limits and retries
+// transient failures, so a scan isn't cut short by either. It is shared by
+// every worker, so what one request learns about the rate limit holds back
+// all of them.
+type githubTransport struct {
+	base http.RoundTripper
+
+	mu sync.Mutex
+	// remaining and reset are from the rate limit headers of the last API response.
+	remaining int
+	reset     time.Time
+}
+
+func newGitHubTransport(base http.RoundTripper) *githubTransport {
+	return &githubTransport{base: base, remaining: -1}
+}
+
+func (t *githubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		if err := t.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err == nil {
+			t.observe(resp)
+		}
+
+		wait, retry := t.retryAfter(req, resp, err, attempt)
+		if !retry || attempt == maxAttempts {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		fmt.Fprintf(os.Stderr, "Retrying %s %s in %s (attempt %d of %d): %s\n", req.Method, req.URL.Path, wait.Round(time.Millisecond), attempt+1, maxAttempts, reason)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryAfter reports whether a request should be retried and how long to
+// wait first. Only idempotent requests are retried: network errors and
+// server errors are retried with jittered exponential backoff, and rate
+// limited requests once the limit allows.
+func (t *githubTransport) retryAfter(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return 0, false
+	}
+	if req.Context().Err() != nil {
+		return 0, false
+	}
+
+	backoff := retryBackoff << (attempt - 1)
+	backoff += rand.N(backoff)
+
+	if err != nil {
+		return backoff, true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		// Secondary rate limits say how long to wait; the primary limit says when it resets.
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return time.Until(t.resetTime()) + time.Second, true
+		}
+		return 0, false
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return backoff, true
+	}
+	return 0, false
+}
+
+// observe records the rate limit headers of an API response. Downloads
+// outside the API don't have them.
+func (t *githubTransport) observe(resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+}
+
+func (t *githubTransport) resetTime() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.reset
+}
+
+// waitForRateLimit blocks until the rate limit resets if it is nearly used up.
+func (t *githubTransport) waitForRateLimit(ctx context.Context) error {
+	t.mu.Lock()
+	remaining, reset := t.remaining, t.reset
+	t.mu.Unlock()
+
+	if remaining < 0 || remaining > rateLimitReserve || time.Now().After(reset) {
+		return nil
+	}
+
+	wait := time.Until(reset) + time.Second
+	fmt.Fprintf(os.Stderr, "%d GitHub API requests left, waiting %s for the rate limit to reset\n", remaining, wait.Round(time.Second))
+	return sleep(ctx, wait)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
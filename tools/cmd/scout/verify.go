@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/baely/infra/tools/internal/provenance"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [service...]",
+	Short: "Check synced config for hand edits",
+	Long:  "Compare every service directory under docker/, or the given ones, with its .scout.json manifest and report the files added, modified or removed since scout wrote them.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerify(args)
+	},
+}
+
+// requireManifest makes verify fail for service directories without a manifest instead of skipping them.
+var requireManifest bool
+
+// changeSymbols are how verify prints each kind of change, matching repo and scan.
+var changeSymbols = map[string]string{
+	provenance.Added:    "+",
+	provenance.Modified: "~",
+	provenance.Missing:  "-",
+}
+
+func runVerify(services []string) error {
+	// Move out of the go directory
+	if err := os.Chdir(path.Dir(must(os.Getwd()))); err != nil {
+		return fmt.Errorf("failed to change directory: %w", err)
+	}
+
+	dirs, err := serviceDirs("docker", services)
+	if err != nil {
+		return err
+	}
+	return verifyDirs(os.Stdout, dirs, requireManifest)
+}
+
+// serviceDirs returns the directories of the given services in dir, or of
+// every service if none are given.
+func serviceDirs(dir string, services []string) ([]string, error) {
+	var dirs []string
+	if len(services) == 0 {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				dirs = append(dirs, filepath.Join(dir, entry.Name()))
+			}
+		}
+		return dirs, nil
+	}
+
+	for _, service := range services {
+		serviceDir := filepath.Join(dir, service)
+		if _, err := os.Stat(serviceDir); err != nil {
+			return nil, fmt.Errorf("unknown service %s: %w", service, err)
+		}
+		dirs = append(dirs, serviceDir)
+	}
+	return dirs, nil
+}
+
+// verifyDirs compares each service directory with its manifest and prints
+// the changes to w. It fails if any directory has changed, or if one has no
+// manifest and manifests are required.
+func verifyDirs(w io.Writer, dirs []string, required bool) error {
+	var edited, unmanaged int
+	for _, dir := range dirs {
+		m, err := provenance.Read(dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		if m == nil {
+			// Directories scout hasn't synced since it started writing manifests can't be checked.
+			if required {
+				fmt.Fprintf(w, "%s has no %s\n", dir, provenance.FileName)
+			} else {
+				fmt.Fprintf(w, "%s has no %s, skipped\n", dir, provenance.FileName)
+			}
+			unmanaged++
+			continue
+		}
+
+		changes, err := m.Verify(dir)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		edited++
+		fmt.Fprintf(w, "%s has changed since it was synced from %s at %s:\n", dir, m.Repository, m.SHA)
+		for _, c := range changes {
+			fmt.Fprintf(w, "  %s %s\n", changeSymbols[c.Kind], c.Path)
+		}
+	}
+
+	fmt.Fprintf(w, "Verified %d services: %d edited, %d without a manifest\n", len(dirs)-unmanaged, edited, unmanaged)
+	if edited > 0 {
+		return fmt.Errorf("%d services have been edited since they were synced", edited)
+	}
+	if required && unmanaged > 0 {
+		return fmt.Errorf("%d services have no %s", unmanaged, provenance.FileName)
+	}
+	return nil
+}
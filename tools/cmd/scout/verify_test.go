@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/baely/infra/tools/internal/provenance"
+)
+
+// writeTestService writes a service directory with a deploy.yaml and, if
+// synced, the manifest scout would have written for it.
+func writeTestService(t *testing.T, dir, deployFile string, synced bool) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "deploy.yaml"), []byte(deployFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !synced {
+		return
+	}
+	m := &provenance.Manifest{
+		Repository: "https://github.com/baely/blog",
+		SHA:        "0123456",
+		Files:      []provenance.File{{Path: "deploy.yaml", SHA256: provenance.Checksum([]byte("services: {}\n"))}},
+	}
+	b, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, provenance.FileName), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyDirs(t *testing.T) {
+	root := t.TempDir()
+	synced := filepath.Join(root, "synced")
+	edited := filepath.Join(root, "edited")
+	unmanaged := filepath.Join(root, "unmanaged")
+	writeTestService(t, synced, "services: {}\n", true)
+	writeTestService(t, edited, "services: {web: {}}\n", true)
+	writeTestService(t, unmanaged, "services: {}\n", false)
+
+	tests := []struct {
+		name     string
+		dirs     []string
+		required bool
+		wantErr  bool
+		wantOut  string
+	}{
+		{name: "synced", dirs: []string{synced}, wantOut: "Verified 1 services: 0 edited, 0 without a manifest\n"},
+		{name: "edited", dirs: []string{synced, edited}, wantErr: true, wantOut: edited + " has changed since it was synced from https://github.com/baely/blog at 0123456:\n  ~ deploy.yaml\n"},
+		{name: "unmanaged", dirs: []string{unmanaged}, wantOut: unmanaged + " has no .scout.json, skipped\n"},
+		{name: "unmanaged with manifests required", dirs: []string{synced, unmanaged}, required: true, wantErr: true, wantOut: unmanaged + " has no .scout.json\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			err := verifyDirs(&out, tt.dirs, tt.required)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyDirs() = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("verifyDirs() printed:\n%s\nwant it to contain:\n%s", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestServiceDirs(t *testing.T) {
+	root := t.TempDir()
+	writeTestService(t, filepath.Join(root, "a"), "services: {}\n", false)
+	writeTestService(t, filepath.Join(root, "b"), "services: {}\n", false)
+	if err := os.WriteFile(filepath.Join(root, "README.md"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dirs, err := serviceDirs(root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(root, "a"), filepath.Join(root, "b")}; strings.Join(dirs, ",") != strings.Join(want, ",") {
+		t.Errorf("serviceDirs() = %q, want %q", dirs, want)
+	}
+
+	if _, err := serviceDirs(root, []string{"c"}); err == nil {
+		t.Errorf("serviceDirs() of an unknown service succeeded")
+	}
+}
@@ -9,6 +9,8 @@ package squadv1alpha1
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -156,6 +158,9 @@ func (x *AssembleRequest) GetTag() AssembleRequest_Tag {
 
 type AssembleResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         string                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	ImageId       string                 `protobuf:"bytes,2,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	Digest        string                 `protobuf:"bytes,3,opt,name=digest,proto3" json:"digest,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -190,10 +195,37 @@ func (*AssembleResponse) Descriptor() ([]byte, []int) {
 	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{1}
 }
 
+func (x *AssembleResponse) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *AssembleResponse) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *AssembleResponse) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
 type StartRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
-	Ref           string                 `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Service string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Ref     string                 `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"`
+	// Host to deploy to, overriding the service's configured host.
+	Host string `protobuf:"bytes,3,opt,name=host,proto3" json:"host,omitempty"`
+	// Report what the deploy would change without changing anything.
+	DryRun bool `protobuf:"varint,4,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// Environment to deploy to (default: Coach's default environment).
+	Environment   string `protobuf:"bytes,5,opt,name=environment,proto3" json:"environment,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -242,8 +274,32 @@ func (x *StartRequest) GetRef() string {
 	return ""
 }
 
+func (x *StartRequest) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *StartRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *StartRequest) GetEnvironment() string {
+	if x != nil {
+		return x.Environment
+	}
+	return ""
+}
+
 type StartResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Containers []*Container           `protobuf:"bytes,1,rep,name=containers,proto3" json:"containers,omitempty"`
+	// Only set for dry runs, which leave containers empty.
+	Plan          *Plan `protobuf:"bytes,2,opt,name=plan,proto3" json:"plan,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -278,33 +334,2058 @@ func (*StartResponse) Descriptor() ([]byte, []int) {
 	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{3}
 }
 
-var File_squad_v1alpha1_coach_proto protoreflect.FileDescriptor
+func (x *StartResponse) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
 
-const file_squad_v1alpha1_coach_proto_rawDesc = "" +
-	"\n" +
-	"\x1asquad/v1alpha1/coach.proto\x12\x0esquad.v1alpha1\"\xd0\x02\n" +
-	"\x0fAssembleRequest\x12\x12\n" +
-	"\x04repo\x18\x01 \x01(\tR\x04repo\x12\x10\n" +
-	"\x03ref\x18\x02 \x01(\tR\x03ref\x124\n" +
-	"\x13dockerfile_location\x18\x03 \x01(\tH\x00R\x12dockerfileLocation\x88\x01\x01\x12.\n" +
-	"\x10context_location\x18\x04 \x01(\tH\x01R\x0fcontextLocation\x88\x01\x01\x12\x14\n" +
-	"\x05image\x18\x05 \x01(\tR\x05image\x125\n" +
-	"\x03tag\x18\x06 \x01(\x0e2#.squad.v1alpha1.AssembleRequest.TagR\x03tag\"7\n" +
-	"\x03Tag\x12\x13\n" +
-	"\x0fTAG_UNSPECIFIED\x10\x00\x12\x0e\n" +
-	"\n" +
-	"TAG_LATEST\x10\x01\x12\v\n" +
-	"\aTAG_SHA\x10\x02B\x16\n" +
-	"\x14_dockerfile_locationB\x13\n" +
-	"\x11_context_location\"\x12\n" +
-	"\x10AssembleResponse\":\n" +
-	"\fStartRequest\x12\x18\n" +
-	"\aservice\x18\x01 \x01(\tR\aservice\x12\x10\n" +
-	"\x03ref\x18\x02 \x01(\tR\x03ref\"\x0f\n" +
-	"\rStartResponse2\xa3\x01\n" +
+func (x *StartResponse) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+// PromoteRequest deploys the commit running in one environment to another.
+type PromoteRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Service string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	From    string                 `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	To      string                 `protobuf:"bytes,3,opt,name=to,proto3" json:"to,omitempty"`
+	// Report what the deploy would change without changing anything.
+	DryRun        bool `protobuf:"varint,4,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PromoteRequest) Reset() {
+	*x = PromoteRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PromoteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PromoteRequest) ProtoMessage() {}
+
+func (x *PromoteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PromoteRequest.ProtoReflect.Descriptor instead.
+func (*PromoteRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PromoteRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *PromoteRequest) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *PromoteRequest) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *PromoteRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type PromoteResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Commit of the infra repo that was promoted.
+	Sha        string       `protobuf:"bytes,1,opt,name=sha,proto3" json:"sha,omitempty"`
+	Containers []*Container `protobuf:"bytes,2,rep,name=containers,proto3" json:"containers,omitempty"`
+	// Only set for dry runs, which leave containers empty.
+	Plan          *Plan `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PromoteResponse) Reset() {
+	*x = PromoteResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PromoteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PromoteResponse) ProtoMessage() {}
+
+func (x *PromoteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PromoteResponse.ProtoReflect.Descriptor instead.
+func (*PromoteResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *PromoteResponse) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+func (x *PromoteResponse) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+func (x *PromoteResponse) GetPlan() *Plan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+// CreatePreviewRequest deploys a service's config at a ref as a temporary
+// copy of the service, routed at pr-<pull_request>.<host> for each of its hosts.
+// Creating a preview that already exists updates it and restarts its TTL.
+type CreatePreviewRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Service string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	// Number of the pull request being previewed.
+	PullRequest int32 `protobuf:"varint,2,opt,name=pull_request,json=pullRequest,proto3" json:"pull_request,omitempty"`
+	// Ref of the infra repo holding the pull request's config.
+	Ref string `protobuf:"bytes,3,opt,name=ref,proto3" json:"ref,omitempty"`
+	// How long the preview lives (default: Coach's preview TTL).
+	Ttl           *durationpb.Duration `protobuf:"bytes,4,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePreviewRequest) Reset() {
+	*x = CreatePreviewRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePreviewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePreviewRequest) ProtoMessage() {}
+
+func (x *CreatePreviewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePreviewRequest.ProtoReflect.Descriptor instead.
+func (*CreatePreviewRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreatePreviewRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *CreatePreviewRequest) GetPullRequest() int32 {
+	if x != nil {
+		return x.PullRequest
+	}
+	return 0
+}
+
+func (x *CreatePreviewRequest) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *CreatePreviewRequest) GetTtl() *durationpb.Duration {
+	if x != nil {
+		return x.Ttl
+	}
+	return nil
+}
+
+type CreatePreviewResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Preview       *Preview               `protobuf:"bytes,1,opt,name=preview,proto3" json:"preview,omitempty"`
+	Containers    []*Container           `protobuf:"bytes,2,rep,name=containers,proto3" json:"containers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePreviewResponse) Reset() {
+	*x = CreatePreviewResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePreviewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePreviewResponse) ProtoMessage() {}
+
+func (x *CreatePreviewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePreviewResponse.ProtoReflect.Descriptor instead.
+func (*CreatePreviewResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreatePreviewResponse) GetPreview() *Preview {
+	if x != nil {
+		return x.Preview
+	}
+	return nil
+}
+
+func (x *CreatePreviewResponse) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+type DeletePreviewRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	PullRequest   int32                  `protobuf:"varint,2,opt,name=pull_request,json=pullRequest,proto3" json:"pull_request,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePreviewRequest) Reset() {
+	*x = DeletePreviewRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePreviewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePreviewRequest) ProtoMessage() {}
+
+func (x *DeletePreviewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePreviewRequest.ProtoReflect.Descriptor instead.
+func (*DeletePreviewRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeletePreviewRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *DeletePreviewRequest) GetPullRequest() int32 {
+	if x != nil {
+		return x.PullRequest
+	}
+	return 0
+}
+
+type DeletePreviewResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePreviewResponse) Reset() {
+	*x = DeletePreviewResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePreviewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePreviewResponse) ProtoMessage() {}
+
+func (x *DeletePreviewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePreviewResponse.ProtoReflect.Descriptor instead.
+func (*DeletePreviewResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{9}
+}
+
+type ListPreviewsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list the service's previews.
+	Service       string `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPreviewsRequest) Reset() {
+	*x = ListPreviewsRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPreviewsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPreviewsRequest) ProtoMessage() {}
+
+func (x *ListPreviewsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPreviewsRequest.ProtoReflect.Descriptor instead.
+func (*ListPreviewsRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListPreviewsRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+type ListPreviewsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Previews      []*Preview             `protobuf:"bytes,1,rep,name=previews,proto3" json:"previews,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPreviewsResponse) Reset() {
+	*x = ListPreviewsResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPreviewsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPreviewsResponse) ProtoMessage() {}
+
+func (x *ListPreviewsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPreviewsResponse.ProtoReflect.Descriptor instead.
+func (*ListPreviewsResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListPreviewsResponse) GetPreviews() []*Preview {
+	if x != nil {
+		return x.Previews
+	}
+	return nil
+}
+
+type Preview struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Service     string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	PullRequest int32                  `protobuf:"varint,2,opt,name=pull_request,json=pullRequest,proto3" json:"pull_request,omitempty"`
+	Ref         string                 `protobuf:"bytes,3,opt,name=ref,proto3" json:"ref,omitempty"`
+	Sha         string                 `protobuf:"bytes,4,opt,name=sha,proto3" json:"sha,omitempty"`
+	// Environment whose host, domain and registries the preview uses.
+	Environment   string                 `protobuf:"bytes,5,opt,name=environment,proto3" json:"environment,omitempty"`
+	Urls          []string               `protobuf:"bytes,6,rep,name=urls,proto3" json:"urls,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Preview) Reset() {
+	*x = Preview{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Preview) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Preview) ProtoMessage() {}
+
+func (x *Preview) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Preview.ProtoReflect.Descriptor instead.
+func (*Preview) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Preview) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Preview) GetPullRequest() int32 {
+	if x != nil {
+		return x.PullRequest
+	}
+	return 0
+}
+
+func (x *Preview) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *Preview) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+func (x *Preview) GetEnvironment() string {
+	if x != nil {
+		return x.Environment
+	}
+	return ""
+}
+
+func (x *Preview) GetUrls() []string {
+	if x != nil {
+		return x.Urls
+	}
+	return nil
+}
+
+func (x *Preview) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Preview) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+type Plan struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Containers []*PlannedContainer    `protobuf:"bytes,1,rep,name=containers,proto3" json:"containers,omitempty"`
+	// Images whose digest would change when pulled.
+	Images []*PlannedImage `protobuf:"bytes,2,rep,name=images,proto3" json:"images,omitempty"`
+	// Unified diff from the deployed config to the config at the ref.
+	// Env files and secrets are only listed as changed.
+	ConfigDiff    string `protobuf:"bytes,3,opt,name=config_diff,json=configDiff,proto3" json:"config_diff,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Plan) Reset() {
+	*x = Plan{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Plan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Plan) ProtoMessage() {}
+
+func (x *Plan) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Plan.ProtoReflect.Descriptor instead.
+func (*Plan) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Plan) GetContainers() []*PlannedContainer {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+func (x *Plan) GetImages() []*PlannedImage {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *Plan) GetConfigDiff() string {
+	if x != nil {
+		return x.ConfigDiff
+	}
+	return ""
+}
+
+type PlannedContainer struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Service string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Name    string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// created, recreated, started, unchanged, or removed for the containers a
+	// blue/green deploy replaces.
+	Action        string `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	Reason        string `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlannedContainer) Reset() {
+	*x = PlannedContainer{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlannedContainer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlannedContainer) ProtoMessage() {}
+
+func (x *PlannedContainer) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlannedContainer.ProtoReflect.Descriptor instead.
+func (*PlannedContainer) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PlannedContainer) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *PlannedContainer) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *PlannedContainer) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *PlannedContainer) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type PlannedImage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Image         string                 `protobuf:"bytes,2,opt,name=image,proto3" json:"image,omitempty"`
+	CurrentDigest string                 `protobuf:"bytes,3,opt,name=current_digest,json=currentDigest,proto3" json:"current_digest,omitempty"`
+	// Empty if the registry couldn't be reached.
+	NewDigest     string `protobuf:"bytes,4,opt,name=new_digest,json=newDigest,proto3" json:"new_digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PlannedImage) Reset() {
+	*x = PlannedImage{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PlannedImage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PlannedImage) ProtoMessage() {}
+
+func (x *PlannedImage) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PlannedImage.ProtoReflect.Descriptor instead.
+func (*PlannedImage) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PlannedImage) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *PlannedImage) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *PlannedImage) GetCurrentDigest() string {
+	if x != nil {
+		return x.CurrentDigest
+	}
+	return ""
+}
+
+func (x *PlannedImage) GetNewDigest() string {
+	if x != nil {
+		return x.NewDigest
+	}
+	return ""
+}
+
+type Container struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Image         string                 `protobuf:"bytes,4,opt,name=image,proto3" json:"image,omitempty"`
+	ImageDigest   string                 `protobuf:"bytes,5,opt,name=image_digest,json=imageDigest,proto3" json:"image_digest,omitempty"`
+	State         string                 `protobuf:"bytes,6,opt,name=state,proto3" json:"state,omitempty"`
+	Health        string                 `protobuf:"bytes,7,opt,name=health,proto3" json:"health,omitempty"`
+	Action        string                 `protobuf:"bytes,8,opt,name=action,proto3" json:"action,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Container) Reset() {
+	*x = Container{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Container) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Container) ProtoMessage() {}
+
+func (x *Container) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Container.ProtoReflect.Descriptor instead.
+func (*Container) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Container) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Container) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Container) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Container) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Container) GetImageDigest() string {
+	if x != nil {
+		return x.ImageDigest
+	}
+	return ""
+}
+
+func (x *Container) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Container) GetHealth() string {
+	if x != nil {
+		return x.Health
+	}
+	return ""
+}
+
+func (x *Container) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+type LintRoutingRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Ref of the infra repo whose docker/ directory is linted.
+	Ref           string `protobuf:"bytes,1,opt,name=ref,proto3" json:"ref,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LintRoutingRequest) Reset() {
+	*x = LintRoutingRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LintRoutingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LintRoutingRequest) ProtoMessage() {}
+
+func (x *LintRoutingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LintRoutingRequest.ProtoReflect.Descriptor instead.
+func (*LintRoutingRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *LintRoutingRequest) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+type LintRoutingResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Conflicts []*RoutingConflict     `protobuf:"bytes,1,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	// Deploy files that couldn't be linted, such as templates.
+	Unlinted      []*UnlintedFile `protobuf:"bytes,2,rep,name=unlinted,proto3" json:"unlinted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LintRoutingResponse) Reset() {
+	*x = LintRoutingResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LintRoutingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LintRoutingResponse) ProtoMessage() {}
+
+func (x *LintRoutingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LintRoutingResponse.ProtoReflect.Descriptor instead.
+func (*LintRoutingResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *LintRoutingResponse) GetConflicts() []*RoutingConflict {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+func (x *LintRoutingResponse) GetUnlinted() []*UnlintedFile {
+	if x != nil {
+		return x.Unlinted
+	}
+	return nil
+}
+
+type RoutingConflict struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Kind    string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Services involved, as <service>/<compose service>, or
+	// <service>/environments/<environment>/<compose service> for an
+	// environment's own deploy.yaml.
+	Services []string `protobuf:"bytes,3,rep,name=services,proto3" json:"services,omitempty"`
+	// Environment the conflict is in.
+	Environment   string `protobuf:"bytes,4,opt,name=environment,proto3" json:"environment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutingConflict) Reset() {
+	*x = RoutingConflict{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutingConflict) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutingConflict) ProtoMessage() {}
+
+func (x *RoutingConflict) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutingConflict.ProtoReflect.Descriptor instead.
+func (*RoutingConflict) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *RoutingConflict) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *RoutingConflict) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *RoutingConflict) GetServices() []string {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+func (x *RoutingConflict) GetEnvironment() string {
+	if x != nil {
+		return x.Environment
+	}
+	return ""
+}
+
+type UnlintedFile struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path relative to the infra repo's docker/ directory, e.g.
+	// github.com_baely_blog/deploy.yaml.tmpl.
+	Path          string `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnlintedFile) Reset() {
+	*x = UnlintedFile{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnlintedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnlintedFile) ProtoMessage() {}
+
+func (x *UnlintedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnlintedFile.ProtoReflect.Descriptor instead.
+func (*UnlintedFile) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *UnlintedFile) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *UnlintedFile) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ListServicesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListServicesRequest) Reset() {
+	*x = ListServicesRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListServicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListServicesRequest) ProtoMessage() {}
+
+func (x *ListServicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListServicesRequest.ProtoReflect.Descriptor instead.
+func (*ListServicesRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{21}
+}
+
+type ListServicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Services      []*Service             `protobuf:"bytes,1,rep,name=services,proto3" json:"services,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListServicesResponse) Reset() {
+	*x = ListServicesResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListServicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListServicesResponse) ProtoMessage() {}
+
+func (x *ListServicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListServicesResponse.ProtoReflect.Descriptor instead.
+func (*ListServicesResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *ListServicesResponse) GetServices() []*Service {
+	if x != nil {
+		return x.Services
+	}
+	return nil
+}
+
+type Service struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// From the service's baileys.public.* labels.
+	Url         string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	Title       string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Description string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	// Ref and host of the last successful deploy.
+	Ref        string                 `protobuf:"bytes,5,opt,name=ref,proto3" json:"ref,omitempty"`
+	Host       string                 `protobuf:"bytes,6,opt,name=host,proto3" json:"host,omitempty"`
+	DeployedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=deployed_at,json=deployedAt,proto3" json:"deployed_at,omitempty"`
+	// running, degraded, stopped or unknown.
+	Status      string       `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"`
+	Containers  []*Container `protobuf:"bytes,9,rep,name=containers,proto3" json:"containers,omitempty"`
+	Environment string       `protobuf:"bytes,10,opt,name=environment,proto3" json:"environment,omitempty"`
+	// Commit of the infra repo the last successful deploy was made from.
+	Sha string `protobuf:"bytes,11,opt,name=sha,proto3" json:"sha,omitempty"`
+	// Where scout synced the deployed config from, if it did.
+	Source        *ServiceSource `protobuf:"bytes,12,opt,name=source,proto3" json:"source,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Service) Reset() {
+	*x = Service{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Service) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Service) ProtoMessage() {}
+
+func (x *Service) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Service.ProtoReflect.Descriptor instead.
+func (*Service) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *Service) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Service) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Service) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Service) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Service) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *Service) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *Service) GetDeployedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DeployedAt
+	}
+	return nil
+}
+
+func (x *Service) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Service) GetContainers() []*Container {
+	if x != nil {
+		return x.Containers
+	}
+	return nil
+}
+
+func (x *Service) GetEnvironment() string {
+	if x != nil {
+		return x.Environment
+	}
+	return ""
+}
+
+func (x *Service) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+func (x *Service) GetSource() *ServiceSource {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+// The repository a service's config was synced from, from the .scout.json
+// manifest scout writes into its directory.
+type ServiceSource struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// URL of the repository.
+	Repository string `protobuf:"bytes,1,opt,name=repository,proto3" json:"repository,omitempty"`
+	Ref        string `protobuf:"bytes,2,opt,name=ref,proto3" json:"ref,omitempty"`
+	// Commit the ref resolved to.
+	Sha           string `protobuf:"bytes,3,opt,name=sha,proto3" json:"sha,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServiceSource) Reset() {
+	*x = ServiceSource{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServiceSource) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServiceSource) ProtoMessage() {}
+
+func (x *ServiceSource) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServiceSource.ProtoReflect.Descriptor instead.
+func (*ServiceSource) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ServiceSource) GetRepository() string {
+	if x != nil {
+		return x.Repository
+	}
+	return ""
+}
+
+func (x *ServiceSource) GetRef() string {
+	if x != nil {
+		return x.Ref
+	}
+	return ""
+}
+
+func (x *ServiceSource) GetSha() string {
+	if x != nil {
+		return x.Sha
+	}
+	return ""
+}
+
+type SetSecretRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Value         []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetSecretRequest) Reset() {
+	*x = SetSecretRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSecretRequest) ProtoMessage() {}
+
+func (x *SetSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSecretRequest.ProtoReflect.Descriptor instead.
+func (*SetSecretRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SetSecretRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *SetSecretRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SetSecretRequest) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type SetSecretResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetSecretResponse) Reset() {
+	*x = SetSecretResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetSecretResponse) ProtoMessage() {}
+
+func (x *SetSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetSecretResponse.ProtoReflect.Descriptor instead.
+func (*SetSecretResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{26}
+}
+
+type ListSecretsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSecretsRequest) Reset() {
+	*x = ListSecretsRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSecretsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSecretsRequest) ProtoMessage() {}
+
+func (x *ListSecretsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSecretsRequest.ProtoReflect.Descriptor instead.
+func (*ListSecretsRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *ListSecretsRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+type ListSecretsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Secrets       []*SecretInfo          `protobuf:"bytes,1,rep,name=secrets,proto3" json:"secrets,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSecretsResponse) Reset() {
+	*x = ListSecretsResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSecretsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSecretsResponse) ProtoMessage() {}
+
+func (x *ListSecretsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSecretsResponse.ProtoReflect.Descriptor instead.
+func (*ListSecretsResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ListSecretsResponse) GetSecrets() []*SecretInfo {
+	if x != nil {
+		return x.Secrets
+	}
+	return nil
+}
+
+// SecretInfo describes a stored secret. Values are never returned.
+type SecretInfo struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SecretInfo) Reset() {
+	*x = SecretInfo{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SecretInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SecretInfo) ProtoMessage() {}
+
+func (x *SecretInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SecretInfo.ProtoReflect.Descriptor instead.
+func (*SecretInfo) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *SecretInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SecretInfo) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type DeleteSecretRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Service       string                 `protobuf:"bytes,1,opt,name=service,proto3" json:"service,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSecretRequest) Reset() {
+	*x = DeleteSecretRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSecretRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSecretRequest) ProtoMessage() {}
+
+func (x *DeleteSecretRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSecretRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSecretRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *DeleteSecretRequest) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *DeleteSecretRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteSecretResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSecretResponse) Reset() {
+	*x = DeleteSecretResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSecretResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSecretResponse) ProtoMessage() {}
+
+func (x *DeleteSecretResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSecretResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSecretResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{31}
+}
+
+type RunGCRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Report what would be removed without removing anything.
+	DryRun        bool `protobuf:"varint,1,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunGCRequest) Reset() {
+	*x = RunGCRequest{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunGCRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunGCRequest) ProtoMessage() {}
+
+func (x *RunGCRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunGCRequest.ProtoReflect.Descriptor instead.
+func (*RunGCRequest) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *RunGCRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type RunGCResponse struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Removed []*GCItem              `protobuf:"bytes,1,rep,name=removed,proto3" json:"removed,omitempty"`
+	// Sum of the sizes of the removed items. Layers shared between images are
+	// counted once for every image.
+	ReclaimedBytes int64 `protobuf:"varint,2,opt,name=reclaimed_bytes,json=reclaimedBytes,proto3" json:"reclaimed_bytes,omitempty"`
+	// Items that couldn't be removed. GC carries on past them.
+	Errors        []string `protobuf:"bytes,3,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunGCResponse) Reset() {
+	*x = RunGCResponse{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunGCResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunGCResponse) ProtoMessage() {}
+
+func (x *RunGCResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunGCResponse.ProtoReflect.Descriptor instead.
+func (*RunGCResponse) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *RunGCResponse) GetRemoved() []*GCItem {
+	if x != nil {
+		return x.Removed
+	}
+	return nil
+}
+
+func (x *RunGCResponse) GetReclaimedBytes() int64 {
+	if x != nil {
+		return x.ReclaimedBytes
+	}
+	return 0
+}
+
+func (x *RunGCResponse) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type GCItem struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// image, build_cache, network, volume or directory.
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	// Runtime or host the item was on. Empty for directories.
+	Runtime       string `protobuf:"bytes,2,opt,name=runtime,proto3" json:"runtime,omitempty"`
+	Name          string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	SizeBytes     int64  `protobuf:"varint,4,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	Reason        string `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GCItem) Reset() {
+	*x = GCItem{}
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GCItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GCItem) ProtoMessage() {}
+
+func (x *GCItem) ProtoReflect() protoreflect.Message {
+	mi := &file_squad_v1alpha1_coach_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GCItem.ProtoReflect.Descriptor instead.
+func (*GCItem) Descriptor() ([]byte, []int) {
+	return file_squad_v1alpha1_coach_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *GCItem) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *GCItem) GetRuntime() string {
+	if x != nil {
+		return x.Runtime
+	}
+	return ""
+}
+
+func (x *GCItem) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GCItem) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
+func (x *GCItem) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+var File_squad_v1alpha1_coach_proto protoreflect.FileDescriptor
+
+const file_squad_v1alpha1_coach_proto_rawDesc = "" +
+	"\n" +
+	"\x1asquad/v1alpha1/coach.proto\x12\x0esquad.v1alpha1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd0\x02\n" +
+	"\x0fAssembleRequest\x12\x12\n" +
+	"\x04repo\x18\x01 \x01(\tR\x04repo\x12\x10\n" +
+	"\x03ref\x18\x02 \x01(\tR\x03ref\x124\n" +
+	"\x13dockerfile_location\x18\x03 \x01(\tH\x00R\x12dockerfileLocation\x88\x01\x01\x12.\n" +
+	"\x10context_location\x18\x04 \x01(\tH\x01R\x0fcontextLocation\x88\x01\x01\x12\x14\n" +
+	"\x05image\x18\x05 \x01(\tR\x05image\x125\n" +
+	"\x03tag\x18\x06 \x01(\x0e2#.squad.v1alpha1.AssembleRequest.TagR\x03tag\"7\n" +
+	"\x03Tag\x12\x13\n" +
+	"\x0fTAG_UNSPECIFIED\x10\x00\x12\x0e\n" +
+	"\n" +
+	"TAG_LATEST\x10\x01\x12\v\n" +
+	"\aTAG_SHA\x10\x02B\x16\n" +
+	"\x14_dockerfile_locationB\x13\n" +
+	"\x11_context_location\"[\n" +
+	"\x10AssembleResponse\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\tR\x05image\x12\x19\n" +
+	"\bimage_id\x18\x02 \x01(\tR\aimageId\x12\x16\n" +
+	"\x06digest\x18\x03 \x01(\tR\x06digest\"\x89\x01\n" +
+	"\fStartRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x10\n" +
+	"\x03ref\x18\x02 \x01(\tR\x03ref\x12\x12\n" +
+	"\x04host\x18\x03 \x01(\tR\x04host\x12\x17\n" +
+	"\adry_run\x18\x04 \x01(\bR\x06dryRun\x12 \n" +
+	"\venvironment\x18\x05 \x01(\tR\venvironment\"t\n" +
+	"\rStartResponse\x129\n" +
+	"\n" +
+	"containers\x18\x01 \x03(\v2\x19.squad.v1alpha1.ContainerR\n" +
+	"containers\x12(\n" +
+	"\x04plan\x18\x02 \x01(\v2\x14.squad.v1alpha1.PlanR\x04plan\"g\n" +
+	"\x0ePromoteRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x12\n" +
+	"\x04from\x18\x02 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x03 \x01(\tR\x02to\x12\x17\n" +
+	"\adry_run\x18\x04 \x01(\bR\x06dryRun\"\x88\x01\n" +
+	"\x0fPromoteResponse\x12\x10\n" +
+	"\x03sha\x18\x01 \x01(\tR\x03sha\x129\n" +
+	"\n" +
+	"containers\x18\x02 \x03(\v2\x19.squad.v1alpha1.ContainerR\n" +
+	"containers\x12(\n" +
+	"\x04plan\x18\x03 \x01(\v2\x14.squad.v1alpha1.PlanR\x04plan\"\x92\x01\n" +
+	"\x14CreatePreviewRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12!\n" +
+	"\fpull_request\x18\x02 \x01(\x05R\vpullRequest\x12\x10\n" +
+	"\x03ref\x18\x03 \x01(\tR\x03ref\x12+\n" +
+	"\x03ttl\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x03ttl\"\x85\x01\n" +
+	"\x15CreatePreviewResponse\x121\n" +
+	"\apreview\x18\x01 \x01(\v2\x17.squad.v1alpha1.PreviewR\apreview\x129\n" +
+	"\n" +
+	"containers\x18\x02 \x03(\v2\x19.squad.v1alpha1.ContainerR\n" +
+	"containers\"S\n" +
+	"\x14DeletePreviewRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12!\n" +
+	"\fpull_request\x18\x02 \x01(\x05R\vpullRequest\"\x17\n" +
+	"\x15DeletePreviewResponse\"/\n" +
+	"\x13ListPreviewsRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\"K\n" +
+	"\x14ListPreviewsResponse\x123\n" +
+	"\bpreviews\x18\x01 \x03(\v2\x17.squad.v1alpha1.PreviewR\bpreviews\"\x96\x02\n" +
+	"\aPreview\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12!\n" +
+	"\fpull_request\x18\x02 \x01(\x05R\vpullRequest\x12\x10\n" +
+	"\x03ref\x18\x03 \x01(\tR\x03ref\x12\x10\n" +
+	"\x03sha\x18\x04 \x01(\tR\x03sha\x12 \n" +
+	"\venvironment\x18\x05 \x01(\tR\venvironment\x12\x12\n" +
+	"\x04urls\x18\x06 \x03(\tR\x04urls\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"expires_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"\x9f\x01\n" +
+	"\x04Plan\x12@\n" +
+	"\n" +
+	"containers\x18\x01 \x03(\v2 .squad.v1alpha1.PlannedContainerR\n" +
+	"containers\x124\n" +
+	"\x06images\x18\x02 \x03(\v2\x1c.squad.v1alpha1.PlannedImageR\x06images\x12\x1f\n" +
+	"\vconfig_diff\x18\x03 \x01(\tR\n" +
+	"configDiff\"p\n" +
+	"\x10PlannedContainer\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"\x84\x01\n" +
+	"\fPlannedImage\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x14\n" +
+	"\x05image\x18\x02 \x01(\tR\x05image\x12%\n" +
+	"\x0ecurrent_digest\x18\x03 \x01(\tR\rcurrentDigest\x12\x1d\n" +
+	"\n" +
+	"new_digest\x18\x04 \x01(\tR\tnewDigest\"\xc8\x01\n" +
+	"\tContainer\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x14\n" +
+	"\x05image\x18\x04 \x01(\tR\x05image\x12!\n" +
+	"\fimage_digest\x18\x05 \x01(\tR\vimageDigest\x12\x14\n" +
+	"\x05state\x18\x06 \x01(\tR\x05state\x12\x16\n" +
+	"\x06health\x18\a \x01(\tR\x06health\x12\x16\n" +
+	"\x06action\x18\b \x01(\tR\x06action\"&\n" +
+	"\x12LintRoutingRequest\x12\x10\n" +
+	"\x03ref\x18\x01 \x01(\tR\x03ref\"\x8e\x01\n" +
+	"\x13LintRoutingResponse\x12=\n" +
+	"\tconflicts\x18\x01 \x03(\v2\x1f.squad.v1alpha1.RoutingConflictR\tconflicts\x128\n" +
+	"\bunlinted\x18\x02 \x03(\v2\x1c.squad.v1alpha1.UnlintedFileR\bunlinted\"}\n" +
+	"\x0fRoutingConflict\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1a\n" +
+	"\bservices\x18\x03 \x03(\tR\bservices\x12 \n" +
+	"\venvironment\x18\x04 \x01(\tR\venvironment\":\n" +
+	"\fUnlintedFile\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\x15\n" +
+	"\x13ListServicesRequest\"K\n" +
+	"\x14ListServicesResponse\x123\n" +
+	"\bservices\x18\x01 \x03(\v2\x17.squad.v1alpha1.ServiceR\bservices\"\x88\x03\n" +
+	"\aService\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\x12\x14\n" +
+	"\x05title\x18\x03 \x01(\tR\x05title\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x10\n" +
+	"\x03ref\x18\x05 \x01(\tR\x03ref\x12\x12\n" +
+	"\x04host\x18\x06 \x01(\tR\x04host\x12;\n" +
+	"\vdeployed_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"deployedAt\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"containers\x18\t \x03(\v2\x19.squad.v1alpha1.ContainerR\n" +
+	"containers\x12 \n" +
+	"\venvironment\x18\n" +
+	" \x01(\tR\venvironment\x12\x10\n" +
+	"\x03sha\x18\v \x01(\tR\x03sha\x125\n" +
+	"\x06source\x18\f \x01(\v2\x1d.squad.v1alpha1.ServiceSourceR\x06source\"S\n" +
+	"\rServiceSource\x12\x1e\n" +
+	"\n" +
+	"repository\x18\x01 \x01(\tR\n" +
+	"repository\x12\x10\n" +
+	"\x03ref\x18\x02 \x01(\tR\x03ref\x12\x10\n" +
+	"\x03sha\x18\x03 \x01(\tR\x03sha\"V\n" +
+	"\x10SetSecretRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\"\x13\n" +
+	"\x11SetSecretResponse\".\n" +
+	"\x12ListSecretsRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\"K\n" +
+	"\x13ListSecretsResponse\x124\n" +
+	"\asecrets\x18\x01 \x03(\v2\x1a.squad.v1alpha1.SecretInfoR\asecrets\"[\n" +
+	"\n" +
+	"SecretInfo\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x129\n" +
+	"\n" +
+	"updated_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"C\n" +
+	"\x13DeleteSecretRequest\x12\x18\n" +
+	"\aservice\x18\x01 \x01(\tR\aservice\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x16\n" +
+	"\x14DeleteSecretResponse\"'\n" +
+	"\fRunGCRequest\x12\x17\n" +
+	"\adry_run\x18\x01 \x01(\bR\x06dryRun\"\x82\x01\n" +
+	"\rRunGCResponse\x120\n" +
+	"\aremoved\x18\x01 \x03(\v2\x16.squad.v1alpha1.GCItemR\aremoved\x12'\n" +
+	"\x0freclaimed_bytes\x18\x02 \x01(\x03R\x0ereclaimedBytes\x12\x16\n" +
+	"\x06errors\x18\x03 \x03(\tR\x06errors\"\x81\x01\n" +
+	"\x06GCItem\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x18\n" +
+	"\aruntime\x18\x02 \x01(\tR\aruntime\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x04 \x01(\x03R\tsizeBytes\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason2\x84\b\n" +
 	"\fCoachService\x12M\n" +
 	"\bAssemble\x12\x1f.squad.v1alpha1.AssembleRequest\x1a .squad.v1alpha1.AssembleResponse\x12D\n" +
-	"\x05Start\x12\x1c.squad.v1alpha1.StartRequest\x1a\x1d.squad.v1alpha1.StartResponseB\xb4\x01\n" +
+	"\x05Start\x12\x1c.squad.v1alpha1.StartRequest\x1a\x1d.squad.v1alpha1.StartResponse\x12J\n" +
+	"\aPromote\x12\x1e.squad.v1alpha1.PromoteRequest\x1a\x1f.squad.v1alpha1.PromoteResponse\x12\\\n" +
+	"\rCreatePreview\x12$.squad.v1alpha1.CreatePreviewRequest\x1a%.squad.v1alpha1.CreatePreviewResponse\x12\\\n" +
+	"\rDeletePreview\x12$.squad.v1alpha1.DeletePreviewRequest\x1a%.squad.v1alpha1.DeletePreviewResponse\x12Y\n" +
+	"\fListPreviews\x12#.squad.v1alpha1.ListPreviewsRequest\x1a$.squad.v1alpha1.ListPreviewsResponse\x12V\n" +
+	"\vLintRouting\x12\".squad.v1alpha1.LintRoutingRequest\x1a#.squad.v1alpha1.LintRoutingResponse\x12Y\n" +
+	"\fListServices\x12#.squad.v1alpha1.ListServicesRequest\x1a$.squad.v1alpha1.ListServicesResponse\x12P\n" +
+	"\tSetSecret\x12 .squad.v1alpha1.SetSecretRequest\x1a!.squad.v1alpha1.SetSecretResponse\x12V\n" +
+	"\vListSecrets\x12\".squad.v1alpha1.ListSecretsRequest\x1a#.squad.v1alpha1.ListSecretsResponse\x12Y\n" +
+	"\fDeleteSecret\x12#.squad.v1alpha1.DeleteSecretRequest\x1a$.squad.v1alpha1.DeleteSecretResponse\x12D\n" +
+	"\x05RunGC\x12\x1c.squad.v1alpha1.RunGCRequest\x1a\x1d.squad.v1alpha1.RunGCResponseB\xb4\x01\n" +
 	"\x12com.squad.v1alpha1B\n" +
 	"CoachProtoP\x01Z9github.com/baely/infra/tools/squad/v1alpha1;squadv1alpha1\xa2\x02\x03SXX\xaa\x02\x0eSquad.V1alpha1\xca\x02\x0eSquad\\V1alpha1\xe2\x02\x1aSquad\\V1alpha1\\GPBMetadata\xea\x02\x0fSquad::V1alpha1b\x06proto3"
 
@@ -321,25 +2402,99 @@ func file_squad_v1alpha1_coach_proto_rawDescGZIP() []byte {
 }
 
 var file_squad_v1alpha1_coach_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_squad_v1alpha1_coach_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_squad_v1alpha1_coach_proto_msgTypes = make([]protoimpl.MessageInfo, 35)
 var file_squad_v1alpha1_coach_proto_goTypes = []any{
-	(AssembleRequest_Tag)(0), // 0: squad.v1alpha1.AssembleRequest.Tag
-	(*AssembleRequest)(nil),  // 1: squad.v1alpha1.AssembleRequest
-	(*AssembleResponse)(nil), // 2: squad.v1alpha1.AssembleResponse
-	(*StartRequest)(nil),     // 3: squad.v1alpha1.StartRequest
-	(*StartResponse)(nil),    // 4: squad.v1alpha1.StartResponse
+	(AssembleRequest_Tag)(0),      // 0: squad.v1alpha1.AssembleRequest.Tag
+	(*AssembleRequest)(nil),       // 1: squad.v1alpha1.AssembleRequest
+	(*AssembleResponse)(nil),      // 2: squad.v1alpha1.AssembleResponse
+	(*StartRequest)(nil),          // 3: squad.v1alpha1.StartRequest
+	(*StartResponse)(nil),         // 4: squad.v1alpha1.StartResponse
+	(*PromoteRequest)(nil),        // 5: squad.v1alpha1.PromoteRequest
+	(*PromoteResponse)(nil),       // 6: squad.v1alpha1.PromoteResponse
+	(*CreatePreviewRequest)(nil),  // 7: squad.v1alpha1.CreatePreviewRequest
+	(*CreatePreviewResponse)(nil), // 8: squad.v1alpha1.CreatePreviewResponse
+	(*DeletePreviewRequest)(nil),  // 9: squad.v1alpha1.DeletePreviewRequest
+	(*DeletePreviewResponse)(nil), // 10: squad.v1alpha1.DeletePreviewResponse
+	(*ListPreviewsRequest)(nil),   // 11: squad.v1alpha1.ListPreviewsRequest
+	(*ListPreviewsResponse)(nil),  // 12: squad.v1alpha1.ListPreviewsResponse
+	(*Preview)(nil),               // 13: squad.v1alpha1.Preview
+	(*Plan)(nil),                  // 14: squad.v1alpha1.Plan
+	(*PlannedContainer)(nil),      // 15: squad.v1alpha1.PlannedContainer
+	(*PlannedImage)(nil),          // 16: squad.v1alpha1.PlannedImage
+	(*Container)(nil),             // 17: squad.v1alpha1.Container
+	(*LintRoutingRequest)(nil),    // 18: squad.v1alpha1.LintRoutingRequest
+	(*LintRoutingResponse)(nil),   // 19: squad.v1alpha1.LintRoutingResponse
+	(*RoutingConflict)(nil),       // 20: squad.v1alpha1.RoutingConflict
+	(*UnlintedFile)(nil),          // 21: squad.v1alpha1.UnlintedFile
+	(*ListServicesRequest)(nil),   // 22: squad.v1alpha1.ListServicesRequest
+	(*ListServicesResponse)(nil),  // 23: squad.v1alpha1.ListServicesResponse
+	(*Service)(nil),               // 24: squad.v1alpha1.Service
+	(*ServiceSource)(nil),         // 25: squad.v1alpha1.ServiceSource
+	(*SetSecretRequest)(nil),      // 26: squad.v1alpha1.SetSecretRequest
+	(*SetSecretResponse)(nil),     // 27: squad.v1alpha1.SetSecretResponse
+	(*ListSecretsRequest)(nil),    // 28: squad.v1alpha1.ListSecretsRequest
+	(*ListSecretsResponse)(nil),   // 29: squad.v1alpha1.ListSecretsResponse
+	(*SecretInfo)(nil),            // 30: squad.v1alpha1.SecretInfo
+	(*DeleteSecretRequest)(nil),   // 31: squad.v1alpha1.DeleteSecretRequest
+	(*DeleteSecretResponse)(nil),  // 32: squad.v1alpha1.DeleteSecretResponse
+	(*RunGCRequest)(nil),          // 33: squad.v1alpha1.RunGCRequest
+	(*RunGCResponse)(nil),         // 34: squad.v1alpha1.RunGCResponse
+	(*GCItem)(nil),                // 35: squad.v1alpha1.GCItem
+	(*durationpb.Duration)(nil),   // 36: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 37: google.protobuf.Timestamp
 }
 var file_squad_v1alpha1_coach_proto_depIdxs = []int32{
-	0, // 0: squad.v1alpha1.AssembleRequest.tag:type_name -> squad.v1alpha1.AssembleRequest.Tag
-	1, // 1: squad.v1alpha1.CoachService.Assemble:input_type -> squad.v1alpha1.AssembleRequest
-	3, // 2: squad.v1alpha1.CoachService.Start:input_type -> squad.v1alpha1.StartRequest
-	2, // 3: squad.v1alpha1.CoachService.Assemble:output_type -> squad.v1alpha1.AssembleResponse
-	4, // 4: squad.v1alpha1.CoachService.Start:output_type -> squad.v1alpha1.StartResponse
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	0,  // 0: squad.v1alpha1.AssembleRequest.tag:type_name -> squad.v1alpha1.AssembleRequest.Tag
+	17, // 1: squad.v1alpha1.StartResponse.containers:type_name -> squad.v1alpha1.Container
+	14, // 2: squad.v1alpha1.StartResponse.plan:type_name -> squad.v1alpha1.Plan
+	17, // 3: squad.v1alpha1.PromoteResponse.containers:type_name -> squad.v1alpha1.Container
+	14, // 4: squad.v1alpha1.PromoteResponse.plan:type_name -> squad.v1alpha1.Plan
+	36, // 5: squad.v1alpha1.CreatePreviewRequest.ttl:type_name -> google.protobuf.Duration
+	13, // 6: squad.v1alpha1.CreatePreviewResponse.preview:type_name -> squad.v1alpha1.Preview
+	17, // 7: squad.v1alpha1.CreatePreviewResponse.containers:type_name -> squad.v1alpha1.Container
+	13, // 8: squad.v1alpha1.ListPreviewsResponse.previews:type_name -> squad.v1alpha1.Preview
+	37, // 9: squad.v1alpha1.Preview.created_at:type_name -> google.protobuf.Timestamp
+	37, // 10: squad.v1alpha1.Preview.expires_at:type_name -> google.protobuf.Timestamp
+	15, // 11: squad.v1alpha1.Plan.containers:type_name -> squad.v1alpha1.PlannedContainer
+	16, // 12: squad.v1alpha1.Plan.images:type_name -> squad.v1alpha1.PlannedImage
+	20, // 13: squad.v1alpha1.LintRoutingResponse.conflicts:type_name -> squad.v1alpha1.RoutingConflict
+	21, // 14: squad.v1alpha1.LintRoutingResponse.unlinted:type_name -> squad.v1alpha1.UnlintedFile
+	24, // 15: squad.v1alpha1.ListServicesResponse.services:type_name -> squad.v1alpha1.Service
+	37, // 16: squad.v1alpha1.Service.deployed_at:type_name -> google.protobuf.Timestamp
+	17, // 17: squad.v1alpha1.Service.containers:type_name -> squad.v1alpha1.Container
+	25, // 18: squad.v1alpha1.Service.source:type_name -> squad.v1alpha1.ServiceSource
+	30, // 19: squad.v1alpha1.ListSecretsResponse.secrets:type_name -> squad.v1alpha1.SecretInfo
+	37, // 20: squad.v1alpha1.SecretInfo.updated_at:type_name -> google.protobuf.Timestamp
+	35, // 21: squad.v1alpha1.RunGCResponse.removed:type_name -> squad.v1alpha1.GCItem
+	1,  // 22: squad.v1alpha1.CoachService.Assemble:input_type -> squad.v1alpha1.AssembleRequest
+	3,  // 23: squad.v1alpha1.CoachService.Start:input_type -> squad.v1alpha1.StartRequest
+	5,  // 24: squad.v1alpha1.CoachService.Promote:input_type -> squad.v1alpha1.PromoteRequest
+	7,  // 25: squad.v1alpha1.CoachService.CreatePreview:input_type -> squad.v1alpha1.CreatePreviewRequest
+	9,  // 26: squad.v1alpha1.CoachService.DeletePreview:input_type -> squad.v1alpha1.DeletePreviewRequest
+	11, // 27: squad.v1alpha1.CoachService.ListPreviews:input_type -> squad.v1alpha1.ListPreviewsRequest
+	18, // 28: squad.v1alpha1.CoachService.LintRouting:input_type -> squad.v1alpha1.LintRoutingRequest
+	22, // 29: squad.v1alpha1.CoachService.ListServices:input_type -> squad.v1alpha1.ListServicesRequest
+	26, // 30: squad.v1alpha1.CoachService.SetSecret:input_type -> squad.v1alpha1.SetSecretRequest
+	28, // 31: squad.v1alpha1.CoachService.ListSecrets:input_type -> squad.v1alpha1.ListSecretsRequest
+	31, // 32: squad.v1alpha1.CoachService.DeleteSecret:input_type -> squad.v1alpha1.DeleteSecretRequest
+	33, // 33: squad.v1alpha1.CoachService.RunGC:input_type -> squad.v1alpha1.RunGCRequest
+	2,  // 34: squad.v1alpha1.CoachService.Assemble:output_type -> squad.v1alpha1.AssembleResponse
+	4,  // 35: squad.v1alpha1.CoachService.Start:output_type -> squad.v1alpha1.StartResponse
+	6,  // 36: squad.v1alpha1.CoachService.Promote:output_type -> squad.v1alpha1.PromoteResponse
+	8,  // 37: squad.v1alpha1.CoachService.CreatePreview:output_type -> squad.v1alpha1.CreatePreviewResponse
+	10, // 38: squad.v1alpha1.CoachService.DeletePreview:output_type -> squad.v1alpha1.DeletePreviewResponse
+	12, // 39: squad.v1alpha1.CoachService.ListPreviews:output_type -> squad.v1alpha1.ListPreviewsResponse
+	19, // 40: squad.v1alpha1.CoachService.LintRouting:output_type -> squad.v1alpha1.LintRoutingResponse
+	23, // 41: squad.v1alpha1.CoachService.ListServices:output_type -> squad.v1alpha1.ListServicesResponse
+	27, // 42: squad.v1alpha1.CoachService.SetSecret:output_type -> squad.v1alpha1.SetSecretResponse
+	29, // 43: squad.v1alpha1.CoachService.ListSecrets:output_type -> squad.v1alpha1.ListSecretsResponse
+	32, // 44: squad.v1alpha1.CoachService.DeleteSecret:output_type -> squad.v1alpha1.DeleteSecretResponse
+	34, // 45: squad.v1alpha1.CoachService.RunGC:output_type -> squad.v1alpha1.RunGCResponse
+	34, // [34:46] is the sub-list for method output_type
+	22, // [22:34] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
 }
 
 func init() { file_squad_v1alpha1_coach_proto_init() }
@@ -354,7 +2509,7 @@ func file_squad_v1alpha1_coach_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_squad_v1alpha1_coach_proto_rawDesc), len(file_squad_v1alpha1_coach_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   4,
+			NumMessages:   35,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,8 +19,18 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	CoachService_Assemble_FullMethodName = "/squad.v1alpha1.CoachService/Assemble"
-	CoachService_Start_FullMethodName    = "/squad.v1alpha1.CoachService/Start"
+	CoachService_Assemble_FullMethodName      = "/squad.v1alpha1.CoachService/Assemble"
+	CoachService_Start_FullMethodName         = "/squad.v1alpha1.CoachService/Start"
+	CoachService_Promote_FullMethodName       = "/squad.v1alpha1.CoachService/Promote"
+	CoachService_CreatePreview_FullMethodName = "/squad.v1alpha1.CoachService/CreatePreview"
+	CoachService_DeletePreview_FullMethodName = "/squad.v1alpha1.CoachService/DeletePreview"
+	CoachService_ListPreviews_FullMethodName  = "/squad.v1alpha1.CoachService/ListPreviews"
+	CoachService_LintRouting_FullMethodName   = "/squad.v1alpha1.CoachService/LintRouting"
+	CoachService_ListServices_FullMethodName  = "/squad.v1alpha1.CoachService/ListServices"
+	CoachService_SetSecret_FullMethodName     = "/squad.v1alpha1.CoachService/SetSecret"
+	CoachService_ListSecrets_FullMethodName   = "/squad.v1alpha1.CoachService/ListSecrets"
+	CoachService_DeleteSecret_FullMethodName  = "/squad.v1alpha1.CoachService/DeleteSecret"
+	CoachService_RunGC_FullMethodName         = "/squad.v1alpha1.CoachService/RunGC"
 )
 
 // CoachServiceClient is the client API for CoachService service.
@@ -29,6 +39,16 @@ const (
 type CoachServiceClient interface {
 	Assemble(ctx context.Context, in *AssembleRequest, opts ...grpc.CallOption) (*AssembleResponse, error)
 	Start(ctx context.Context, in *StartRequest, opts ...grpc.CallOption) (*StartResponse, error)
+	Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error)
+	CreatePreview(ctx context.Context, in *CreatePreviewRequest, opts ...grpc.CallOption) (*CreatePreviewResponse, error)
+	DeletePreview(ctx context.Context, in *DeletePreviewRequest, opts ...grpc.CallOption) (*DeletePreviewResponse, error)
+	ListPreviews(ctx context.Context, in *ListPreviewsRequest, opts ...grpc.CallOption) (*ListPreviewsResponse, error)
+	LintRouting(ctx context.Context, in *LintRoutingRequest, opts ...grpc.CallOption) (*LintRoutingResponse, error)
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error)
+	SetSecret(ctx context.Context, in *SetSecretRequest, opts ...grpc.CallOption) (*SetSecretResponse, error)
+	ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error)
+	RunGC(ctx context.Context, in *RunGCRequest, opts ...grpc.CallOption) (*RunGCResponse, error)
 }
 
 type coachServiceClient struct {
@@ -59,12 +79,122 @@ func (c *coachServiceClient) Start(ctx context.Context, in *StartRequest, opts .
 	return out, nil
 }
 
+func (c *coachServiceClient) Promote(ctx context.Context, in *PromoteRequest, opts ...grpc.CallOption) (*PromoteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PromoteResponse)
+	err := c.cc.Invoke(ctx, CoachService_Promote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) CreatePreview(ctx context.Context, in *CreatePreviewRequest, opts ...grpc.CallOption) (*CreatePreviewResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreatePreviewResponse)
+	err := c.cc.Invoke(ctx, CoachService_CreatePreview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) DeletePreview(ctx context.Context, in *DeletePreviewRequest, opts ...grpc.CallOption) (*DeletePreviewResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePreviewResponse)
+	err := c.cc.Invoke(ctx, CoachService_DeletePreview_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) ListPreviews(ctx context.Context, in *ListPreviewsRequest, opts ...grpc.CallOption) (*ListPreviewsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListPreviewsResponse)
+	err := c.cc.Invoke(ctx, CoachService_ListPreviews_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) LintRouting(ctx context.Context, in *LintRoutingRequest, opts ...grpc.CallOption) (*LintRoutingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LintRoutingResponse)
+	err := c.cc.Invoke(ctx, CoachService_LintRouting_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListServicesResponse)
+	err := c.cc.Invoke(ctx, CoachService_ListServices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) SetSecret(ctx context.Context, in *SetSecretRequest, opts ...grpc.CallOption) (*SetSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetSecretResponse)
+	err := c.cc.Invoke(ctx, CoachService_SetSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSecretsResponse)
+	err := c.cc.Invoke(ctx, CoachService_ListSecrets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*DeleteSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteSecretResponse)
+	err := c.cc.Invoke(ctx, CoachService_DeleteSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *coachServiceClient) RunGC(ctx context.Context, in *RunGCRequest, opts ...grpc.CallOption) (*RunGCResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunGCResponse)
+	err := c.cc.Invoke(ctx, CoachService_RunGC_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CoachServiceServer is the server API for CoachService service.
 // All implementations must embed UnimplementedCoachServiceServer
 // for forward compatibility.
 type CoachServiceServer interface {
 	Assemble(context.Context, *AssembleRequest) (*AssembleResponse, error)
 	Start(context.Context, *StartRequest) (*StartResponse, error)
+	Promote(context.Context, *PromoteRequest) (*PromoteResponse, error)
+	CreatePreview(context.Context, *CreatePreviewRequest) (*CreatePreviewResponse, error)
+	DeletePreview(context.Context, *DeletePreviewRequest) (*DeletePreviewResponse, error)
+	ListPreviews(context.Context, *ListPreviewsRequest) (*ListPreviewsResponse, error)
+	LintRouting(context.Context, *LintRoutingRequest) (*LintRoutingResponse, error)
+	ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error)
+	SetSecret(context.Context, *SetSecretRequest) (*SetSecretResponse, error)
+	ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error)
+	DeleteSecret(context.Context, *DeleteSecretRequest) (*DeleteSecretResponse, error)
+	RunGC(context.Context, *RunGCRequest) (*RunGCResponse, error)
 	mustEmbedUnimplementedCoachServiceServer()
 }
 
@@ -81,6 +211,36 @@ func (UnimplementedCoachServiceServer) Assemble(context.Context, *AssembleReques
 func (UnimplementedCoachServiceServer) Start(context.Context, *StartRequest) (*StartResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
 }
+func (UnimplementedCoachServiceServer) Promote(context.Context, *PromoteRequest) (*PromoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Promote not implemented")
+}
+func (UnimplementedCoachServiceServer) CreatePreview(context.Context, *CreatePreviewRequest) (*CreatePreviewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreatePreview not implemented")
+}
+func (UnimplementedCoachServiceServer) DeletePreview(context.Context, *DeletePreviewRequest) (*DeletePreviewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePreview not implemented")
+}
+func (UnimplementedCoachServiceServer) ListPreviews(context.Context, *ListPreviewsRequest) (*ListPreviewsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPreviews not implemented")
+}
+func (UnimplementedCoachServiceServer) LintRouting(context.Context, *LintRoutingRequest) (*LintRoutingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LintRouting not implemented")
+}
+func (UnimplementedCoachServiceServer) ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedCoachServiceServer) SetSecret(context.Context, *SetSecretRequest) (*SetSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSecret not implemented")
+}
+func (UnimplementedCoachServiceServer) ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSecrets not implemented")
+}
+func (UnimplementedCoachServiceServer) DeleteSecret(context.Context, *DeleteSecretRequest) (*DeleteSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSecret not implemented")
+}
+func (UnimplementedCoachServiceServer) RunGC(context.Context, *RunGCRequest) (*RunGCResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunGC not implemented")
+}
 func (UnimplementedCoachServiceServer) mustEmbedUnimplementedCoachServiceServer() {}
 func (UnimplementedCoachServiceServer) testEmbeddedByValue()                      {}
 
@@ -138,6 +298,186 @@ func _CoachService_Start_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CoachService_Promote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PromoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).Promote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_Promote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).Promote(ctx, req.(*PromoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_CreatePreview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreatePreviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).CreatePreview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_CreatePreview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).CreatePreview(ctx, req.(*CreatePreviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_DeletePreview_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePreviewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).DeletePreview(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_DeletePreview_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).DeletePreview(ctx, req.(*DeletePreviewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_ListPreviews_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPreviewsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).ListPreviews(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_ListPreviews_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).ListPreviews(ctx, req.(*ListPreviewsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_LintRouting_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LintRoutingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).LintRouting(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_LintRouting_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).LintRouting(ctx, req.(*LintRoutingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_ListServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_SetSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).SetSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_SetSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).SetSecret(ctx, req.(*SetSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSecretsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_ListSecrets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).ListSecrets(ctx, req.(*ListSecretsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_DeleteSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).DeleteSecret(ctx, req.(*DeleteSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CoachService_RunGC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunGCRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CoachServiceServer).RunGC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CoachService_RunGC_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CoachServiceServer).RunGC(ctx, req.(*RunGCRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // CoachService_ServiceDesc is the grpc.ServiceDesc for CoachService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +493,46 @@ var CoachService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Start",
 			Handler:    _CoachService_Start_Handler,
 		},
+		{
+			MethodName: "Promote",
+			Handler:    _CoachService_Promote_Handler,
+		},
+		{
+			MethodName: "CreatePreview",
+			Handler:    _CoachService_CreatePreview_Handler,
+		},
+		{
+			MethodName: "DeletePreview",
+			Handler:    _CoachService_DeletePreview_Handler,
+		},
+		{
+			MethodName: "ListPreviews",
+			Handler:    _CoachService_ListPreviews_Handler,
+		},
+		{
+			MethodName: "LintRouting",
+			Handler:    _CoachService_LintRouting_Handler,
+		},
+		{
+			MethodName: "ListServices",
+			Handler:    _CoachService_ListServices_Handler,
+		},
+		{
+			MethodName: "SetSecret",
+			Handler:    _CoachService_SetSecret_Handler,
+		},
+		{
+			MethodName: "ListSecrets",
+			Handler:    _CoachService_ListSecrets_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _CoachService_DeleteSecret_Handler,
+		},
+		{
+			MethodName: "RunGC",
+			Handler:    _CoachService_RunGC_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "squad/v1alpha1/coach.proto",
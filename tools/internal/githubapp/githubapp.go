@@ -0,0 +1,210 @@
+// Package githubapp authenticates to GitHub as the installation of a GitHub
+// App on a user or organization, minting installation tokens as they are
+// needed and refreshing them before they expire.
+package githubapp
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/go-github/v74/github"
+)
+
+const (
+	// jwtLifetime is how long the JWTs the app signs are valid. GitHub allows at most 10 minutes.
+	jwtLifetime = 9 * time.Minute
+	// clockSkew backdates JWTs so a server clock slightly behind ours still accepts them.
+	clockSkew = time.Minute
+	// refreshBefore is how long before it expires an installation token is replaced.
+	refreshBefore = 5 * time.Minute
+)
+
+// Environment variables an app is read from by FromEnv.
+const (
+	IDEnv             = "GITHUB_APP_ID"
+	PrivateKeyEnv     = "GITHUB_APP_PRIVATE_KEY"
+	PrivateKeyFileEnv = "GITHUB_APP_PRIVATE_KEY_FILE"
+)
+
+// App is a GitHub App that can act as any of its installations.
+type App struct {
+	id  int64
+	key *rsa.PrivateKey
+	// client calls the app endpoints, authenticated with a JWT.
+	client *github.Client
+
+	mu     sync.Mutex
+	tokens map[string]*installationToken
+}
+
+type installationToken struct {
+	mu        sync.Mutex
+	id        int64
+	token     string
+	expiresAt time.Time
+}
+
+// New returns the app with the given ID and PEM-encoded private key. Its
+// requests are made through base, or http.DefaultTransport if base is nil.
+func New(id int64, privateKey []byte, base http.RoundTripper) (*App, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key of app %d: %w", id, err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	a := &App{
+		id:     id,
+		key:    key,
+		tokens: make(map[string]*installationToken),
+	}
+	a.client = github.NewClient(&http.Client{Transport: &jwtTransport{app: a, base: base}})
+	return a, nil
+}
+
+// FromEnv returns the app configured by GITHUB_APP_ID and either
+// GITHUB_APP_PRIVATE_KEY, holding the PEM private key, or
+// GITHUB_APP_PRIVATE_KEY_FILE. It returns nil if GITHUB_APP_ID isn't set.
+func FromEnv(base http.RoundTripper) (*App, error) {
+	rawID := os.Getenv(IDEnv)
+	if rawID == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s environment variable must be a GitHub App ID", IDEnv)
+	}
+
+	key := []byte(os.Getenv(PrivateKeyEnv))
+	if keyFile := os.Getenv(PrivateKeyFileEnv); len(key) == 0 && keyFile != "" {
+		key, err = os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read private key: %w", err)
+		}
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("%s or %s environment variable is required with %s", PrivateKeyEnv, PrivateKeyFileEnv, IDEnv)
+	}
+	return New(id, key, base)
+}
+
+// Transport returns a transport that authenticates every request as the
+// app's installation on owner.
+func (a *App) Transport(owner string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &installationTransport{app: a, owner: owner, base: base}
+}
+
+// Client returns a client authenticated as the app's installation on owner.
+func (a *App) Client(owner string, base http.RoundTripper) *github.Client {
+	return github.NewClient(&http.Client{Transport: a.Transport(owner, base)})
+}
+
+// Token returns an installation token for owner, minting one if there isn't
+// one that is valid for a while yet.
+func (a *App) Token(ctx context.Context, owner string) (string, error) {
+	a.mu.Lock()
+	t, ok := a.tokens[strings.ToLower(owner)]
+	if !ok {
+		t = &installationToken{}
+		a.tokens[strings.ToLower(owner)] = t
+	}
+	a.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.token != "" && time.Until(t.expiresAt) > refreshBefore {
+		return t.token, nil
+	}
+
+	if t.id == 0 {
+		id, err := a.installationID(ctx, owner)
+		if err != nil {
+			return "", err
+		}
+		t.id = id
+	}
+
+	token, _, err := a.client.Apps.CreateInstallationToken(ctx, t.id, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create installation token for %s: %w", owner, err)
+	}
+	t.token = token.GetToken()
+	t.expiresAt = token.GetExpiresAt().Time
+	return t.token, nil
+}
+
+// installationID finds the app's installation on owner.
+func (a *App) installationID(ctx context.Context, owner string) (int64, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		installations, resp, err := a.client.Apps.ListInstallations(ctx, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list installations of app %d: %w", a.id, err)
+		}
+		for _, installation := range installations {
+			if strings.EqualFold(installation.GetAccount().GetLogin(), owner) {
+				return installation.GetID(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("app %d is not installed on %s", a.id, owner)
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// jwt returns a JWT that authenticates as the app itself.
+func (a *App) jwt() (string, error) {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(a.id, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-clockSkew)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(jwtLifetime)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(a.key)
+}
+
+type jwtTransport struct {
+	app  *App
+	base http.RoundTripper
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.jwt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign JWT for app %d: %w", t.app.id, err)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+type installationTransport struct {
+	app   *App
+	owner string
+	base  http.RoundTripper
+}
+
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.Token(req.Context(), t.owner)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+token)
+	return t.base.RoundTrip(req)
+}
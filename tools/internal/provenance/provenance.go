@@ -0,0 +1,170 @@
+// Package provenance records where a service's config came from. Scout
+// writes a manifest into every service directory it syncs, and Coach reads it
+// to learn which repository and commit a service is deployed from, and to
+// check that the config hasn't been edited since it was synced.
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileName is the manifest's name in a service directory.
+const FileName = ".scout.json"
+
+// Manifest describes the source of a service directory.
+type Manifest struct {
+	// Repository is the URL of the repository the config was synced from.
+	Repository string `json:"repository"`
+	// Ref is the ref that was synced, e.g. main or v1.2.0.
+	Ref string `json:"ref"`
+	// SHA is the commit the ref resolved to.
+	SHA string `json:"sha"`
+	// ScannedAt is when the files were last synced. It only moves when something else in the manifest changes.
+	ScannedAt time.Time `json:"scanned_at"`
+	// Files are the files scout wrote, sorted by path.
+	Files []File `json:"files"`
+}
+
+// File is a file scout wrote into a service directory.
+type File struct {
+	// Path is relative to the service directory, e.g. environments/stage/vars.yaml.
+	Path string `json:"path"`
+	// Upstream is the file's path in the source repository, e.g. config/environments/stage/vars.yaml.
+	Upstream string `json:"upstream"`
+	// SHA256 is the checksum of the file as scout wrote it.
+	SHA256 string `json:"sha256"`
+	// Header is set if scout prepended # Repo and # Ref comments to the file.
+	Header bool `json:"header,omitempty"`
+	// Placeholders are the placeholders substituted in the file, e.g. sha.
+	Placeholders []string `json:"placeholders,omitempty"`
+}
+
+// Kinds of changes to a service directory since scout wrote it.
+const (
+	Added    = "added"
+	Modified = "modified"
+	Missing  = "missing"
+)
+
+// Change is a file in a service directory that differs from its manifest.
+type Change struct {
+	Path string
+	Kind string
+}
+
+// Parse parses a manifest.
+func Parse(b []byte) (*Manifest, error) {
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", FileName, err)
+	}
+	return m, nil
+}
+
+// Read reads the manifest of a service directory. It returns nil if the
+// directory has none, e.g. because it wasn't written by scout.
+func Read(dir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dir, FileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", FileName, err)
+	}
+	return Parse(b)
+}
+
+// Checksum returns the SHA-256 of a file's content, as recorded in manifests.
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// File returns the manifest's entry for a path relative to the service directory.
+func (m *Manifest) File(path string) (File, bool) {
+	for _, f := range m.Files {
+		if f.Path == path {
+			return f, true
+		}
+	}
+	return File{}, false
+}
+
+// Check returns an error if a file isn't in the manifest or its content
+// isn't what scout wrote.
+func (m *Manifest) Check(path string, content []byte) error {
+	f, ok := m.File(path)
+	if !ok {
+		return fmt.Errorf("%s is not in %s", path, FileName)
+	}
+	if Checksum(content) != f.SHA256 {
+		return fmt.Errorf("%s has been modified since it was synced", path)
+	}
+	return nil
+}
+
+// Verify compares a service directory with its manifest and returns the
+// files that were added, modified or removed since scout wrote them, sorted by path.
+func (m *Manifest) Verify(dir string) ([]Change, error) {
+	var changes []Change
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(dir, func(filename string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == FileName {
+			return nil
+		}
+		seen[rel] = true
+
+		f, ok := m.File(rel)
+		if !ok {
+			changes = append(changes, Change{Path: rel, Kind: Added})
+			return nil
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		if Checksum(content) != f.SHA256 {
+			changes = append(changes, Change{Path: rel, Kind: Modified})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for _, f := range m.Files {
+		if !seen[f.Path] {
+			changes = append(changes, Change{Path: f.Path, Kind: Missing})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// Marshal encodes the manifest as it is written to a service directory.
+func (m *Manifest) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,111 @@
+package provenance
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testManifest() *Manifest {
+	return &Manifest{
+		Repository: "https://github.com/baely/blog",
+		Ref:        "main",
+		SHA:        "0123456789abcdef",
+		Files: []File{
+			{Path: "deploy.yaml", Upstream: "config/deploy.yaml", SHA256: Checksum([]byte("services: {}\n")), Header: true},
+			{Path: "environments/stage/vars.yaml", Upstream: "config/environments/stage/vars.yaml", SHA256: Checksum([]byte("replicas: 1\n"))},
+		},
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+	m, err := Read(dir)
+	if err != nil || m != nil {
+		t.Errorf("Read() without a manifest = %v, %v, want nil, nil", m, err)
+	}
+
+	want := testManifest()
+	b, err := want.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, map[string]string{FileName: string(b)})
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %+v, want %+v", got, want)
+	}
+
+	writeTestFiles(t, dir, map[string]string{FileName: "{"})
+	if _, err := Read(dir); err == nil {
+		t.Errorf("Read() of an invalid manifest succeeded")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m := testManifest()
+	if err := m.Check("deploy.yaml", []byte("services: {}\n")); err != nil {
+		t.Errorf("Check() of an unchanged file: %v", err)
+	}
+	if err := m.Check("deploy.yaml", []byte("services: {web: {}}\n")); err == nil {
+		t.Errorf("Check() of a modified file succeeded")
+	}
+	if err := m.Check(".env", nil); err == nil {
+		t.Errorf("Check() of a file that isn't in the manifest succeeded")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []Change
+	}{
+		{
+			name:  "unchanged",
+			files: map[string]string{"deploy.yaml": "services: {}\n", "environments/stage/vars.yaml": "replicas: 1\n"},
+		},
+		{
+			name:  "added, modified and missing",
+			files: map[string]string{"deploy.yaml": "services: {web: {}}\n", ".env": "TOKEN=secret"},
+			want: []Change{
+				{Path: ".env", Kind: Added},
+				{Path: "deploy.yaml", Kind: Modified},
+				{Path: "environments/stage/vars.yaml", Kind: Missing},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tt.files)
+			writeTestFiles(t, dir, map[string]string{FileName: "{}"})
+
+			got, err := testManifest().Verify(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
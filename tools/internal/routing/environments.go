@@ -0,0 +1,182 @@
+package routing
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DeployTemplateName is a deploy.yaml rendered as a template when it is deployed.
+	DeployTemplateName = DeployFileName + ".tmpl"
+	// EnvironmentsDir holds the files of a service that replace its own in an
+	// environment, e.g. environments/stage/deploy.yaml.
+	EnvironmentsDir = "environments"
+)
+
+// Service is the deploy files of a service directory, e.g. docker/github.com_baely_blog.
+type Service struct {
+	Name string
+	// Files maps the paths of its deploy files relative to the service
+	// directory, e.g. deploy.yaml or environments/stage/deploy.yaml, to their content.
+	Files map[string][]byte
+}
+
+// IsDeployFile reports whether a path relative to a service directory is one
+// of the deploy files LintEnvironments reads.
+func IsDeployFile(name string) bool {
+	if rest, ok := strings.CutPrefix(name, EnvironmentsDir+"/"); ok {
+		env, file, ok := strings.Cut(rest, "/")
+		if !ok || env == "" {
+			return false
+		}
+		name = file
+	}
+	return name == DeployFileName || name == DeployTemplateName
+}
+
+// ReadServices reads the deploy files of every service directory in dir.
+func ReadServices(dir string) ([]Service, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []Service
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		service := Service{Name: entry.Name(), Files: make(map[string][]byte)}
+		root := filepath.Join(dir, entry.Name())
+		err := filepath.WalkDir(root, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, filename)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if !IsDeployFile(rel) {
+				return nil
+			}
+			b, err := os.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			service.Files[rel] = b
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		services = append(services, service)
+	}
+	return services, nil
+}
+
+// Unlinted is a deploy file that couldn't be linted.
+type Unlinted struct {
+	// Path is relative to the directory holding the services, e.g.
+	// github.com_baely_blog/deploy.yaml.tmpl.
+	Path   string
+	Reason string
+}
+
+// LintEnvironments finds the routing conflicts between the services deployed
+// to each environment. A service is deployed to an environment from its
+// environments/<environment>/deploy.yaml if it has one, and from its own
+// deploy.yaml otherwise. Every environment with a deploy.yaml of its own is
+// linted along with defaultEnvironment; conflicts outside the default
+// environment are only reported if they involve an environment's own
+// deploy.yaml, as the rest are reported for the default environment.
+// Templates are only rendered at deploy time, so they are returned as
+// unlinted instead.
+func LintEnvironments(services []Service, defaultEnvironment string) ([]Conflict, []Unlinted, error) {
+	environments := map[string]bool{defaultEnvironment: true}
+	for _, service := range services {
+		for name := range service.Files {
+			if rest, ok := strings.CutPrefix(name, EnvironmentsDir+"/"); ok {
+				env, _, _ := strings.Cut(rest, "/")
+				environments[env] = true
+			}
+		}
+	}
+
+	var (
+		conflicts []Conflict
+		unlinted  []Unlinted
+	)
+	seen := make(map[string]bool)
+	for _, env := range sortedKeys(environments) {
+		var stacks []*Stack
+		for _, service := range services {
+			dir, file, reason := environmentDeployFile(service, env)
+			if file == "" {
+				continue
+			}
+			filePath := path.Join(service.Name, dir, file)
+			if reason != "" {
+				if !seen[filePath] {
+					seen[filePath] = true
+					unlinted = append(unlinted, Unlinted{Path: filePath, Reason: reason})
+				}
+				continue
+			}
+
+			stack, err := ParseDeployFile(path.Join(service.Name, dir), service.Files[path.Join(dir, file)])
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", filePath, err)
+			}
+			stacks = append(stacks, stack)
+		}
+
+		for _, c := range Lint(stacks) {
+			if env != defaultEnvironment && !ownedByEnvironment(c) {
+				continue
+			}
+			c.Environment = env
+			conflicts = append(conflicts, c)
+		}
+	}
+
+	sort.Slice(unlinted, func(i, j int) bool {
+		return unlinted[i].Path < unlinted[j].Path
+	})
+	return conflicts, unlinted, nil
+}
+
+// environmentDeployFile returns the deploy file a service is deployed from in
+// an environment, as the directory it is in and its name. The reason is set
+// if the file can't be linted. The file is empty if the service has none.
+func environmentDeployFile(service Service, env string) (dir, file, reason string) {
+	for _, dir := range []string{path.Join(EnvironmentsDir, env), "."} {
+		_, plain := service.Files[path.Join(dir, DeployFileName)]
+		_, template := service.Files[path.Join(dir, DeployTemplateName)]
+		switch {
+		case plain && template:
+			return dir, DeployTemplateName, fmt.Sprintf("%s and %s can't both be committed", DeployTemplateName, DeployFileName)
+		case template:
+			return dir, DeployTemplateName, "templates are only rendered when they are deployed"
+		case plain:
+			return dir, DeployFileName, ""
+		}
+	}
+	return "", "", ""
+}
+
+// ownedByEnvironment reports whether a conflict involves a deploy.yaml from
+// an environments directory.
+func ownedByEnvironment(c Conflict) bool {
+	for _, owner := range c.Owners {
+		if strings.Contains(owner, "/"+EnvironmentsDir+"/") {
+			return true
+		}
+	}
+	return false
+}
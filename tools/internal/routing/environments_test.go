@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsDeployFile(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"deploy.yaml", true},
+		{"deploy.yaml.tmpl", true},
+		{"environments/stage/deploy.yaml", true},
+		{"environments/stage/deploy.yaml.tmpl", true},
+		{"environments/deploy.yaml", false},
+		{"environments//deploy.yaml", false},
+		{"environments/stage/nested/deploy.yaml", false},
+		{"nested/deploy.yaml", false},
+		{"vars.yaml", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsDeployFile(tt.name); got != tt.want {
+			t.Errorf("IsDeployFile(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLintEnvironments(t *testing.T) {
+	const (
+		blog      = "services: {web: {labels: [traefik.http.routers.blog.rule=Host(`blog.baileys.dev`)]}}"
+		stageBlog = "services: {web: {labels: [traefik.http.routers.blog.rule=Host(`stage.baileys.dev`)]}}"
+		stageApi  = "services: {web: {labels: [traefik.http.routers.api.rule=Host(`stage.baileys.dev`)]}}"
+	)
+
+	tests := []struct {
+		name         string
+		services     []Service
+		wantConflict []Conflict
+		wantUnlinted []Unlinted
+	}{
+		{
+			name: "conflict in the default environment",
+			services: []Service{
+				{Name: "a", Files: map[string][]byte{"deploy.yaml": []byte(blog)}},
+				{Name: "b", Files: map[string][]byte{"deploy.yaml": []byte(blog), "environments/stage/deploy.yaml": []byte(stageApi)}},
+			},
+			wantConflict: []Conflict{
+				{Kind: KindDuplicateRouter, Message: "router http/blog is declared by a/web, b/web", Owners: []string{"a/web", "b/web"}, Environment: "prod"},
+				{Kind: KindOverlappingHost, Message: "routers http/blog (a/web) and http/blog (b/web) both match blog.baileys.dev", Owners: []string{"a/web", "b/web"}, Environment: "prod"},
+			},
+		},
+		{
+			name: "conflict in an environment's deploy.yaml",
+			services: []Service{
+				{Name: "a", Files: map[string][]byte{"deploy.yaml": []byte(blog), "environments/stage/deploy.yaml": []byte(stageBlog)}},
+				{Name: "b", Files: map[string][]byte{"deploy.yaml": []byte(stageApi)}},
+			},
+			wantConflict: []Conflict{
+				{Kind: KindOverlappingHost, Message: "routers http/blog (a/environments/stage/web) and http/api (b/web) both match stage.baileys.dev", Owners: []string{"a/environments/stage/web", "b/web"}, Environment: "stage"},
+			},
+		},
+		{
+			name: "templates",
+			services: []Service{
+				{Name: "a", Files: map[string][]byte{"deploy.yaml.tmpl": []byte(blog)}},
+				{Name: "b", Files: map[string][]byte{"deploy.yaml": []byte(blog), "environments/stage/deploy.yaml.tmpl": []byte(blog)}},
+				{Name: "c", Files: map[string][]byte{"deploy.yaml": []byte(blog), "deploy.yaml.tmpl": []byte(blog)}},
+			},
+			wantUnlinted: []Unlinted{
+				{Path: "a/deploy.yaml.tmpl", Reason: "templates are only rendered when they are deployed"},
+				{Path: "b/environments/stage/deploy.yaml.tmpl", Reason: "templates are only rendered when they are deployed"},
+				{Path: "c/deploy.yaml.tmpl", Reason: "deploy.yaml.tmpl and deploy.yaml can't both be committed"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts, unlinted, err := LintEnvironments(tt.services, "prod")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflict) {
+				t.Errorf("conflicts = %+v, want %+v", conflicts, tt.wantConflict)
+			}
+			if !reflect.DeepEqual(unlinted, tt.wantUnlinted) {
+				t.Errorf("unlinted = %+v, want %+v", unlinted, tt.wantUnlinted)
+			}
+		})
+	}
+}
+
+func TestLintEnvironmentsRejectsInvalidDeployFiles(t *testing.T) {
+	services := []Service{{Name: "a", Files: map[string][]byte{"environments/stage/deploy.yaml": []byte("services: [")}}}
+	if _, _, err := LintEnvironments(services, "prod"); err == nil {
+		t.Errorf("LintEnvironments() succeeded, want an error for a/environments/stage/deploy.yaml")
+	}
+}
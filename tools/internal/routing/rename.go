@@ -0,0 +1,231 @@
+package routing
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Rename returns a copy of labels with every traefik router and service
+// renamed by appending suffix, and every host matched by their rules and TLS
+// domains prefixed with hostPrefix. IP addresses are left as they are, as a
+// prefix would make them invalid. A copy of a service whose routers are all
+// HostBound can then be routed next to the original without replacing it. A
+// router's service reference follows the service if it is declared in the
+// same labels.
+func Rename(labels Labels, suffix, hostPrefix string) Labels {
+	declared := make(map[string]bool)
+	for _, id := range traefikServices(labels) {
+		declared[id] = true
+	}
+
+	renamed := make(Labels, len(labels))
+	for key, value := range labels {
+		if protocol, name, option, ok := traefikKey(key, "services"); ok {
+			renamed[traefikLabel(protocol, "services", name+suffix, option)] = value
+			continue
+		}
+
+		protocol, name, option, ok := traefikKey(key, "routers")
+		if !ok {
+			renamed[key] = value
+			continue
+		}
+
+		lower := strings.ToLower(option)
+		switch {
+		case lower == "rule":
+			value = prefixRuleHosts(value, hostPrefix)
+		case lower == "service" && declared[protocol+"/"+value]:
+			value += suffix
+		case strings.HasPrefix(lower, "tls.domains[") && (strings.HasSuffix(lower, ".main") || strings.HasSuffix(lower, ".sans")):
+			hosts := strings.Split(value, ",")
+			for i, host := range hosts {
+				hosts[i] = prefixHost(hostPrefix, strings.TrimSpace(host))
+			}
+			value = strings.Join(hosts, ",")
+		}
+		renamed[traefikLabel(protocol, "routers", name+suffix, option)] = value
+	}
+	return renamed
+}
+
+// RuleHosts returns the hosts matched by the Host() matchers of a rule.
+func RuleHosts(rule string) []string {
+	hosts, _ := ruleArgs(rule, hostMatcher)
+	return hosts
+}
+
+func traefikLabel(protocol, section, name, option string) string {
+	key := strings.Join([]string{"traefik", protocol, section, name}, ".")
+	if option != "" {
+		key += "." + option
+	}
+	return key
+}
+
+// prefixHost prefixes a host name. IP addresses are returned unchanged.
+func prefixHost(prefix, host string) string {
+	if IsIP(host) {
+		return host
+	}
+	return prefix + host
+}
+
+// IsIP reports whether a host is an IP address rather than a name.
+func IsIP(host string) bool {
+	return net.ParseIP(strings.Trim(host, "[]")) != nil
+}
+
+// prefixRuleHosts prefixes every host matched by the rule's Host() and
+// HostSNI() matchers, other than HostSNI(`*`).
+func prefixRuleHosts(rule, prefix string) string {
+	for _, matcher := range []*regexp.Regexp{hostMatcher, hostSNIMatcher} {
+		rule = matcher.ReplaceAllStringFunc(rule, func(m string) string {
+			return ruleArg.ReplaceAllStringFunc(m, func(arg string) string {
+				quote, host := arg[:1], arg[1:len(arg)-1]
+				if host == "*" {
+					return arg
+				}
+				return quote + prefixHost(prefix, host) + quote
+			})
+		})
+	}
+	return rule
+}
+
+// HostBound reports whether every request the router's rule matches is for
+// one of the host names of its Host() matchers, or HostSNI() matchers for a
+// TCP router. Prefixing those names then routes a renamed copy of the router
+// apart from the original. Rules matching an IP address, HostSNI(`*`) or
+// requests without a host matcher, such as a bare PathPrefix(), aren't.
+func (r Router) HostBound() bool {
+	var matcher string
+	switch r.Protocol {
+	case "http":
+		matcher = "host"
+	case "tcp":
+		matcher = "hostsni"
+	default:
+		return false
+	}
+
+	p := &ruleParser{rule: r.Rule, hostMatcher: matcher}
+	bound, ok := p.or()
+	return ok && p.end() && bound
+}
+
+// ruleParser works out whether a traefik rule is host bound as it parses it.
+// Each parse method returns whether the expression is host bound and whether
+// it parsed.
+type ruleParser struct {
+	rule        string
+	pos         int
+	hostMatcher string
+}
+
+func (p *ruleParser) or() (bool, bool) {
+	bound, ok := p.and()
+	for ok && p.consume("||") {
+		var b bool
+		b, ok = p.and()
+		bound = bound && b
+	}
+	return bound, ok
+}
+
+func (p *ruleParser) and() (bool, bool) {
+	bound, ok := p.unary()
+	for ok && p.consume("&&") {
+		var b bool
+		b, ok = p.unary()
+		bound = bound || b
+	}
+	return bound, ok
+}
+
+func (p *ruleParser) unary() (bool, bool) {
+	switch {
+	case p.consume("!"):
+		// A negated host matches every other host.
+		_, ok := p.unary()
+		return false, ok
+	case p.consume("("):
+		bound, ok := p.or()
+		return bound, ok && p.consume(")")
+	}
+	return p.matcher()
+}
+
+func (p *ruleParser) matcher() (bool, bool) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.rule) && isIdentByte(p.rule[p.pos]) {
+		p.pos++
+	}
+	name := strings.ToLower(p.rule[start:p.pos])
+	if name == "" || !p.consume("(") {
+		return false, false
+	}
+
+	var args []string
+	for !p.consume(")") {
+		if len(args) > 0 && !p.consume(",") {
+			return false, false
+		}
+		arg, ok := p.quoted()
+		if !ok {
+			return false, false
+		}
+		args = append(args, arg)
+	}
+
+	if name != p.hostMatcher || len(args) == 0 {
+		return false, true
+	}
+	for _, host := range args {
+		if host == "*" || IsIP(host) {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+func (p *ruleParser) quoted() (string, bool) {
+	p.skipSpace()
+	if p.pos >= len(p.rule) || (p.rule[p.pos] != '`' && p.rule[p.pos] != '"') {
+		return "", false
+	}
+	quote := p.rule[p.pos]
+	end := strings.IndexByte(p.rule[p.pos+1:], quote)
+	if end < 0 {
+		return "", false
+	}
+	arg := p.rule[p.pos+1 : p.pos+1+end]
+	p.pos += end + 2
+	return arg, true
+}
+
+func (p *ruleParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.rule[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *ruleParser) skipSpace() {
+	for p.pos < len(p.rule) && (p.rule[p.pos] == ' ' || p.rule[p.pos] == '\t' || p.rule[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *ruleParser) end() bool {
+	p.skipSpace()
+	return p.pos == len(p.rule)
+}
+
+func isIdentByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
@@ -0,0 +1,72 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostBound(t *testing.T) {
+	tests := []struct {
+		router Router
+		want   bool
+	}{
+		{Router{Protocol: "http", Rule: "Host(`blog.baileys.dev`)"}, true},
+		{Router{Protocol: "http", Rule: "host(\"blog.baileys.dev\")"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) || Host(`b.baileys.dev`)"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`, `b.baileys.dev`)"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) && PathPrefix(`/api`)"}, true},
+		{Router{Protocol: "http", Rule: "PathPrefix(`/api`) && (Host(`a.baileys.dev`) || Host(`b.baileys.dev`))"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) && (PathPrefix(`/a`) || PathPrefix(`/b`))"}, true},
+		{Router{Protocol: "http", Rule: "Host(`144.6.89.140`)"}, false},
+		{Router{Protocol: "http", Rule: "Host(`[::1]`)"}, false},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) || Host(`144.6.89.140`)"}, false},
+		{Router{Protocol: "http", Rule: "PathPrefix(`/api`)"}, false},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) || PathPrefix(`/api`)"}, false},
+		{Router{Protocol: "http", Rule: "!Host(`a.baileys.dev`)"}, false},
+		{Router{Protocol: "http", Rule: "HostRegexp(`^.+\\.baileys\\.dev$`)"}, false},
+		{Router{Protocol: "http", Rule: "HostSNI(`a.baileys.dev`)"}, false},
+		{Router{Protocol: "http", Rule: ""}, false},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`"}, false},
+		{Router{Protocol: "tcp", Rule: "HostSNI(`a.baileys.dev`)"}, true},
+		{Router{Protocol: "tcp", Rule: "HostSNI(`*`)"}, false},
+		{Router{Protocol: "udp"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.router.HostBound(); got != tt.want {
+			t.Errorf("%s router with rule %q: HostBound() = %v, want %v", tt.router.Protocol, tt.router.Rule, got, tt.want)
+		}
+	}
+}
+
+func TestRename(t *testing.T) {
+	labels := Labels{
+		"traefik.enable":                                     "true",
+		"traefik.http.routers.web.rule":                      "Host(`a.baileys.dev`) || Host(`144.6.89.140`)",
+		"traefik.http.routers.web.service":                   "web",
+		"traefik.http.routers.web.tls.domains[0].main":       "a.baileys.dev",
+		"traefik.http.routers.web.tls.domains[0].sans":       "b.baileys.dev, c.baileys.dev",
+		"traefik.http.routers.api.service":                   "api@file",
+		"traefik.http.services.web.loadbalancer.server.port": "80",
+		"traefik.tcp.routers.db.rule":                        "HostSNI(`*`) || HostSNI(`db.baileys.dev`)",
+		"com.example.other":                                  "kept",
+	}
+
+	want := Labels{
+		"traefik.enable":                                          "true",
+		"traefik.http.routers.web-pr-1.rule":                      "Host(`pr-1.a.baileys.dev`) || Host(`144.6.89.140`)",
+		"traefik.http.routers.web-pr-1.service":                   "web-pr-1",
+		"traefik.http.routers.web-pr-1.tls.domains[0].main":       "pr-1.a.baileys.dev",
+		"traefik.http.routers.web-pr-1.tls.domains[0].sans":       "pr-1.b.baileys.dev,pr-1.c.baileys.dev",
+		"traefik.http.routers.api-pr-1.service":                   "api@file",
+		"traefik.http.services.web-pr-1.loadbalancer.server.port": "80",
+		"traefik.tcp.routers.db-pr-1.rule":                        "HostSNI(`*`) || HostSNI(`pr-1.db.baileys.dev`)",
+		"com.example.other":                                       "kept",
+	}
+	if got := Rename(labels, "-pr-1", "pr-1."); !reflect.DeepEqual(got, want) {
+		t.Errorf("Rename() = %v, want %v", got, want)
+	}
+	if labels["traefik.http.routers.web.rule"] != "Host(`a.baileys.dev`) || Host(`144.6.89.140`)" {
+		t.Errorf("Rename() modified its labels: %v", labels)
+	}
+}
@@ -0,0 +1,396 @@
+// Package routing finds conflicts between the traefik routes that services
+// declare in the labels of their deploy.yaml.
+package routing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// DeployFileName is the compose file each service is deployed from.
+const DeployFileName = "deploy.yaml"
+
+// Kinds of conflict.
+const (
+	// KindDuplicateRouter is a router name declared by more than one service.
+	KindDuplicateRouter = "duplicate-router"
+	// KindDuplicateService is a traefik service name declared by more than one service.
+	KindDuplicateService = "duplicate-service"
+	// KindOverlappingHost is a host matched by the rules of more than one router.
+	KindOverlappingHost = "overlapping-host"
+	// KindEntryPointClash is a TCP entrypoint more than one router competes for.
+	KindEntryPointClash = "entrypoint-clash"
+)
+
+// Labels are a compose service's labels. They can be written as a list of
+// key=value strings or as a map.
+type Labels map[string]string
+
+func (l *Labels) UnmarshalYAML(value *yaml.Node) error {
+	labels := make(Labels)
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			k, v, _ := strings.Cut(item, "=")
+			labels[k] = v
+		}
+	case yaml.MappingNode:
+		var m map[string]string
+		if err := value.Decode(&m); err != nil {
+			return err
+		}
+		for k, v := range m {
+			labels[k] = v
+		}
+	default:
+		return fmt.Errorf("labels must be a list or a map")
+	}
+	*l = labels
+	return nil
+}
+
+// Stack is a deployed service's deploy.yaml, e.g. docker/github.com_baely_blog.
+type Stack struct {
+	// Name is the name of the directory holding the deploy.yaml.
+	Name string
+	// Services maps each compose service to its labels.
+	Services map[string]Labels
+}
+
+// ParseDeployFile reads the labels of every service in a deploy.yaml. Nothing
+// else in the file is interpreted.
+func ParseDeployFile(name string, b []byte) (*Stack, error) {
+	var deployFile struct {
+		Services map[string]struct {
+			Labels Labels `yaml:"labels"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(b, &deployFile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", DeployFileName, err)
+	}
+
+	stack := &Stack{Name: name, Services: make(map[string]Labels)}
+	for service, s := range deployFile.Services {
+		stack.Services[service] = s.Labels
+	}
+	return stack, nil
+}
+
+// LoadDir reads dir/*/deploy.yaml.
+func LoadDir(dir string) ([]*Stack, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*", DeployFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var stacks []*Stack
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		name := filepath.Base(filepath.Dir(file))
+		stack, err := ParseDeployFile(name, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		stacks = append(stacks, stack)
+	}
+	return stacks, nil
+}
+
+// Router is a traefik router declared by a service's labels.
+type Router struct {
+	// Owner is the stack and compose service declaring the router, e.g. github.com_baely_txn/txn.
+	Owner string
+	// Protocol is http, tcp or udp.
+	Protocol    string
+	Name        string
+	Rule        string
+	EntryPoints []string
+}
+
+// Routers returns the routers declared by a service's labels, sorted by protocol and name.
+func Routers(owner string, labels Labels) []Router {
+	routers := make(map[string]*Router)
+	for key, value := range labels {
+		protocol, name, option, ok := traefikKey(key, "routers")
+		if !ok {
+			continue
+		}
+
+		id := protocol + "/" + name
+		r, ok := routers[id]
+		if !ok {
+			r = &Router{Owner: owner, Protocol: protocol, Name: name}
+			routers[id] = r
+		}
+
+		switch strings.ToLower(option) {
+		case "rule":
+			r.Rule = value
+		case "entrypoints":
+			for _, ep := range strings.Split(value, ",") {
+				if ep = strings.TrimSpace(ep); ep != "" {
+					r.EntryPoints = append(r.EntryPoints, ep)
+				}
+			}
+		}
+	}
+
+	var out []Router
+	for _, r := range routers {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID() < out[j].ID()
+	})
+	return out
+}
+
+// ID is the router's protocol and name, e.g. http/blog.
+func (r Router) ID() string {
+	return r.Protocol + "/" + r.Name
+}
+
+// traefikServices returns the traefik services declared by a service's
+// labels, as protocol/name pairs.
+func traefikServices(labels Labels) []string {
+	seen := make(map[string]bool)
+	var services []string
+	for key := range labels {
+		protocol, name, _, ok := traefikKey(key, "services")
+		if !ok {
+			continue
+		}
+		id := protocol + "/" + name
+		if !seen[id] {
+			seen[id] = true
+			services = append(services, id)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// traefikKey splits a label such as traefik.http.routers.blog.rule into its
+// protocol, name and option.
+func traefikKey(key, section string) (protocol, name, option string, ok bool) {
+	parts := strings.SplitN(key, ".", 5)
+	if len(parts) < 4 || parts[0] != "traefik" || parts[2] != section {
+		return "", "", "", false
+	}
+	if len(parts) == 5 {
+		option = parts[4]
+	}
+	return parts[1], parts[3], option, true
+}
+
+// Conflict is a problem with how traefik routes to more than one service.
+type Conflict struct {
+	Kind    string
+	Message string
+	// Owners are the stacks and compose services involved.
+	Owners []string
+	// Environment is the environment the conflict is in, if it was found by LintEnvironments.
+	Environment string
+}
+
+// Lint finds routing conflicts between the services of every stack. Routers
+// and services only conflict across compose services; a service may use the
+// same name for its router and its traefik service.
+func Lint(stacks []*Stack) []Conflict {
+	var (
+		conflicts []Conflict
+		routers   []Router
+	)
+
+	routerOwners := make(map[string][]string)
+	serviceOwners := make(map[string][]string)
+	for _, stack := range stacks {
+		for _, service := range sortedKeys(stack.Services) {
+			owner := stack.Name + "/" + service
+			labels := stack.Services[service]
+			if labels["traefik.enable"] == "false" {
+				continue
+			}
+			for _, r := range Routers(owner, labels) {
+				routers = append(routers, r)
+				routerOwners[r.ID()] = append(routerOwners[r.ID()], owner)
+			}
+			for _, s := range traefikServices(labels) {
+				serviceOwners[s] = append(serviceOwners[s], owner)
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(routerOwners) {
+		if owners := routerOwners[id]; len(owners) > 1 {
+			conflicts = append(conflicts, Conflict{
+				Kind:    KindDuplicateRouter,
+				Message: fmt.Sprintf("router %s is declared by %s", id, strings.Join(owners, ", ")),
+				Owners:  owners,
+			})
+		}
+	}
+	for _, id := range sortedKeys(serviceOwners) {
+		if owners := serviceOwners[id]; len(owners) > 1 {
+			conflicts = append(conflicts, Conflict{
+				Kind:    KindDuplicateService,
+				Message: fmt.Sprintf("service %s is declared by %s", id, strings.Join(owners, ", ")),
+				Owners:  owners,
+			})
+		}
+	}
+
+	sort.SliceStable(routers, func(i, j int) bool {
+		return routers[i].Owner < routers[j].Owner
+	})
+	for i, a := range routers {
+		for _, b := range routers[i+1:] {
+			if a.Owner == b.Owner || a.Protocol != b.Protocol {
+				continue
+			}
+			if c, ok := overlap(a, b); ok {
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	return conflicts
+}
+
+var (
+	hostMatcher    = regexp.MustCompile(`(?i)\bHost\(([^)]*)\)`)
+	hostSNIMatcher = regexp.MustCompile(`(?i)\bHostSNI\(([^)]*)\)`)
+	ruleArg        = regexp.MustCompile("[`\"]([^`\"]*)[`\"]")
+	ruleOperators  = strings.NewReplacer("||", "", "(", "", ")", "", " ", "", "\t", "")
+)
+
+// overlap reports whether two routers on the same protocol can match the same request.
+func overlap(a, b Router) (Conflict, bool) {
+	entryPoints := sharedEntryPoints(a.EntryPoints, b.EntryPoints)
+	if entryPoints == nil {
+		return Conflict{}, false
+	}
+
+	owners := []string{a.Owner, b.Owner}
+	switch a.Protocol {
+	case "http":
+		hosts := sharedHosts(a.Rule, b.Rule, hostMatcher)
+		if len(hosts) == 0 {
+			return Conflict{}, false
+		}
+		return Conflict{
+			Kind:    KindOverlappingHost,
+			Message: fmt.Sprintf("routers %s (%s) and %s (%s) both match %s", a.ID(), a.Owner, b.ID(), b.Owner, strings.Join(hosts, ", ")),
+			Owners:  owners,
+		}, true
+	case "tcp":
+		aSNIs, aOnly := ruleArgs(a.Rule, hostSNIMatcher)
+		bSNIs, bOnly := ruleArgs(b.Rule, hostSNIMatcher)
+		if !aOnly || !bOnly {
+			return Conflict{}, false
+		}
+		if contains(aSNIs, "*") || contains(bSNIs, "*") {
+			return Conflict{
+				Kind:    KindEntryPointClash,
+				Message: fmt.Sprintf("routers %s (%s) and %s (%s) compete for entrypoint %s, and HostSNI(`*`) takes all of its traffic", a.ID(), a.Owner, b.ID(), b.Owner, describeEntryPoints(entryPoints)),
+				Owners:  owners,
+			}, true
+		}
+		if snis := intersect(aSNIs, bSNIs); len(snis) > 0 {
+			return Conflict{
+				Kind:    KindEntryPointClash,
+				Message: fmt.Sprintf("routers %s (%s) and %s (%s) both match SNI %s on entrypoint %s", a.ID(), a.Owner, b.ID(), b.Owner, strings.Join(snis, ", "), describeEntryPoints(entryPoints)),
+				Owners:  owners,
+			}, true
+		}
+	}
+	return Conflict{}, false
+}
+
+// sharedHosts returns the hosts matched by both rules. Rules that match on
+// anything other than hosts, such as a path prefix, are assumed to be
+// deliberately sharing a host and don't overlap.
+func sharedHosts(a, b string, matcher *regexp.Regexp) []string {
+	aHosts, aOnly := ruleArgs(a, matcher)
+	bHosts, bOnly := ruleArgs(b, matcher)
+	if !aOnly || !bOnly {
+		return nil
+	}
+	return intersect(aHosts, bHosts)
+}
+
+// ruleArgs returns the lowercased arguments of every matcher in the rule and
+// whether the rule consists of nothing but those matchers or'd together.
+func ruleArgs(rule string, matcher *regexp.Regexp) ([]string, bool) {
+	var args []string
+	for _, m := range matcher.FindAllStringSubmatch(rule, -1) {
+		for _, arg := range ruleArg.FindAllStringSubmatch(m[1], -1) {
+			args = append(args, strings.ToLower(arg[1]))
+		}
+	}
+	rest := ruleOperators.Replace(matcher.ReplaceAllString(rule, ""))
+	return args, len(args) > 0 && rest == ""
+}
+
+// sharedEntryPoints returns the entrypoints both routers listen on, or nil if
+// they don't share any. A router without entrypoints listens on all of them,
+// which is returned as an empty, non-nil slice.
+func sharedEntryPoints(a, b []string) []string {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return []string{}
+	case len(a) == 0:
+		return b
+	case len(b) == 0:
+		return a
+	}
+	return intersect(a, b)
+}
+
+func describeEntryPoints(entryPoints []string) string {
+	if len(entryPoints) == 0 {
+		return "(all)"
+	}
+	return strings.Join(entryPoints, ", ")
+}
+
+func intersect(a, b []string) []string {
+	var out []string
+	for _, s := range a {
+		if contains(b, s) && !contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
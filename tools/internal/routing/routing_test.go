@@ -0,0 +1,226 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseTestStack(t *testing.T, name, deployFile string) *Stack {
+	t.Helper()
+	stack, err := ParseDeployFile(name, []byte(deployFile))
+	if err != nil {
+		t.Fatalf("ParseDeployFile(%s): %v", name, err)
+	}
+	return stack
+}
+
+func TestParseDeployFileLabels(t *testing.T) {
+	list := parseTestStack(t, "list", `
+services:
+  web:
+    labels:
+      - traefik.enable=true
+      - traefik.http.routers.web.rule=Host(`+"`a.baileys.dev`"+`)
+`)
+	mapping := parseTestStack(t, "map", `
+services:
+  web:
+    labels:
+      traefik.enable: "true"
+      traefik.http.routers.web.rule: Host(`+"`a.baileys.dev`"+`)
+`)
+
+	want := Labels{"traefik.enable": "true", "traefik.http.routers.web.rule": "Host(`a.baileys.dev`)"}
+	if got := list.Services["web"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels from a list = %v, want %v", got, want)
+	}
+	if got := mapping.Services["web"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("labels from a map = %v, want %v", got, want)
+	}
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name   string
+		stacks map[string]string
+		want   []Conflict
+	}{
+		{
+			name: "duplicate router",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.web.rule=Host(`a.baileys.dev`)]}}",
+				"b": "services: {web: {labels: [traefik.http.routers.web.rule=Host(`b.baileys.dev`)]}}",
+			},
+			want: []Conflict{{
+				Kind:    KindDuplicateRouter,
+				Message: "router http/web is declared by a/web, b/web",
+				Owners:  []string{"a/web", "b/web"},
+			}},
+		},
+		{
+			name: "duplicate service",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.services.web.loadbalancer.server.port=80]}}",
+				"b": "services: {web: {labels: [traefik.http.services.web.loadbalancer.server.port=8080]}}",
+			},
+			want: []Conflict{{
+				Kind:    KindDuplicateService,
+				Message: "service http/web is declared by a/web, b/web",
+				Owners:  []string{"a/web", "b/web"},
+			}},
+		},
+		{
+			name: "router and service of the same name in one service",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.web.rule=Host(`a.baileys.dev`), traefik.http.routers.web.service=web, traefik.http.services.web.loadbalancer.server.port=80]}}",
+			},
+		},
+		{
+			name: "overlapping host",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.a.rule=Host(`a.baileys.dev`) || Host(`shared.baileys.dev`)]}}",
+				"b": "services: {web: {labels: [traefik.http.routers.b.rule=Host(`SHARED.baileys.dev`)]}}",
+			},
+			want: []Conflict{{
+				Kind:    KindOverlappingHost,
+				Message: "routers http/a (a/web) and http/b (b/web) both match shared.baileys.dev",
+				Owners:  []string{"a/web", "b/web"},
+			}},
+		},
+		{
+			name: "shared host on different entrypoints",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.a.rule=Host(`a.baileys.dev`), traefik.http.routers.a.entrypoints=web]}}",
+				"b": "services: {web: {labels: [traefik.http.routers.b.rule=Host(`a.baileys.dev`), traefik.http.routers.b.entrypoints=websecure]}}",
+			},
+		},
+		{
+			name: "shared host with a path prefix",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.a.rule=Host(`a.baileys.dev`)]}}",
+				"b": "services: {api: {labels: [traefik.http.routers.b.rule=Host(`a.baileys.dev`) && PathPrefix(`/api`)]}}",
+			},
+		},
+		{
+			name: "traefik disabled",
+			stacks: map[string]string{
+				"a": "services: {web: {labels: [traefik.http.routers.web.rule=Host(`a.baileys.dev`)]}}",
+				"b": "services: {web: {labels: [traefik.enable=false, traefik.http.routers.web.rule=Host(`a.baileys.dev`)]}}",
+			},
+		},
+		{
+			name: "HostSNI catch-all",
+			stacks: map[string]string{
+				"a": "services: {db: {labels: [traefik.tcp.routers.a.rule=HostSNI(`*`), traefik.tcp.routers.a.entrypoints=postgres]}}",
+				"b": "services: {db: {labels: [traefik.tcp.routers.b.rule=HostSNI(`b.baileys.dev`), traefik.tcp.routers.b.entrypoints=postgres]}}",
+			},
+			want: []Conflict{{
+				Kind:    KindEntryPointClash,
+				Message: "routers tcp/a (a/db) and tcp/b (b/db) compete for entrypoint postgres, and HostSNI(`*`) takes all of its traffic",
+				Owners:  []string{"a/db", "b/db"},
+			}},
+		},
+		{
+			name: "shared SNI",
+			stacks: map[string]string{
+				"a": "services: {db: {labels: [traefik.tcp.routers.a.rule=HostSNI(`db.baileys.dev`)]}}",
+				"b": "services: {db: {labels: [traefik.tcp.routers.b.rule=HostSNI(`db.baileys.dev`)]}}",
+			},
+			want: []Conflict{{
+				Kind:    KindEntryPointClash,
+				Message: "routers tcp/a (a/db) and tcp/b (b/db) both match SNI db.baileys.dev on entrypoint (all)",
+				Owners:  []string{"a/db", "b/db"},
+			}},
+		},
+		{
+			name: "different SNIs",
+			stacks: map[string]string{
+				"a": "services: {db: {labels: [traefik.tcp.routers.a.rule=HostSNI(`a.baileys.dev`)]}}",
+				"b": "services: {db: {labels: [traefik.tcp.routers.b.rule=HostSNI(`b.baileys.dev`)]}}",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stacks []*Stack
+			for _, name := range sortedKeys(tt.stacks) {
+				stacks = append(stacks, parseTestStack(t, name, tt.stacks[name]))
+			}
+			if got := Lint(stacks); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		a, b Router
+		want bool
+	}{
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)"}, Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`, `b.baileys.dev`)"}, Router{Protocol: "http", Rule: "(Host(`b.baileys.dev`))"}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)"}, Router{Protocol: "http", Rule: "Host(`b.baileys.dev`)"}, false},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)", EntryPoints: []string{"web", "websecure"}}, Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)", EntryPoints: []string{"websecure"}}, true},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)", EntryPoints: []string{"web"}}, Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)", EntryPoints: []string{"websecure"}}, false},
+		{Router{Protocol: "http", Rule: "Host(`a.baileys.dev`) && Path(`/`)"}, Router{Protocol: "http", Rule: "Host(`a.baileys.dev`)"}, false},
+		{Router{Protocol: "tcp", Rule: "HostSNI(`*`)"}, Router{Protocol: "tcp", Rule: "HostSNI(`*`)"}, true},
+		{Router{Protocol: "tcp", Rule: "HostSNI(`*`) && ClientIP(`10.0.0.0/8`)"}, Router{Protocol: "tcp", Rule: "HostSNI(`*`)"}, false},
+		{Router{Protocol: "udp"}, Router{Protocol: "udp"}, false},
+	}
+
+	for _, tt := range tests {
+		if _, got := overlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("overlap(%q %v, %q %v) = %v, want %v", tt.a.Rule, tt.a.EntryPoints, tt.b.Rule, tt.b.EntryPoints, got, tt.want)
+		}
+	}
+}
+
+func TestRuleHosts(t *testing.T) {
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"Host(`a.baileys.dev`)", []string{"a.baileys.dev"}},
+		{"Host(`A.baileys.dev`) || host(\"b.baileys.dev\")", []string{"a.baileys.dev", "b.baileys.dev"}},
+		{"Host(`a.baileys.dev`, `b.baileys.dev`) && PathPrefix(`/api`)", []string{"a.baileys.dev", "b.baileys.dev"}},
+		{"HostSNI(`a.baileys.dev`)", nil},
+		{"PathPrefix(`/api`)", nil},
+	}
+
+	for _, tt := range tests {
+		if got := RuleHosts(tt.rule); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RuleHosts(%q) = %q, want %q", tt.rule, got, tt.want)
+		}
+	}
+}
+
+// TestLintRepoServices lints the infra repo's own services, where txn and
+// txns both declare the txn router.
+func TestLintRepoServices(t *testing.T) {
+	services, err := ReadServices("../../../docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repoServices []Service
+	for _, service := range services {
+		if service.Name == "github.com_baely_txn" || service.Name == "github.com_baely_txns" {
+			repoServices = append(repoServices, service)
+		}
+	}
+	if len(repoServices) != 2 {
+		t.Fatalf("found %d of the txn and txns services, want 2", len(repoServices))
+	}
+
+	conflicts, _, err := LintEnvironments(repoServices, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range conflicts {
+		if c.Kind == KindDuplicateRouter && c.Message == "router http/txn is declared by github.com_baely_txn/txn, github.com_baely_txns/txns" {
+			return
+		}
+	}
+	t.Errorf("LintEnvironments() = %+v, want the duplicate http/txn router", conflicts)
+}
@@ -0,0 +1,43 @@
+// Package sopsfile names the SOPS-encrypted config files that can be
+// committed next to a service's deploy.yaml. Scout copies them into the infra
+// repo untouched, and Coach decrypts them when the service is deployed.
+package sopsfile
+
+import "strings"
+
+// formats maps the extensions of encrypted files to their SOPS format. Each
+// file is decrypted to the same name without ".enc", e.g. app.enc.env to
+// app.env, and .enc.env to .env.
+var formats = map[string]string{
+	".enc.env":  "dotenv",
+	".enc.json": "json",
+}
+
+// Decrypted returns the name an encrypted file is decrypted to and its SOPS
+// format. ok is false if the file isn't encrypted.
+func Decrypted(name string) (output, format string, ok bool) {
+	for ext, format := range formats {
+		if base, ok := strings.CutSuffix(name, ext); ok {
+			return base + strings.TrimPrefix(ext, ".enc"), format, true
+		}
+	}
+	return "", "", false
+}
+
+// IsEncrypted reports whether a file is SOPS-encrypted, by its name.
+func IsEncrypted(name string) bool {
+	_, _, ok := Decrypted(name)
+	return ok
+}
+
+// Sources returns the names of the encrypted files a file could have been
+// decrypted from, e.g. app.enc.env for app.env.
+func Sources(name string) []string {
+	var sources []string
+	for ext := range formats {
+		if base, ok := strings.CutSuffix(name, strings.TrimPrefix(ext, ".enc")); ok {
+			sources = append(sources, base+ext)
+		}
+	}
+	return sources
+}
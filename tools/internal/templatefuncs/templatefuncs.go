@@ -0,0 +1,30 @@
+// Package templatefuncs names the functions Coach's config templates can use.
+// Coach defines them, and Scout leaves them alone when it substitutes its
+// placeholders, as {{lower}} looks like one.
+package templatefuncs
+
+// Names of the functions.
+const (
+	Digest     = "digest"
+	Default    = "default"
+	Required   = "required"
+	Quote      = "quote"
+	Lower      = "lower"
+	Upper      = "upper"
+	TrimPrefix = "trimPrefix"
+	TrimSuffix = "trimSuffix"
+	Replace    = "replace"
+)
+
+// Names lists every function Coach's templates can use.
+var Names = []string{Digest, Default, Required, Quote, Lower, Upper, TrimPrefix, TrimSuffix, Replace}
+
+// IsName reports whether name is one of the functions.
+func IsName(name string) bool {
+	for _, n := range Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}